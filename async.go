@@ -0,0 +1,184 @@
+package sloggcp
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrClosed is returned when writing to a closed [AsyncHandler].
+var ErrClosed = errors.New("sloggcp: handler closed")
+
+// OverflowPolicy determines the behavior of an [AsyncHandler] when its buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the logging goroutine until there is space in the buffer.
+	// No records are lost, but a slow writer can stall the logging goroutines.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDrop drops the record when the buffer is full.
+	// Logging never blocks, but records can be lost under load.
+	// The number of dropped records is reported by [AsyncHandler.Dropped].
+	OverflowDrop
+)
+
+// AsyncHandler is a handler which encodes records on the logging goroutine,
+// like the handler returned by [NewErrorReportingHandler],
+// and writes them to the underlying writer on a background goroutine.
+// A slow writer, for example a stdout pipe, therefore does not stall
+// the logging goroutines, until the buffer is full.
+//
+// Records are written in the order in which they were encoded,
+// including records of handlers derived with WithAttrs and WithGroup,
+// which share the buffer and the background goroutine.
+// Records which are dropped because of a full buffer (see [OverflowDrop]) are lost.
+// Records in the buffer are lost if the program exits without calling [AsyncHandler.Close].
+//
+// Errors returned by the underlying writer cannot be returned from Handle.
+// The first write error is returned by [AsyncHandler.Flush] and [AsyncHandler.Close].
+type AsyncHandler struct {
+	slog.Handler
+	w *asyncWriter
+}
+
+// NewAsyncHandler returns an [AsyncHandler] writing to w,
+// buffering up to bufferSize encoded records.
+// The overflow policy determines the behavior when the buffer is full.
+// The remaining arguments are the same as for [NewErrorReportingHandler].
+// The handler must be closed with [AsyncHandler.Close] to stop the background goroutine
+// and to make sure all buffered records are written.
+func NewAsyncHandler(w io.Writer, bufferSize int, overflow OverflowPolicy, opts *slog.HandlerOptions, options ...Option) *AsyncHandler {
+	aw := newAsyncWriter(w, bufferSize, overflow)
+	return &AsyncHandler{
+		Handler: NewErrorReportingHandler(aw, opts, options...),
+		w:       aw,
+	}
+}
+
+// Flush blocks until all records handled before the call are written.
+// It returns the first error returned by the underlying writer, if any.
+func (h *AsyncHandler) Flush() error {
+	return h.w.Flush()
+}
+
+// Close writes all buffered records and stops the background goroutine.
+// Records handled after Close are not written and Handle returns [ErrClosed].
+// It returns the first error returned by the underlying writer, if any.
+// Close is safe to call multiple times.
+func (h *AsyncHandler) Close() error {
+	return h.w.Close()
+}
+
+// Dropped returns the number of records dropped because the buffer was full.
+func (h *AsyncHandler) Dropped() uint64 {
+	return h.w.dropped.Load()
+}
+
+// asyncItem is a single encoded record, or a flush request when done is set.
+type asyncItem struct {
+	data []byte
+	done chan struct{}
+}
+
+type asyncWriter struct {
+	w        io.Writer
+	overflow OverflowPolicy
+	items    chan asyncItem
+	stopped  chan struct{}
+	dropped  atomic.Uint64
+
+	mtx    sync.RWMutex // protects closed and sending on items
+	closed bool
+
+	errMtx sync.Mutex
+	err    error // first write error
+}
+
+func newAsyncWriter(w io.Writer, bufferSize int, overflow OverflowPolicy) *asyncWriter {
+	aw := &asyncWriter{
+		w:        w,
+		overflow: overflow,
+		items:    make(chan asyncItem, max(bufferSize, 0)),
+		stopped:  make(chan struct{}),
+	}
+	go aw.run()
+	return aw
+}
+
+func (aw *asyncWriter) run() {
+	defer close(aw.stopped)
+	for item := range aw.items {
+		if item.done != nil {
+			close(item.done)
+			continue
+		}
+		if _, err := aw.w.Write(item.data); err != nil {
+			aw.setErr(err)
+		}
+	}
+}
+
+// Write implements [io.Writer].
+// The data is copied, as the handler reuses its buffer.
+func (aw *asyncWriter) Write(p []byte) (int, error) {
+	aw.mtx.RLock()
+	defer aw.mtx.RUnlock()
+	if aw.closed {
+		return 0, ErrClosed
+	}
+	item := asyncItem{data: append([]byte(nil), p...)}
+	if aw.overflow == OverflowDrop {
+		select {
+		case aw.items <- item:
+		default:
+			aw.dropped.Add(1)
+		}
+		return len(p), nil
+	}
+	aw.items <- item
+	return len(p), nil
+}
+
+// Flush blocks until all data written before the call is written to the underlying writer.
+func (aw *asyncWriter) Flush() error {
+	aw.mtx.RLock()
+	if aw.closed {
+		aw.mtx.RUnlock()
+		<-aw.stopped
+		return aw.getErr()
+	}
+	done := make(chan struct{})
+	aw.items <- asyncItem{done: done}
+	aw.mtx.RUnlock()
+	<-done
+	return aw.getErr()
+}
+
+// Close writes all buffered data and stops the background goroutine.
+// The underlying writer is not closed.
+func (aw *asyncWriter) Close() error {
+	aw.mtx.Lock()
+	if !aw.closed {
+		aw.closed = true
+		close(aw.items)
+	}
+	aw.mtx.Unlock()
+	<-aw.stopped
+	return aw.getErr()
+}
+
+func (aw *asyncWriter) setErr(err error) {
+	aw.errMtx.Lock()
+	defer aw.errMtx.Unlock()
+	if aw.err == nil {
+		aw.err = err
+	}
+}
+
+func (aw *asyncWriter) getErr() error {
+	aw.errMtx.Lock()
+	defer aw.errMtx.Unlock()
+	return aw.err
+}
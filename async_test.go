@@ -0,0 +1,151 @@
+package sloggcp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingWriter blocks every write until release is closed.
+type blockingWriter struct {
+	release chan struct{}
+	mtx     sync.Mutex
+	buf     bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.buf.Write(p)
+}
+
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func messages(t *testing.T, data []byte) []string {
+	t.Helper()
+	var msgs []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var entry struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Failed to decode log output: %v", err)
+		}
+		msgs = append(msgs, entry.Message)
+	}
+	return msgs
+}
+
+func TestAsyncHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewAsyncHandler(&buf, 2, OverflowBlock, nil)
+	logger := slog.New(h)
+	derived := logger.With("a", 1)
+
+	var want []string
+	for i := range 10 {
+		msg := fmt.Sprint("msg ", i)
+		if i%2 == 0 {
+			logger.Info(msg)
+		} else {
+			derived.Info(msg)
+		}
+		want = append(want, msg)
+	}
+	if err := h.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got := messages(t, buf.Bytes()); !slices.Equal(got, want) {
+		t.Errorf("messages = %v, want %v", got, want)
+	}
+
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("second Close() error = %v", err)
+	}
+	if err := h.Flush(); err != nil {
+		t.Fatalf("Flush() after Close error = %v", err)
+	}
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "closed", 0)
+	if err := h.Handle(t.Context(), r); !errors.Is(err, ErrClosed) {
+		t.Errorf("Handle() after Close error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestAsyncHandler_drop(t *testing.T) {
+	w := &blockingWriter{release: make(chan struct{})}
+	h := NewAsyncHandler(w, 1, OverflowDrop, nil)
+	logger := slog.New(h)
+
+	// The first record may be taken by the background goroutine,
+	// which then blocks, so at most 2 records are kept.
+	for i := range 5 {
+		logger.Info(fmt.Sprint("msg ", i))
+	}
+	close(w.release)
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	got := messages(t, w.buf.Bytes())
+	if len(got) == 0 || len(got) > 2 {
+		t.Fatalf("messages = %v, want 1 or 2", got)
+	}
+	if dropped := h.Dropped(); int(dropped) != 5-len(got) {
+		t.Errorf("Dropped() = %d, want %d", dropped, 5-len(got))
+	}
+	if got[0] != "msg 0" {
+		t.Errorf("first message = %q, want %q", got[0], "msg 0")
+	}
+}
+
+func TestAsyncHandler_writeError(t *testing.T) {
+	h := NewAsyncHandler(errWriter{}, 1, OverflowBlock, nil)
+	slog.New(h).Info("msg")
+	if err := h.Flush(); err == nil || err.Error() != "write failed" {
+		t.Errorf("Flush() error = %v, want %q", err, "write failed")
+	}
+	if err := h.Close(); err == nil {
+		t.Error("Close() error = nil, want error")
+	}
+}
+
+func TestAsyncHandler_concurrent(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewAsyncHandler(&buf, 8, OverflowBlock, nil)
+	logger := slog.New(h)
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			for j := range 100 {
+				logger.Info("msg", "goroutine", i, "i", j)
+			}
+		})
+	}
+	wg.Go(func() {
+		if err := h.Flush(); err != nil {
+			t.Errorf("Flush() error = %v", err)
+		}
+	})
+	wg.Wait()
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got := len(messages(t, buf.Bytes())); got != 800 {
+		t.Errorf("got %d records, want 800", got)
+	}
+}
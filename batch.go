@@ -0,0 +1,150 @@
+package sloggcp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// BatchWriter is an [io.Writer] which collects encoded records
+// and writes them to the underlying writer in batches, reducing the number of write calls,
+// for example when logging to a file which is tailed by the logging agent.
+// Each call to Write must contain complete newline terminated records,
+// as written by the handlers of this package, so the records are never split.
+//
+// A batch is written when it reaches maxBytes or maxDelay after its first record was added,
+// whichever comes first. So maxDelay is the maximum latency of a record,
+// which keeps logs of low traffic services timely, while batching under load.
+// The timer only runs while a batch is pending, so an idle writer uses no goroutine.
+// BatchWriter is safe for concurrent use.
+// It must be closed with [BatchWriter.Close] to write the last batch and stop the timer.
+//
+//	w := sloggcp.NewBatchWriter(file, 64<<10, time.Second)
+//	defer w.Close()
+//	logger := slog.New(sloggcp.NewErrorReportingHandler(w, nil))
+type BatchWriter struct {
+	w        io.Writer
+	maxBytes int
+	maxDelay time.Duration
+
+	mtx    sync.Mutex // protects all fields below
+	buf    []byte
+	timer  *time.Timer
+	err    error // error of a timed write, returned by the next call
+	closed bool
+}
+
+// NewBatchWriter returns a [BatchWriter] writing to w.
+// When maxDelay is 0 or less, batches are only written when they reach maxBytes,
+// or on Flush and Close.
+func NewBatchWriter(w io.Writer, maxBytes int, maxDelay time.Duration) *BatchWriter {
+	return &BatchWriter{
+		w:        w,
+		maxBytes: maxBytes,
+		maxDelay: maxDelay,
+		buf:      make([]byte, 0, maxBytes),
+	}
+}
+
+// Write adds p to the current batch.
+// When the batch reaches maxBytes, it is written before Write returns.
+// Records larger than maxBytes are written directly.
+//
+// A batch which fails to be written is kept and written again with the next batch.
+// An error writing a previous batch, by the timer or when the batch was full, is returned and cleared.
+// If p is added nonetheless, Write returns len(p) with an error wrapping [ErrPreviousBatch].
+// Otherwise, as the failed batch already fills the buffer, p is not added
+// and the error does not wrap ErrPreviousBatch.
+// The handlers of this package write such a record to the writer set through [WithFallbackWriter].
+func (b *BatchWriter) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.closed {
+		return 0, ErrClosed
+	}
+	prevErr := b.takeErr()
+	if len(b.buf)+len(p) > b.maxBytes && len(b.buf) > 0 {
+		prevErr = errors.Join(prevErr, b.flush())
+		if len(b.buf) > 0 {
+			return 0, prevErr
+		}
+	}
+	if len(p) >= b.maxBytes {
+		n, err := b.w.Write(p)
+		if err != nil {
+			return n, errors.Join(prevErr, err)
+		}
+		return n, previousBatchError(prevErr)
+	}
+	b.buf = append(b.buf, p...)
+	if b.timer == nil && b.maxDelay > 0 {
+		b.timer = time.AfterFunc(b.maxDelay, b.timedFlush)
+	}
+	return len(p), previousBatchError(prevErr)
+}
+
+// ErrPreviousBatch is wrapped by the errors returned from [BatchWriter.Write]
+// for a batch written before, when the record being written was added nonetheless.
+var ErrPreviousBatch = errors.New("sloggcp: write previous batch")
+
+func previousBatchError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrPreviousBatch, err)
+}
+
+// Flush writes the current batch, including a batch which failed to be written before.
+// An error of a previous timed write is returned as well, and cleared.
+func (b *BatchWriter) Flush() error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return errors.Join(b.takeErr(), b.flush())
+}
+
+// Close writes the current batch and stops the timer.
+// An error of a previous timed write is returned as well.
+// If the batch cannot be written, it is kept, so Flush can write it later.
+// Subsequent calls to Write return [ErrClosed].
+// Close does not close the underlying writer.
+func (b *BatchWriter) Close() error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.closed = true
+	return errors.Join(b.takeErr(), b.flush())
+}
+
+func (b *BatchWriter) timedFlush() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.timer = nil
+	if err := b.flush(); err != nil && b.err == nil {
+		b.err = err
+	}
+}
+
+// flush writes the current batch. On error, the part which was not written is kept.
+// b.mtx must be held.
+func (b *BatchWriter) flush() error {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.buf) == 0 {
+		return nil
+	}
+	n, err := b.w.Write(b.buf)
+	if err == nil && n < len(b.buf) {
+		err = io.ErrShortWrite
+	}
+	b.buf = b.buf[:copy(b.buf, b.buf[n:])]
+	return err
+}
+
+func (b *BatchWriter) takeErr() error {
+	err := b.err
+	b.err = nil
+	return err
+}
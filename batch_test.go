@@ -0,0 +1,400 @@
+package sloggcp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingWriter counts the calls to Write.
+type countingWriter struct {
+	mtx    sync.Mutex
+	buf    bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.writes++
+	return w.buf.Write(p)
+}
+
+func (w *countingWriter) result() (string, int) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.buf.String(), w.writes
+}
+
+func TestBatchWriter(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxBytes   int
+		records    []string
+		flush      bool
+		wantWrites int
+		wantOutput string
+	}{
+		{
+			name:       "no flush",
+			maxBytes:   10,
+			records:    []string{"a\n", "b\n"},
+			wantWrites: 0,
+			wantOutput: "",
+		},
+		{
+			name:       "flush",
+			maxBytes:   10,
+			records:    []string{"a\n", "b\n"},
+			flush:      true,
+			wantWrites: 1,
+			wantOutput: "a\nb\n",
+		},
+		{
+			name:       "max bytes",
+			maxBytes:   6,
+			records:    []string{"aa\n", "bb\n", "cc\n"},
+			wantWrites: 1,
+			wantOutput: "aa\nbb\n",
+		},
+		{
+			name:       "records are not split",
+			maxBytes:   5,
+			records:    []string{"aa\n", "bb\n", "cc\n"},
+			wantWrites: 2,
+			wantOutput: "aa\nbb\n",
+		},
+		{
+			name:       "large record",
+			maxBytes:   4,
+			records:    []string{"a\n", "bbbbbb\n", "c\n"},
+			flush:      true,
+			wantWrites: 3,
+			wantOutput: "a\nbbbbbb\nc\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w countingWriter
+			b := NewBatchWriter(&w, tt.maxBytes, 0)
+			for _, record := range tt.records {
+				if n, err := b.Write([]byte(record)); err != nil || n != len(record) {
+					t.Fatalf("Write() = %d, %v", n, err)
+				}
+			}
+			if tt.flush {
+				if err := b.Flush(); err != nil {
+					t.Fatalf("Flush() error = %v", err)
+				}
+			}
+			output, writes := w.result()
+			if writes != tt.wantWrites {
+				t.Errorf("writes = %d, want %d", writes, tt.wantWrites)
+			}
+			if output != tt.wantOutput {
+				t.Errorf("output = %q, want %q", output, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestBatchWriter_maxDelay(t *testing.T) {
+	var w countingWriter
+	b := NewBatchWriter(&w, 1024, time.Millisecond)
+	defer b.Close()
+	if _, err := b.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if output, _ := w.result(); output == "a\n" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("batch not written after max delay")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBatchWriter_maxDelayLowTraffic(t *testing.T) {
+	var w countingWriter
+	b := NewBatchWriter(&w, 1024, time.Millisecond)
+	defer b.Close()
+	want := ""
+	for _, record := range []string{"a\n", "b\n", "c\n"} {
+		if _, err := b.Write([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+		want += record
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if output, _ := w.result(); output == want {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("record %q not written after max delay", record)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if _, writes := w.result(); writes != 3 {
+		t.Errorf("writes = %d, want 3", writes)
+	}
+}
+
+func TestBatchWriter_CloseStopsTimer(t *testing.T) {
+	var w countingWriter
+	b := NewBatchWriter(&w, 1024, 10*time.Millisecond)
+	if _, err := b.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if output, writes := w.result(); output != "a\n" || writes != 1 {
+		t.Errorf("output = %q, writes = %d, want a single write on Close", output, writes)
+	}
+}
+
+func TestBatchWriter_Close(t *testing.T) {
+	var w countingWriter
+	b := NewBatchWriter(&w, 1024, time.Hour)
+	if _, err := b.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if output, writes := w.result(); output != "a\n" || writes != 1 {
+		t.Errorf("output = %q, writes = %d", output, writes)
+	}
+	if _, err := b.Write([]byte("b\n")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Write() after Close error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestBatchWriter_error(t *testing.T) {
+	b := NewBatchWriter(errWriter{}, 1024, 0)
+	if _, err := b.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Flush(); err == nil {
+		t.Error("Flush() error = nil, want error")
+	}
+}
+
+// failOnceWriter fails the first write.
+type failOnceWriter struct {
+	countingWriter
+	failed bool
+}
+
+func (w *failOnceWriter) Write(p []byte) (int, error) {
+	w.mtx.Lock()
+	if !w.failed {
+		w.failed = true
+		w.mtx.Unlock()
+		return 0, errors.New("write failed")
+	}
+	w.mtx.Unlock()
+	return w.countingWriter.Write(p)
+}
+
+func TestBatchWriter_previousError(t *testing.T) {
+	w := new(failOnceWriter)
+	b := NewBatchWriter(w, 1024, time.Hour)
+	if _, err := b.Write([]byte("retried\n")); err != nil {
+		t.Fatal(err)
+	}
+	b.timedFlush() // fails
+
+	n, err := b.Write([]byte("a\n"))
+	if n != 2 || !errors.Is(err, ErrPreviousBatch) {
+		t.Errorf("Write() = %d, %v, want 2, %v", n, err, ErrPreviousBatch)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if output, _ := w.result(); output != "retried\na\n" {
+		t.Errorf("output = %q, want %q", output, "retried\na\n")
+	}
+}
+
+func TestBatchWriter_previousErrorHandler(t *testing.T) {
+	var (
+		w        = new(failOnceWriter)
+		fallback bytes.Buffer
+		handled  []error
+	)
+	b := NewBatchWriter(w, 1024, time.Hour)
+	h := New(b, WithFallbackWriter(&fallback), WithErrorHandler(func(err error) {
+		handled = append(handled, err)
+	}))
+	logger := slog.New(h)
+	logger.Info("retried")
+	b.timedFlush() // fails
+
+	logger.Info("kept")
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	output, _ := w.result()
+	if got := messages(t, []byte(output)); !slices.Equal(got, []string{"retried", "kept"}) {
+		t.Errorf("messages = %v, want [retried kept]", got)
+	}
+	if fallback.Len() > 0 {
+		t.Errorf("fallback output = %q, want none", fallback.String())
+	}
+	if len(handled) != 1 || !errors.Is(handled[0], ErrPreviousBatch) {
+		t.Errorf("handled errors = %v, want %v", handled, ErrPreviousBatch)
+	}
+}
+
+func TestBatchWriter_fullAfterError(t *testing.T) {
+	var (
+		fallback bytes.Buffer
+		handled  []error
+	)
+	b := NewBatchWriter(errWriter{}, 256, time.Hour)
+	h := New(b, WithFallbackWriter(&fallback), WithErrorHandler(func(err error) {
+		handled = append(handled, err)
+	}))
+	logger := slog.New(h)
+	logger.Info("kept")
+	b.timedFlush() // fails, the batch is kept
+
+	logger.Info("fallback", "padding", strings.Repeat("x", 200))
+	if got := messages(t, fallback.Bytes()); !slices.Equal(got, []string{"fallback"}) {
+		t.Errorf("fallback messages = %v, want [fallback]", got)
+	}
+	if len(handled) != 1 || errors.Is(handled[0], ErrPreviousBatch) {
+		t.Errorf("handled errors = %v, want one not wrapping %v", handled, ErrPreviousBatch)
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if got := messages(t, b.buf); !slices.Equal(got, []string{"kept"}) {
+		t.Errorf("batch messages = %v, want [kept]", got)
+	}
+}
+
+func TestBatchWriter_flushPartial(t *testing.T) {
+	var w countingWriter
+	partial := writerFunc(func(p []byte) (int, error) {
+		w.Write(p[:3])
+		return 3, errors.New("short write")
+	})
+	b := NewBatchWriter(partial, 1024, 0)
+	if _, err := b.Write([]byte("a\nb\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Flush(); err == nil {
+		t.Fatal("Flush() error = nil, want error")
+	}
+	b.w = &w
+	if err := b.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if output, _ := w.result(); output != "a\nb\n" {
+		t.Errorf("output = %q, want %q", output, "a\nb\n")
+	}
+}
+
+func TestBatchWriter_ClosePreviousError(t *testing.T) {
+	var w countingWriter
+	b := NewBatchWriter(&w, 1024, time.Hour)
+	if _, err := b.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	timedErr := errors.New("timed write failed")
+	b.mtx.Lock()
+	b.err = timedErr // as set by a failed timed write of an earlier batch
+	b.mtx.Unlock()
+
+	if err := b.Close(); !errors.Is(err, timedErr) {
+		t.Errorf("Close() error = %v, want %v", err, timedErr)
+	}
+	if output, _ := w.result(); output != "a\n" {
+		t.Errorf("output = %q, want pending batch written on Close", output)
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.timer != nil {
+		t.Error("timer not stopped on Close")
+	}
+}
+
+func TestBatchWriter_concurrent(t *testing.T) {
+	var w countingWriter
+	b := NewBatchWriter(&w, 512, time.Millisecond)
+	logger := slog.New(NewErrorReportingHandler(b, nil))
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			for j := range 100 {
+				logger.Info("msg", "goroutine", i, "i", j)
+			}
+		})
+	}
+	wg.Wait()
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	output, _ := w.result()
+	if got := len(messages(t, []byte(output))); got != 800 {
+		t.Errorf("got %d records, want 800", got)
+	}
+}
+
+// BenchmarkBatchWriter compares the number of writes to the underlying writer
+// with and without batching.
+func BenchmarkBatchWriter(b *testing.B) {
+	benchmarks := []struct {
+		name   string
+		writer func(w io.Writer) (io.Writer, func() error)
+	}{
+		{
+			name: "direct",
+			writer: func(w io.Writer) (io.Writer, func() error) {
+				return w, func() error { return nil }
+			},
+		},
+		{
+			name: "batch 64KiB",
+			writer: func(w io.Writer) (io.Writer, func() error) {
+				bw := NewBatchWriter(w, 64<<10, time.Second)
+				return bw, bw.Close
+			},
+		},
+	}
+	for _, bb := range benchmarks {
+		b.Run(bb.name, func(b *testing.B) {
+			cw := &countingWriter{}
+			w, closeFn := bb.writer(writerFunc(func(p []byte) (int, error) {
+				cw.writes++
+				return len(p), nil
+			}))
+			logger := slog.New(NewErrorReportingHandler(w, nil))
+			for b.Loop() {
+				logger.Info("msg", "a", 1, "b", "two")
+			}
+			if err := closeFn(); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportMetric(float64(cw.writes)/float64(b.N), "writes/op")
+		})
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
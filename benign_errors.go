@@ -0,0 +1,48 @@
+package sloggcp
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// defaultBenignErrors are the errors which do not create error reports by default.
+var defaultBenignErrors = []error{context.Canceled, context.DeadlineExceeded}
+
+// WithBenignErrors sets the errors which are expected in normal operation and do not create error reports,
+// so routine failures, such as requests cancelled by their clients, do not trigger Error Reporting alerts.
+// By default, these are [context.Canceled] and [context.DeadlineExceeded], as returned by ctx.Err().
+// Calling WithBenignErrors without errors creates error reports for all errors.
+//
+// An error attribute whose value matches one of errs, as reported by [errors.Is],
+// is written as a regular attribute, without the fields of the error report,
+// and the [ErrorReporter] is not called. The severity of the record is lowered to WARNING,
+// if it is higher. This takes precedence over [SeverityError] and [WithErrorReportSeverity].
+func WithBenignErrors(errs ...error) Option {
+	return func(c *config) {
+		c.benignErrors = errs
+	}
+}
+
+// isBenignError reports whether value is an error matching one of the errors set through [WithBenignErrors].
+func (c *config) isBenignError(value any) bool {
+	err, ok := value.(error)
+	if !ok || len(c.benignErrors) == 0 {
+		return false
+	}
+	for _, benign := range c.benignErrors {
+		if errors.Is(err, benign) {
+			return true
+		}
+	}
+	return false
+}
+
+// lowerSeverity lowers the severity of a record with a benign error to WARNING, see [WithBenignErrors].
+func lowerSeverity(out *object, severity string) string {
+	if level, err := ParseSeverity(severity); err == nil && level > LevelWarning {
+		out.add(SeverityKey, slog.StringValue(WarningSeverity))
+		return WarningSeverity
+	}
+	return severity
+}
@@ -0,0 +1,125 @@
+package sloggcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestWithBenignErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     []Option
+		level       slog.Level
+		err         error
+		want        map[string]any
+		wantReports int
+	}{
+		{
+			name:  "canceled",
+			level: slog.LevelError,
+			err:   context.Canceled,
+			want:  map[string]any{SeverityKey: WarningSeverity, MessageKey: "msg", ErrorKey: "context canceled"},
+		},
+		{
+			name:  "wrapped deadline exceeded",
+			level: slog.LevelError,
+			err:   fmt.Errorf("fetch: %w", context.DeadlineExceeded),
+			want:  map[string]any{SeverityKey: WarningSeverity, MessageKey: "msg", ErrorKey: "fetch: context deadline exceeded"},
+		},
+		{
+			name:  "lower level kept",
+			level: slog.LevelInfo,
+			err:   context.Canceled,
+			want:  map[string]any{SeverityKey: InfoSeverity, MessageKey: "msg", ErrorKey: "context canceled"},
+		},
+		{
+			name:    "precedence over error report severity",
+			options: []Option{WithErrorReportSeverity(true)},
+			level:   slog.LevelInfo,
+			err:     context.Canceled,
+			want:    map[string]any{SeverityKey: InfoSeverity, MessageKey: "msg", ErrorKey: "context canceled"},
+		},
+		{
+			name:  "other error",
+			level: slog.LevelError,
+			err:   io.EOF,
+			want: map[string]any{
+				SeverityKey:        ErrorSeverity,
+				MessageKey:         "EOF",
+				ErrorKey:           "EOF",
+				ErrorReportTypeKey: ErrorReportTypeValue,
+			},
+			wantReports: 1,
+		},
+		{
+			name:    "custom",
+			options: []Option{WithBenignErrors(io.EOF)},
+			level:   slog.LevelError,
+			err:     io.EOF,
+			want:    map[string]any{SeverityKey: WarningSeverity, MessageKey: "msg", ErrorKey: "EOF"},
+		},
+		{
+			name:    "disabled",
+			options: []Option{WithBenignErrors()},
+			level:   slog.LevelError,
+			err:     context.Canceled,
+			want: map[string]any{
+				SeverityKey:        ErrorSeverity,
+				MessageKey:         "context canceled",
+				ErrorKey:           "context canceled",
+				ErrorReportTypeKey: ErrorReportTypeValue,
+			},
+			wantReports: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				buf     bytes.Buffer
+				reports int
+			)
+			reporter := ErrorReporterFunc(func(context.Context, ErrorReport) { reports++ })
+			options := append([]Option{WithErrorReporter(reporter)}, tt.options...)
+			slog.New(New(&buf, options...)).Log(t.Context(), tt.level, "msg", ErrorKey, tt.err)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			delete(got, TimeKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+			if reports != tt.wantReports {
+				t.Errorf("reports = %d, want %d", reports, tt.wantReports)
+			}
+		})
+	}
+}
+
+func TestWithBenignErrors_grouped(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(New(&buf, WithGroupedErrors(true)))
+	logger.WithGroup("g").Error("msg", ErrorKey, errors.Join(errors.New("stop"), context.Canceled))
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode log output: %v", err)
+	}
+	delete(got, TimeKey)
+	want := map[string]any{
+		SeverityKey: WarningSeverity,
+		MessageKey:  "msg",
+		"g":         map[string]any{ErrorKey: "stop\ncontext canceled"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("log output = %v, want %v", got, want)
+	}
+}
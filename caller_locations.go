@@ -0,0 +1,66 @@
+package sloggcp
+
+import (
+	"errors"
+	"runtime"
+)
+
+// CallerLocationsKey is the key of the locations of the callers of the report location,
+// written for errors implementing [ReportLocationsError].
+const CallerLocationsKey = "callerLocations"
+
+// ReportLocationsError is an error that provides the locations of a few stack frames,
+// from where the error was created, outwards.
+// The first location is reported as report location, unless the error provides one
+// through [ReportLocationError]. The others are written under [CallerLocationsKey],
+// for more context than a single frame, without a full textual stack trace.
+type ReportLocationsError interface {
+	error
+	// ReportLocations returns the locations, starting with the innermost frame.
+	// It may return nil if the locations are unknown.
+	ReportLocations() []*ReportLocation
+}
+
+// NewReportLocations returns the locations of up to depth frames of the current call stack,
+// for an error implementing [ReportLocationsError].
+// The skip parameter is the number of stack frames to skip
+// (0 identifies the caller of NewReportLocations), like for [NewReportLocation].
+func NewReportLocations(skip, depth int) []*ReportLocation {
+	if depth <= 0 {
+		return nil
+	}
+	pcs := make([]uintptr, depth)
+	n := runtime.Callers(skip+2, pcs) // skip runtime.Callers and NewReportLocations
+	if n == 0 {
+		return nil
+	}
+	locations := make([]*ReportLocation, 0, n)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			locations = append(locations, &ReportLocation{
+				FilePath:     frame.File,
+				LineNumber:   frame.Line,
+				FunctionName: frame.Function,
+			})
+		}
+		if !more || len(locations) == depth {
+			return locations
+		}
+	}
+}
+
+// findReportLocations returns the locations of the first [ReportLocationsError]
+// in the error tree of value, as found by [errors.As].
+func findReportLocations(value any) []*ReportLocation {
+	err, ok := value.(error)
+	if !ok {
+		return nil
+	}
+	var target ReportLocationsError
+	if !errors.As(err, &target) {
+		return nil
+	}
+	return target.ReportLocations()
+}
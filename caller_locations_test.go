@@ -0,0 +1,124 @@
+package sloggcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestNewReportLocations(t *testing.T) {
+	tests := []struct {
+		name      string
+		skip      int
+		depth     int
+		wantFuncs []string
+	}{
+		{
+			name:      "caller",
+			depth:     2,
+			wantFuncs: []string{"github.com/zitadel/sloggcp.TestNewReportLocations.func1", "testing.tRunner"},
+		},
+		{
+			name:      "skip",
+			skip:      1,
+			depth:     1,
+			wantFuncs: []string{"testing.tRunner"},
+		},
+		{
+			name:  "zero depth",
+			depth: 0,
+		},
+		{
+			name:  "skip all",
+			skip:  100,
+			depth: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var funcs []string
+			for _, location := range NewReportLocations(tt.skip, tt.depth) {
+				funcs = append(funcs, location.FunctionName)
+			}
+			if !reflect.DeepEqual(funcs, tt.wantFuncs) {
+				t.Errorf("NewReportLocations() functions = %v, want %v", funcs, tt.wantFuncs)
+			}
+		})
+	}
+}
+
+// locationsError is a [ReportLocationsError] with fixed locations,
+// and a [ReportLocationError] if location is set.
+type locationsError struct {
+	locations []*ReportLocation
+	location  *ReportLocation
+}
+
+func (e locationsError) Error() string {
+	return "failed"
+}
+
+func (e locationsError) ReportLocations() []*ReportLocation {
+	return e.locations
+}
+
+func (e locationsError) ReportLocation() *ReportLocation {
+	return e.location
+}
+
+func TestHandler_callerLocations(t *testing.T) {
+	first := &ReportLocation{FilePath: "a.go", LineNumber: 1, FunctionName: "a"}
+	second := &ReportLocation{FilePath: "b.go", LineNumber: 2, FunctionName: "b"}
+	third := &ReportLocation{FilePath: "c.go", LineNumber: 3, FunctionName: "c"}
+	tests := []struct {
+		name         string
+		err          error
+		wantLocation *ReportLocation
+		wantCallers  []*ReportLocation
+	}{
+		{
+			name:         "locations",
+			err:          locationsError{locations: []*ReportLocation{first, second, third}},
+			wantLocation: first,
+			wantCallers:  []*ReportLocation{second, third},
+		},
+		{
+			name:         "single location",
+			err:          fmt.Errorf("wrapped: %w", locationsError{locations: []*ReportLocation{first}}),
+			wantLocation: first,
+		},
+		{
+			name: "no locations",
+			err:  locationsError{},
+		},
+		{
+			name:         "report location takes precedence",
+			err:          locationsError{[]*ReportLocation{second, third}, first},
+			wantLocation: first,
+			wantCallers:  []*ReportLocation{third},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			slog.New(New(&buf)).Error("msg", ErrorKey, tt.err)
+
+			var got struct {
+				ReportLocation  *ReportLocation   `json:"reportLocation"`
+				CallerLocations []*ReportLocation `json:"callerLocations"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			if !reflect.DeepEqual(got.ReportLocation, tt.wantLocation) {
+				t.Errorf("%s = %v, want %v", ReportLocationKey, got.ReportLocation, tt.wantLocation)
+			}
+			if !reflect.DeepEqual(got.CallerLocations, tt.wantCallers) {
+				t.Errorf("%s = %v, want %v", CallerLocationsKey, got.CallerLocations, tt.wantCallers)
+			}
+		})
+	}
+}
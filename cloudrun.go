@@ -0,0 +1,178 @@
+package sloggcp
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+type loggerContextKey struct{}
+
+type traceContextKey struct{}
+
+// traceContext is the trace of a request, stored in its context.
+type traceContext struct {
+	traceID, spanID string
+	sampled         *bool
+	projectID       string // qualifies traceID, unless the handler has a project ID
+}
+
+type httpRequestContextKey struct{}
+
+// ContextWithLogger returns a copy of ctx carrying the logger,
+// to be retrieved by [LoggerFromContext].
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// LoggerFromContext returns the logger stored in ctx by [ContextWithLogger] or [CloudRunMiddleware],
+// or [slog.Default] if there is none.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// TraceFromContext is a [TraceExtractor] returning the trace stored in ctx by [RequestLogger] or [CloudRunMiddleware].
+func TraceFromContext(ctx context.Context) (traceID, spanID string, sampled *bool) {
+	t, _ := ctx.Value(traceContextKey{}).(traceContext)
+	return t.traceID, t.spanID, t.sampled
+}
+
+// RequestLogger returns a context and logger for an incoming request on Cloud Run,
+// or behind a Google Cloud load balancer, so the application logs are grouped
+// under the request log Cloud Run creates.
+//
+// The trace is read from the [CloudTraceContextHeader] ("X-Cloud-Trace-Context") set by Cloud Run,
+// see [ParseCloudTraceContext]. The returned context carries the trace, see [TraceFromContext],
+// and the returned logger, see [LoggerFromContext].
+// Every record written by the logger, or loggers derived from it, has the [TraceKey], [SpanIDKey] and,
+// if known, [TraceSampledKey] fields. The trace ID is qualified with projectID, if not empty,
+// as Cloud Logging requires for grouping. The first record also carries the
+// request metadata as [HTTPRequest] under [HTTPRequestKey], without status and latency.
+// Records logged with the returned context through another logger carry the trace as well.
+//
+// The fields are passed in the context of the records and written by the handlers of this package,
+// which read them in addition to their [TraceExtractor]. So they are always written at the top level,
+// even if logger has groups, and are not written by other handlers.
+//
+// The options configure the request metadata, see [NewHTTPRequest].
+//
+// Without valid trace header, the context and logger only carry the request metadata.
+func RequestLogger(r *http.Request, logger *slog.Logger, projectID string, options ...HTTPRequestOption) (context.Context, *slog.Logger) {
+	req := NewHTTPRequest(r, 0, 0, 0, options...)
+	return requestLogger(r, logger, projectID, &req)
+}
+
+// TraceLogger returns a context and logger for an incoming request like [RequestLogger],
+// without the request metadata. It suits middlewares which log the request themselves,
+// with [ContextWithHTTPRequest], once the response is known.
+func TraceLogger(r *http.Request, logger *slog.Logger, projectID string) (context.Context, *slog.Logger) {
+	return requestLogger(r, logger, projectID, nil)
+}
+
+// requestLogger implements RequestLogger and TraceLogger. request is written with the first record, if not nil.
+func requestLogger(r *http.Request, logger *slog.Logger, projectID string, request *HTTPRequest) (context.Context, *slog.Logger) {
+	ctx := r.Context()
+	h := &requestHandler{next: logger.Handler(), request: request, done: new(atomic.Bool)}
+	if t, ok := cloudTrace(r.Header.Get(CloudTraceContextHeader)); ok {
+		t.projectID = projectID
+		ctx = context.WithValue(ctx, traceContextKey{}, t)
+		h.trace = &t
+	}
+	reqLogger := slog.New(h)
+	return ContextWithLogger(ctx, reqLogger), reqLogger
+}
+
+// ContextWithHTTPRequest returns a copy of ctx carrying req. The handlers of this package
+// write it under [HTTPRequestKey] at the top level of the records logged with the context,
+// even if the logger has groups:
+//
+//	logger.InfoContext(sloggcp.ContextWithHTTPRequest(ctx, req), "request served")
+func ContextWithHTTPRequest(ctx context.Context, req HTTPRequest) context.Context {
+	return context.WithValue(ctx, httpRequestContextKey{}, &req)
+}
+
+// CloudRunMiddleware wraps next, so requests carry the context returned by [RequestLogger].
+// Handlers obtain the request logger with [LoggerFromContext]:
+//
+//	http.Handle("/", sloggcp.CloudRunMiddleware(logger, projectID, handler))
+//
+//	func handle(w http.ResponseWriter, r *http.Request) {
+//		sloggcp.LoggerFromContext(r.Context()).Info("handling request")
+//	}
+//
+// Behind a Google Cloud load balancer, pass [WithTrustedProxies](1) for the remote IP of the client.
+func CloudRunMiddleware(logger *slog.Logger, projectID string, next http.Handler, options ...HTTPRequestOption) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, _ := RequestLogger(r, logger, projectID, options...)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// cloudTrace parses the [CloudTraceContextHeader].
+// The sampling decision is only known if the header has the OPTIONS part.
+func cloudTrace(header string) (traceContext, bool) {
+	traceID, spanID, sampled, ok := ParseCloudTraceContext(header)
+	if !ok {
+		return traceContext{}, false
+	}
+	t := traceContext{traceID: traceID, spanID: spanID}
+	if strings.Contains(header, ";o=") {
+		t.sampled = &sampled
+	}
+	return t, true
+}
+
+// requestHandler passes the trace of a request, and the request metadata with the first record,
+// in the context of the records to next, see [RequestLogger].
+type requestHandler struct {
+	next    slog.Handler
+	trace   *traceContext // nil without trace
+	request *HTTPRequest  // written with the first record
+	done    *atomic.Bool  // the request was written, shared by derived handlers
+}
+
+// Enabled implements [slog.Handler].
+func (h *requestHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle implements [slog.Handler].
+func (h *requestHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.trace != nil {
+		ctx = context.WithValue(ctx, traceContextKey{}, *h.trace)
+	}
+	if h.request != nil && !h.done.Load() && h.done.CompareAndSwap(false, true) {
+		ctx = context.WithValue(ctx, httpRequestContextKey{}, h.request)
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs implements [slog.Handler].
+func (h *requestHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.next = h.next.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup implements [slog.Handler].
+func (h *requestHandler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.next = h.next.WithGroup(name)
+	return &h2
+}
+
+// setHTTPRequest adds the request metadata passed in ctx by the logger returned from [RequestLogger],
+// or stored by [ContextWithHTTPRequest], if any.
+func setHTTPRequest(ctx context.Context, out *object) {
+	if ctx == nil {
+		return
+	}
+	if req, ok := ctx.Value(httpRequestContextKey{}).(*HTTPRequest); ok {
+		out.add(HTTPRequestKey, slog.AnyValue(*req))
+	}
+}
@@ -0,0 +1,181 @@
+package sloggcp
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCloudRunMiddleware(t *testing.T) {
+	const traceID = "105445aa7843bc8bf206b12000100000"
+	tests := []struct {
+		name      string
+		header    string
+		projectID string
+		options   []Option
+		group     string // of the logger passed to the middleware
+		wantKey   string // top-level key of the attribute of the first record, if grouped
+		wantTrace map[string]any
+
+		forwardedFor   string
+		requestOptions []HTTPRequestOption
+		wantRemoteIP   string // "192.0.2.1" of httptest if empty
+	}{
+		{
+			name:      "sampled",
+			header:    traceID + "/74;o=1",
+			projectID: "my-project",
+			wantTrace: map[string]any{
+				TraceKey:        "projects/my-project/traces/" + traceID,
+				SpanIDKey:       "000000000000004a",
+				TraceSampledKey: true,
+			},
+		},
+		{
+			name:   "sampling unknown",
+			header: traceID + "/74",
+			wantTrace: map[string]any{
+				TraceKey:  traceID,
+				SpanIDKey: "000000000000004a",
+			},
+		},
+		{
+			name:      "no header",
+			wantTrace: map[string]any{},
+		},
+		{
+			name:      "grouped logger",
+			header:    traceID + "/74;o=1",
+			projectID: "my-project",
+			group:     "app",
+			wantKey:   "app",
+			wantTrace: map[string]any{
+				TraceKey:        "projects/my-project/traces/" + traceID,
+				SpanIDKey:       "000000000000004a",
+				TraceSampledKey: true,
+			},
+		},
+		{
+			name:    "grouped logger with separator",
+			header:  traceID + "/74",
+			options: []Option{WithGroupSeparator(".")},
+			group:   "app",
+			wantKey: "app.g.a",
+			wantTrace: map[string]any{
+				TraceKey:  traceID,
+				SpanIDKey: "000000000000004a",
+			},
+		},
+		{
+			name:      "handler project ID",
+			header:    traceID,
+			projectID: "other-project",
+			options:   []Option{WithProjectID("my-project")},
+			wantTrace: map[string]any{TraceKey: "projects/my-project/traces/" + traceID},
+		},
+		{
+			name:         "Cloud Run remote IP",
+			wantTrace:    map[string]any{},
+			forwardedFor: "198.51.100.1, 203.0.113.1",
+			wantRemoteIP: "203.0.113.1",
+		},
+		{
+			name:           "load balancer remote IP",
+			wantTrace:      map[string]any{},
+			forwardedFor:   "198.51.100.1, 203.0.113.1",
+			requestOptions: []HTTPRequestOption{WithTrustedProxies(1)},
+			wantRemoteIP:   "198.51.100.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(NewErrorReportingHandler(&buf, nil, tt.options...))
+			if tt.group != "" {
+				logger = logger.WithGroup(tt.group)
+			}
+			var gotTraceID string
+			h := CloudRunMiddleware(logger, tt.projectID, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotTraceID, _, _ = TraceFromContext(r.Context())
+				reqLogger := LoggerFromContext(r.Context())
+				reqLogger.WithGroup("g").Info("first", "a", 1)
+				reqLogger.Info("second")
+			}), tt.requestOptions...)
+			r := httptest.NewRequest(http.MethodGet, "http://example.com/path", nil)
+			if tt.header != "" {
+				r.Header.Set(CloudTraceContextHeader, tt.header)
+			}
+			if tt.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			wantRemoteIP := cmp.Or(tt.wantRemoteIP, "192.0.2.1")
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if want := tt.wantTrace[TraceKey]; want != nil && gotTraceID != traceID {
+				t.Errorf("TraceFromContext() = %s, want %s", gotTraceID, traceID)
+			}
+			dec := json.NewDecoder(&buf)
+			for i, wantRequest := range []bool{true, false} {
+				var got map[string]any
+				if err := dec.Decode(&got); err != nil {
+					t.Fatalf("Failed to decode log output %d: %v", i, err)
+				}
+				gotTrace := map[string]any{}
+				for _, key := range []string{TraceKey, SpanIDKey, TraceSampledKey} {
+					if v, ok := got[key]; ok {
+						gotTrace[key] = v
+					}
+				}
+				if !reflect.DeepEqual(gotTrace, tt.wantTrace) {
+					t.Errorf("record %d trace = %v, want %v", i, gotTrace, tt.wantTrace)
+				}
+				req, ok := got[HTTPRequestKey].(map[string]any)
+				if ok != wantRequest {
+					t.Fatalf("record %d httpRequest = %v, want present %v", i, got[HTTPRequestKey], wantRequest)
+				}
+				if ok && (req["requestMethod"] != http.MethodGet || req["requestUrl"] != "http://example.com/path" || req["remoteIp"] != wantRemoteIP) {
+					t.Errorf("record %d httpRequest = %v", i, req)
+				}
+				if _, ok := got[tt.wantKey]; tt.wantKey != "" && i == 0 && !ok {
+					t.Errorf("record %d = %v, want %s", i, got, tt.wantKey)
+				}
+			}
+		})
+	}
+}
+
+func TestLoggerFromContext_default(t *testing.T) {
+	if got := LoggerFromContext(t.Context()); got != slog.Default() {
+		t.Errorf("LoggerFromContext() = %v, want slog.Default()", got)
+	}
+}
+
+func TestTraceLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewErrorReportingHandler(&buf, nil)).WithGroup("g")
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/path", nil)
+	r.Header.Set(CloudTraceContextHeader, "105445aa7843bc8bf206b12000100000/74")
+	ctx, reqLogger := TraceLogger(r, logger, "my-project")
+	reqLogger.Info("first", "a", 1)
+	reqLogger.InfoContext(ContextWithHTTPRequest(ctx, NewHTTPRequest(r, http.StatusOK, 0, 0)), "served")
+
+	dec := json.NewDecoder(&buf)
+	for i, wantStatus := range []any{nil, float64(http.StatusOK)} {
+		var got map[string]any
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("Failed to decode log output %d: %v", i, err)
+		}
+		if want := "projects/my-project/traces/105445aa7843bc8bf206b12000100000"; got[TraceKey] != want {
+			t.Errorf("record %d %s = %v, want %v", i, TraceKey, got[TraceKey], want)
+		}
+		req, _ := got[HTTPRequestKey].(map[string]any)
+		if (req == nil) != (wantStatus == nil) || (req != nil && req["status"] != wantStatus) {
+			t.Errorf("record %d %s = %v, want status %v", i, HTTPRequestKey, got[HTTPRequestKey], wantStatus)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package sloggcp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// ContextAttrsFunc returns attributes from the context passed to the handler,
+// such as request scoped fields stored by middleware.
+type ContextAttrsFunc func(ctx context.Context) []slog.Attr
+
+// WithContextAttrs sets a function which provides attributes from the context of every record.
+// The attributes are added at the top level, regardless of groups opened with [slog.Logger.WithGroup].
+// They are added after the attributes from [slog.Logger.With] and before the record attributes,
+// so on key collision the record attributes take precedence.
+// Context attributes are processed like all other attributes,
+// including [slog.HandlerOptions.ReplaceAttr], labels and error reports.
+func WithContextAttrs(fn ContextAttrsFunc) Option {
+	return func(c *config) {
+		c.contextAttrs = fn
+	}
+}
+
+// WithContextValue adds the value stored in the context under ctxKey to every record,
+// as top-level string attribute with the key fieldName, such as a request ID set by middleware:
+//
+//	sloggcp.WithContextValue(requestIDKey{}, "requestId")
+//
+// Values which are not strings are formatted with [fmt.Sprint], so a UUID type is written by its String method.
+// When the context holds no value for ctxKey, the attribute is omitted.
+// It is a shorthand for the single value case of [WithContextAttrs], and can be passed multiple times.
+// The attributes are added before those of the ContextAttrsFunc, and are processed the same way.
+func WithContextValue(ctxKey any, fieldName string) Option {
+	return func(c *config) {
+		c.contextValues = append(c.contextValues, contextValue{ctxKey: ctxKey, fieldName: fieldName})
+	}
+}
+
+// contextValue is a context value added through [WithContextValue].
+type contextValue struct {
+	ctxKey    any
+	fieldName string
+}
+
+// contextValueAttrs returns the attributes of the values set through [WithContextValue] present in ctx.
+func (c *config) contextValueAttrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	var attrs []slog.Attr
+	for _, cv := range c.contextValues {
+		switch v := ctx.Value(cv.ctxKey).(type) {
+		case nil:
+		case string:
+			attrs = append(attrs, slog.String(cv.fieldName, v))
+		default:
+			attrs = append(attrs, slog.String(cv.fieldName, fmt.Sprint(v)))
+		}
+	}
+	return attrs
+}
+
+// addContextAttrs adds the attributes of the values set through [WithContextValue]
+// and returned by the [ContextAttrsFunc], if any, to the top-level object.
+func (s *encodeState) addContextAttrs(ctx context.Context, h *handler) {
+	if h.cfg.contextAttrs == nil && len(h.cfg.contextValues) == 0 {
+		return
+	}
+	attrs := h.cfg.contextValueAttrs(ctx)
+	if h.cfg.contextAttrs != nil {
+		attrs = append(attrs, h.cfg.contextAttrs(ctx)...)
+	}
+	if len(attrs) == 0 {
+		return
+	}
+	// Temporarily close the open groups, so the attributes are added to the top level.
+	groups, levels := s.groups, s.levels
+	s.groups, s.levels = nil, s.levels[:1]
+	for _, a := range attrs {
+		s.addAttr(h, a)
+	}
+	s.groups, s.levels = groups, levels
+}
+
+// ContextLevelFunc returns the minimum level for records logged with the context,
+// for example raised for tenants which opted out of verbose logging.
+type ContextLevelFunc func(ctx context.Context) slog.Level
+
+// WithContextLevel sets a function which raises the minimum level per context.
+// Records below the level returned for their context are discarded,
+// in addition to records below the level of the handler.
+// Return a level at or below the handler's level to not suppress anything.
+//
+// The function is called by Enabled, which slog calls with the context passed to the log call,
+// before the record is created. Records logged without context,
+// such as through [slog.Logger.Info] instead of [slog.Logger.InfoContext], get [context.Background].
+func WithContextLevel(fn ContextLevelFunc) Option {
+	return func(c *config) {
+		c.contextLevel = fn
+	}
+}
+
+// WithSuppressOnCancel discards records below minLevel which are logged with a cancelled context,
+// such as the debug logs of the remaining work of a request whose client went away.
+// Records at [LevelError] and above always pass, even if minLevel is higher.
+//
+// Like [WithContextLevel], the context is checked by Enabled, so suppressed records are never created.
+// Records logged without context are never suppressed.
+func WithSuppressOnCancel(minLevel slog.Level) Option {
+	return func(c *config) {
+		c.cancelLevel, c.cancelLevelSet = min(minLevel, LevelError), true
+	}
+}
+
+// suppressedOnCancel reports whether a record with level is discarded,
+// because ctx is cancelled, according to [WithSuppressOnCancel].
+func (c *config) suppressedOnCancel(ctx context.Context, level slog.Level) bool {
+	return c.cancelLevelSet && level < c.cancelLevel && ctx != nil && ctx.Err() != nil
+}
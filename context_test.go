@@ -0,0 +1,262 @@
+package sloggcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+type ctxAttrsKey struct{}
+
+func ctxAttrs(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(ctxAttrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+func TestWithContextAttrs(t *testing.T) {
+	tests := []struct {
+		name        string
+		ctxAttrs    []slog.Attr
+		replaceAttr func(groups []string, a slog.Attr) slog.Attr
+		logger      func(*slog.Logger) *slog.Logger
+		logAttrs    []any
+		want        map[string]any
+	}{
+		{
+			name:     "no context attrs",
+			logAttrs: []any{"a", 1},
+			want: map[string]any{
+				"a": float64(1),
+			},
+		},
+		{
+			name:     "context attrs",
+			ctxAttrs: []slog.Attr{slog.String("tenant", "acme"), slog.String("user", "u1")},
+			logAttrs: []any{"a", 1},
+			want: map[string]any{
+				"tenant": "acme",
+				"user":   "u1",
+				"a":      float64(1),
+			},
+		},
+		{
+			name:     "record attrs take precedence",
+			ctxAttrs: []slog.Attr{slog.String("tenant", "acme")},
+			logger: func(l *slog.Logger) *slog.Logger {
+				return l.With("user", "logger")
+			},
+			logAttrs: []any{"tenant", "record"},
+			want: map[string]any{
+				"tenant": "record",
+				"user":   "logger",
+			},
+		},
+		{
+			name:     "top level with group",
+			ctxAttrs: []slog.Attr{slog.String("tenant", "acme")},
+			logger: func(l *slog.Logger) *slog.Logger {
+				return l.WithGroup("g").With("b", 2)
+			},
+			logAttrs: []any{"a", 1},
+			want: map[string]any{
+				"tenant": "acme",
+				"g": map[string]any{
+					"a": float64(1),
+					"b": float64(2),
+				},
+			},
+		},
+		{
+			name:     "replace attr",
+			ctxAttrs: []slog.Attr{slog.String("tenant", "acme")},
+			replaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) == 0 && a.Key == "tenant" {
+					a.Key = "org"
+				}
+				return a
+			},
+			logger: func(l *slog.Logger) *slog.Logger {
+				return l.WithGroup("g")
+			},
+			want: map[string]any{
+				"org": "acme",
+			},
+		},
+		{
+			name:     "labels",
+			ctxAttrs: []slog.Attr{Labels(map[string]string{"tenant": "acme"})},
+			want: map[string]any{
+				LabelsKey: map[string]any{"tenant": "acme"},
+			},
+		},
+		{
+			name:     "error report",
+			ctxAttrs: []slog.Attr{slog.String(ErrorKey, "oops")},
+			want: map[string]any{
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				MessageKey:         "oops",
+				ErrorKey:           "oops",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := &slog.HandlerOptions{ReplaceAttr: tt.replaceAttr}
+			logger := slog.New(NewErrorReportingHandler(&buf, opts, WithContextAttrs(ctxAttrs)))
+			if tt.logger != nil {
+				logger = tt.logger(logger)
+			}
+			ctx := context.WithValue(context.Background(), ctxAttrsKey{}, tt.ctxAttrs)
+			logger.InfoContext(ctx, "", tt.logAttrs...)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			delete(got, TimeKey)
+			delete(got, SeverityKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type requestIDKey struct{}
+
+type requestID [2]byte
+
+func (id requestID) String() string {
+	return fmt.Sprintf("%x", id[:])
+}
+
+func TestWithContextValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		options []Option
+		want    map[string]any
+	}{
+		{
+			name: "absent",
+			want: map[string]any{},
+		},
+		{
+			name:  "string",
+			value: "req-1",
+			want:  map[string]any{"requestId": "req-1"},
+		},
+		{
+			name:  "stringer",
+			value: requestID{0xab, 0xcd},
+			want:  map[string]any{"requestId": "abcd"},
+		},
+		{
+			name:  "other type",
+			value: 42,
+			want:  map[string]any{"requestId": "42"},
+		},
+		{
+			name:    "with context attrs",
+			value:   "req-1",
+			options: []Option{WithContextAttrs(func(context.Context) []slog.Attr { return []slog.Attr{slog.String("tenant", "acme")} })},
+			want:    map[string]any{"requestId": "req-1", "tenant": "acme"},
+		},
+		{
+			name:    "context attrs take precedence",
+			value:   "req-1",
+			options: []Option{WithContextAttrs(func(context.Context) []slog.Attr { return []slog.Attr{slog.String("requestId", "attrs")} })},
+			want:    map[string]any{"requestId": "attrs"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			options := append([]Option{WithContextValue(requestIDKey{}, "requestId")}, tt.options...)
+			logger := slog.New(New(&buf, options...)).WithGroup("g")
+			ctx := context.Background()
+			if tt.value != nil {
+				ctx = context.WithValue(ctx, requestIDKey{}, tt.value)
+			}
+			logger.InfoContext(ctx, "")
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			delete(got, TimeKey)
+			delete(got, SeverityKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type ctxLevelKey struct{}
+
+func ctxLevel(ctx context.Context) slog.Level {
+	level, _ := ctx.Value(ctxLevelKey{}).(slog.Level)
+	return level // LevelInfo if unset
+}
+
+func TestWithContextLevel(t *testing.T) {
+	quiet := context.WithValue(context.Background(), ctxLevelKey{}, LevelWarning)
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		level slog.Level
+		want  bool
+	}{
+		{name: "default context, info", ctx: context.Background(), level: LevelInfo, want: true},
+		{name: "default context, debug below handler level", ctx: context.Background(), level: LevelDebug, want: false},
+		{name: "raised, info", ctx: quiet, level: LevelInfo, want: false},
+		{name: "raised, warning", ctx: quiet, level: LevelWarning, want: true},
+		{name: "raised, error", ctx: quiet, level: LevelError, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(NewErrorReportingHandler(&buf, nil, WithContextLevel(ctxLevel)))
+			logger.Log(tt.ctx, tt.level, "msg")
+			if got := buf.Len() > 0; got != tt.want {
+				t.Errorf("logged = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithSuppressOnCancel(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name     string
+		minLevel slog.Level
+		ctx      context.Context
+		level    slog.Level
+		want     bool
+	}{
+		{name: "active, info", minLevel: LevelWarning, ctx: context.Background(), level: LevelInfo, want: true},
+		{name: "cancelled, info", minLevel: LevelWarning, ctx: cancelled, level: LevelInfo, want: false},
+		{name: "cancelled, warning", minLevel: LevelWarning, ctx: cancelled, level: LevelWarning, want: true},
+		{name: "cancelled, error", minLevel: LevelWarning, ctx: cancelled, level: LevelError, want: true},
+		{name: "above error, cancelled, warning", minLevel: LevelEmergency, ctx: cancelled, level: LevelWarning, want: false},
+		{name: "above error, cancelled, error", minLevel: LevelEmergency, ctx: cancelled, level: LevelError, want: true},
+		{name: "above error, cancelled, critical", minLevel: LevelEmergency, ctx: cancelled, level: LevelCritical, want: true},
+		{name: "no context", minLevel: LevelWarning, level: LevelInfo, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := NewErrorReportingHandler(&buf, nil, WithSuppressOnCancel(tt.minLevel))
+			if got := h.Enabled(tt.ctx, tt.level); got != tt.want {
+				t.Errorf("Enabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package sloggcp
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// WithDefaultAttrs adds the attributes to every record, at the top level,
+// for example to stamp every entry with the service name and version.
+// They are processed once at construction, like attributes added with [slog.Logger.With],
+// and come before all other attributes, so attributes with the same key override them.
+// Unlike attributes added with With, they are part of the handler itself,
+// so every logger created from it with [slog.New] includes them.
+func WithDefaultAttrs(attrs ...slog.Attr) Option {
+	return func(c *config) {
+		c.defaultAttrs = append(c.defaultAttrs, slices.Clone(attrs)...)
+	}
+}
@@ -0,0 +1,78 @@
+package sloggcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestWithDefaultAttrs(t *testing.T) {
+	tests := []struct {
+		name   string
+		logger func(h slog.Handler) *slog.Logger
+		attrs  []any
+		want   map[string]any
+	}{
+		{
+			name:   "fresh logger",
+			logger: slog.New,
+			want:   map[string]any{"service": "svc", "version": "1.0"},
+		},
+		{
+			name: "group",
+			logger: func(h slog.Handler) *slog.Logger {
+				return slog.New(h).WithGroup("g")
+			},
+			attrs: []any{"a", 1},
+			want:  map[string]any{"service": "svc", "version": "1.0", "g": map[string]any{"a": 1.0}},
+		},
+		{
+			name: "overridden by With",
+			logger: func(h slog.Handler) *slog.Logger {
+				return slog.New(h).With("version", "2.0")
+			},
+			want: map[string]any{"service": "svc", "version": "2.0"},
+		},
+		{
+			name:   "overridden by record",
+			logger: slog.New,
+			attrs:  []any{"service", "other"},
+			want:   map[string]any{"service": "other", "version": "1.0"},
+		},
+		{
+			name:   "labels",
+			logger: slog.New,
+			attrs:  []any{Labels(map[string]string{"b": "2"})},
+			want: map[string]any{
+				"service": "svc", "version": "1.0",
+				LabelsKey: map[string]any{"a": "1", "b": "2"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := NewErrorReportingHandler(&buf, nil, WithDefaultAttrs(
+				slog.String("service", "svc"),
+				slog.String("version", "1.0"),
+				Labels(map[string]string{"a": "1"}),
+			))
+			tt.logger(h).Info("", tt.attrs...)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			delete(got, TimeKey)
+			delete(got, SeverityKey)
+			if _, ok := tt.want[LabelsKey]; !ok {
+				delete(got, LabelsKey)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package sloggcp
+
+import "strconv"
+
+// WithDuplicateKeys controls the output of attributes with duplicate keys.
+//
+// Within a JSON object, the last field with a key wins, and fields with the same key
+// that were added before it are dropped by default. Fields are added in the following order:
+//  1. Fields of the handler: time, source location, message, severity, trace and insert ID.
+//  2. Attributes added through [slog.Logger.With], in order.
+//  3. Attributes returned by the [ContextAttrsFunc] of [WithContextAttrs].
+//  4. Attributes of the record, in order.
+//  5. The labels, merged from all labels attributes.
+//  6. The fields of the error report, including the error attribute itself.
+//
+// So an attribute of the record overrides the attribute of the logger with the same key,
+// and the error report overrides attributes with the keys "@type", "message", "reportLocation" and "serviceContext".
+// Of multiple top-level error attributes with the same key, the last one is reported.
+// The members of groups follow the same rule.
+//
+// When keep is true, overridden attribute values are kept under the key
+// followed by "#" and a sequence number, starting at 1 for the first one,
+// like "component#1". The last value is still written under the key itself.
+// Overridden fields of the handler, such as the log message replaced by the error message, are dropped.
+func WithDuplicateKeys(keep bool) Option {
+	return func(c *config) {
+		c.keepDuplicateKeys = keep
+	}
+}
+
+// duplicates tracks the fields of a run of duplicate keys
+// while the sorted fields of an object are written.
+type duplicates struct {
+	n int // number of overridden fields kept for the current key
+}
+
+// key returns the key to write a field with,
+// or false if the field is omitted.
+// attr reports whether the field is an attribute,
+// overridden whether it is followed by a field with the same key.
+func (d *duplicates) key(c *config, key string, attr, overridden bool) (string, bool) {
+	if !overridden {
+		d.n = 0
+		return key, true
+	}
+	if !c.keepDuplicateKeys || !attr {
+		return "", false
+	}
+	d.n++
+	return key + "#" + strconv.Itoa(d.n), true
+}
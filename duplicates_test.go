@@ -0,0 +1,152 @@
+package sloggcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestWithDuplicateKeys(t *testing.T) {
+	type args struct {
+		with  []any
+		msg   string
+		attrs []any
+	}
+	tests := []struct {
+		name string
+		keep bool
+		args args
+		want map[string]any
+	}{
+		{
+			name: "record overrides logger",
+			args: args{
+				with:  []any{"component", "default"},
+				attrs: []any{"component", "specific"},
+			},
+			want: map[string]any{"component": "specific"},
+		},
+		{
+			name: "last record attribute wins",
+			args: args{
+				attrs: []any{"a", 1, "a", 2, "a", 3},
+			},
+			want: map[string]any{"a": 3.0},
+		},
+		{
+			name: "attribute overrides handler field",
+			args: args{
+				msg:   "log message",
+				attrs: []any{MessageKey, "attribute"},
+			},
+			want: map[string]any{MessageKey: "attribute"},
+		},
+		{
+			name: "error report overrides attribute",
+			args: args{
+				msg:   "log message",
+				with:  []any{MessageKey, "logger"},
+				attrs: []any{ErrorKey, errors.New("oops"), MessageKey, "record"},
+			},
+			want: map[string]any{
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				ErrorKey:           "oops",
+				MessageKey:         "oops",
+			},
+		},
+		{
+			name: "last error wins",
+			args: args{
+				with:  []any{ErrorKey, errors.New("first")},
+				attrs: []any{ErrorKey, errors.New("second")},
+			},
+			want: map[string]any{
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				ErrorKey:           "second",
+				MessageKey:         "second",
+			},
+		},
+		{
+			name: "group members",
+			args: args{
+				attrs: []any{slog.Group("g", "a", 1, "a", 2)},
+			},
+			want: map[string]any{"g": map[string]any{"a": 2.0}},
+		},
+		{
+			name: "keep record overrides logger",
+			keep: true,
+			args: args{
+				with:  []any{"component", "default"},
+				attrs: []any{"component", "specific"},
+			},
+			want: map[string]any{"component": "specific", "component#1": "default"},
+		},
+		{
+			name: "keep record attributes",
+			keep: true,
+			args: args{
+				attrs: []any{"a", 1, "b", 1, "a", 2, "a", 3},
+			},
+			want: map[string]any{"a": 3.0, "a#1": 1.0, "a#2": 2.0, "b": 1.0},
+		},
+		{
+			name: "keep attribute overridden by error report",
+			keep: true,
+			args: args{
+				msg:   "log message",
+				with:  []any{MessageKey, "logger"},
+				attrs: []any{ErrorKey, errors.New("oops"), MessageKey, "record"},
+			},
+			want: map[string]any{
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				ErrorKey:           "oops",
+				MessageKey:         "oops",
+				MessageKey + "#1":  "logger",
+				MessageKey + "#2":  "record",
+			},
+		},
+		{
+			name: "keep errors",
+			keep: true,
+			args: args{
+				with:  []any{ErrorKey, errors.New("first")},
+				attrs: []any{ErrorKey, errors.New("second")},
+			},
+			want: map[string]any{
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				ErrorKey:           "second",
+				ErrorKey + "#1":    map[string]any{}, // encoded with json.Marshal, like other attributes added with With
+				MessageKey:         "second",
+			},
+		},
+		{
+			name: "keep group members",
+			keep: true,
+			args: args{
+				attrs: []any{slog.Group("g", "a", 1, "a", 2)},
+			},
+			want: map[string]any{"g": map[string]any{"a": 2.0, "a#1": 1.0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := NewErrorReportingHandler(&buf, nil, WithDuplicateKeys(tt.keep))
+			slog.New(h).With(tt.args.with...).Error(tt.args.msg, tt.args.attrs...)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			delete(got, TimeKey)
+			delete(got, SeverityKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,707 @@
+package sloggcp
+
+import (
+	"cmp"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// field is a member of a JSON object under construction.
+type field struct {
+	key     string
+	value   slog.Value
+	raw     []byte // pre-encoded value, if not nil
+	nested  bool   // value is the object of the next level
+	attr    bool   // value is from an attribute, subject to the value options such as WithMaxValueBytes
+	ordered bool   // members of group values keep their order, see WithOrderedErrorValue
+	with    bool   // value is from an attribute added with WithAttrs, see position.with
+	payload bool   // value is the object of the top-level attributes, see WithPayloadKey
+}
+
+// object is a JSON object under construction.
+// Fields are written sorted by key. When keys are duplicated, the last added field wins.
+// This is the same output as encoding a map with [json.Marshal], unless [WithDuplicateKeys] is enabled.
+type object struct {
+	fields []field
+}
+
+func (o *object) add(key string, value slog.Value) {
+	o.fields = append(o.fields, field{key: key, value: value})
+}
+
+// addAttr adds a value from an attribute.
+func (o *object) addAttr(key string, value slog.Value) {
+	o.fields = append(o.fields, field{key: key, value: value, attr: true})
+}
+
+// addJSON adds a value which is encoded using [json.Marshal],
+// bypassing the attribute value rules.
+func (o *object) addJSON(key string, v any) {
+	o.add(key, slog.AnyValue(jsonValue{v}))
+}
+
+// jsonValue wraps values that must be encoded with [json.Marshal],
+// even if they implement [slog.LogValuer], [error] or [fmt.Stringer].
+type jsonValue struct {
+	v any
+}
+
+// encodeState holds the scratch space for encoding a single record.
+// It is reused through encodeStatePool.
+type encodeState struct {
+	buf    []byte
+	levels []object // top-level object followed by the nested objects of each open group
+	groups []string
+
+	labels      map[string]string
+	labelsOwned bool // labels may be modified
+
+	errorAttr  slog.Attr // the top-level error attribute, if any
+	errorFound bool
+	errorIndex int  // index of errorAttr.Key in the configured error keys
+	errorGroup bool // errorAttr is part of a group
+	errorWith  bool // errorAttr was added with WithAttrs
+}
+
+// prepared is the immutable state of a handler derived with WithAttrs or WithGroup.
+// Attributes are already processed and their values encoded.
+type prepared struct {
+	levels [][]field // top-level fields followed by the fields of each group
+	groups []string
+	labels map[string]string
+	// errorAttr is the top-level error attribute, if errorFound.
+	errorAttr  slog.Attr
+	errorFound bool
+	errorIndex int
+	errorGroup bool
+	// lastNonEmpty is the index of the deepest level with fields
+	// other than the nested group.
+	lastNonEmpty int
+}
+
+var encodeStatePool = sync.Pool{
+	New: func() any {
+		return &encodeState{
+			buf:    make([]byte, 0, 1024),
+			levels: make([]object, 1, 4),
+		}
+	},
+}
+
+func newEncodeState() *encodeState {
+	s := encodeStatePool.Get().(*encodeState)
+	s.levels = s.levels[:1]
+	s.levels[0].fields = s.levels[0].fields[:0]
+	return s
+}
+
+// free returns s to the pool.
+// Large buffers are dropped, to prevent the pool from holding on to excessive memory.
+func (s *encodeState) free() {
+	const maxBufferSize = 64 << 10
+	if cap(s.buf) > maxBufferSize {
+		return
+	}
+	s.buf = s.buf[:0]
+	s.groups = s.groups[:0]
+	s.labels, s.labelsOwned = nil, false
+	s.errorAttr, s.errorFound, s.errorIndex, s.errorGroup, s.errorWith = slog.Attr{}, false, 0, false, false
+	for i := range s.levels {
+		clear(s.levels[i].fields) // release references to values
+	}
+	encodeStatePool.Put(s)
+}
+
+// load appends the fields of the prepared state to the objects of s.
+// When omitEmpty is true, trailing groups without fields are left out.
+func (s *encodeState) load(p *prepared, omitEmpty bool) {
+	levels := p.levels
+	if omitEmpty {
+		levels = levels[:p.lastNonEmpty+1]
+	}
+	for i, fields := range levels {
+		if i > 0 {
+			s.pushLevel()
+		}
+		if omitEmpty && i == len(levels)-1 && i < len(p.levels)-1 {
+			fields = fields[:len(fields)-1] // remove the nested group
+		}
+		s.current().fields = append(s.current().fields, fields...)
+	}
+	// Flattened groups have no level, see [WithGroupSeparator].
+	s.groups = append(s.groups, p.groups[:len(p.groups)-(len(p.levels)-len(levels))]...)
+	s.labels, s.labelsOwned = p.labels, false
+	s.errorAttr, s.errorFound, s.errorIndex, s.errorGroup = p.errorAttr, p.errorFound, p.errorIndex, p.errorGroup
+	s.errorWith = p.errorFound
+}
+
+// prepare returns a copy of the state, with all values encoded.
+// The attributes are marked as added with WithAttrs, see [position].
+func (s *encodeState) prepare(c *config) *prepared {
+	p := &prepared{
+		levels:     make([][]field, len(s.levels)),
+		groups:     slices.Clone(s.groups),
+		labels:     s.labels,
+		errorAttr:  s.errorAttr,
+		errorFound: s.errorFound,
+		errorIndex: s.errorIndex,
+		errorGroup: s.errorGroup,
+	}
+	for i, o := range s.levels {
+		fields := slices.Clone(o.fields)
+		for j, f := range fields {
+			if f.nested || f.raw != nil {
+				continue
+			}
+			f.with = f.attr
+			fields[j] = f
+			// On error, the value is kept, so the error is returned from Handle.
+			if raw, err := f.appendValue(c, nil, position{level: i, groups: s.groups[:i]}); err == nil {
+				fields[j] = field{key: f.key, raw: raw, attr: f.attr}
+			}
+		}
+		p.levels[i] = fields
+		if len(fields) > 1 || (len(fields) == 1 && !fields[0].nested) {
+			p.lastNonEmpty = i
+		}
+	}
+	return p
+}
+
+// addAttr processes a single attribute for the current group.
+// The attribute is passed to [slog.HandlerOptions.ReplaceAttr] first.
+// Special GCP attributes, such as labels, operations and errors, are extracted to the top level.
+func (s *encodeState) addAttr(h *handler, a slog.Attr) {
+	if a.Key == MinLevelKey {
+		return // see MinLevel
+	}
+	a = h.replaceAttr(s.groups, a)
+	if a.Key == "" {
+		// Like slog specifies, attributes without key are dropped
+		// and the members of groups without key are inlined.
+		if v := a.Value.Resolve(); v.Kind() == slog.KindGroup {
+			for _, member := range v.Group() {
+				s.addAttr(h, member)
+			}
+		}
+		return
+	}
+	a, ok := h.cfg.redact(s.groups, a)
+	if !ok {
+		return
+	}
+	if a.Key == LabelsKey {
+		s.mergeLabels(a.Value)
+		return
+	}
+	if op, ok := operationFromAttr(a); ok {
+		s.top().addJSON(OperationKey, op)
+		return
+	}
+	if i := slices.Index(h.cfg.errorKeys, a.Key); i >= 0 {
+		if s.setErrorAttr(h, a, i, len(s.groups) > 0) {
+			return
+		}
+	}
+	if h.cfg.groupedErrors && a.Value.Kind() == slog.KindGroup {
+		s.findGroupedError(h, a.Value.Group())
+	}
+	if h.cfg.groupSeparator != "" {
+		s.addFlatAttr(h, s.groups, a)
+		return
+	}
+	s.current().addAttr(a.Key, a.Value)
+}
+
+// findGroupedError searches the attributes of a group value for error attributes.
+func (s *encodeState) findGroupedError(h *handler, attrs []slog.Attr) {
+	for _, a := range attrs {
+		if i := slices.Index(h.cfg.errorKeys, a.Key); i >= 0 {
+			s.setErrorAttr(h, a, i, true)
+		} else if a.Value.Kind() == slog.KindGroup {
+			s.findGroupedError(h, a.Value.Group())
+		}
+	}
+}
+
+// setErrorAttr keeps a as the error attribute to create the report from in Handle,
+// if it takes precedence over the current one. i is the index of a.Key in the configured error keys.
+// Reports are created from top-level attributes, with the first configured key.
+// For repeated keys, the last attribute is kept. The previous one is dropped,
+// unless [WithDuplicateKeys] is enabled.
+// Attributes with other error keys are added as regular attributes.
+// When [WithGroupedErrors] is enabled, an error in a group is used
+// if there is no top-level error. It remains part of its group.
+// It reports whether a was consumed.
+func (s *encodeState) setErrorAttr(h *handler, a slog.Attr, i int, grouped bool) bool {
+	if grouped {
+		if h.cfg.groupedErrors && (!s.errorFound || (s.errorGroup && i <= s.errorIndex)) {
+			s.errorAttr, s.errorFound, s.errorIndex, s.errorGroup = a, true, i, true
+		}
+		return false
+	}
+	switch {
+	case !s.errorFound || s.errorGroup || i == s.errorIndex:
+		if s.errorFound && !s.errorGroup && h.cfg.keepDuplicateKeys {
+			s.keepErrorAttr()
+		}
+		s.errorAttr, s.errorFound, s.errorIndex, s.errorGroup, s.errorWith = a, true, i, false, false
+		return true
+	case i < s.errorIndex:
+		s.keepErrorAttr()
+		s.errorAttr, s.errorIndex, s.errorWith = a, i, false
+		return true
+	}
+	return false
+}
+
+// keepErrorAttr adds the replaced error attribute as regular top-level attribute.
+func (s *encodeState) keepErrorAttr() {
+	out := s.top()
+	out.fields = append(out.fields, field{key: s.errorAttr.Key, value: s.errorAttr.Value, attr: true, with: s.errorWith})
+}
+
+// top returns the top-level object.
+func (s *encodeState) top() *object {
+	return &s.levels[0]
+}
+
+// current returns the object of the innermost open group.
+func (s *encodeState) current() *object {
+	return &s.levels[len(s.levels)-1]
+}
+
+// openGroup adds a nested object to the current object
+// and makes it the current one.
+// When flat is true, see [WithGroupSeparator], only the group key is tracked.
+func (s *encodeState) openGroup(name string, flat bool) {
+	if flat {
+		s.groups = append(s.groups, name)
+		return
+	}
+	cur := s.current()
+	cur.fields = append(cur.fields, field{key: name, nested: true})
+	s.groups = append(s.groups, name)
+	s.pushLevel()
+}
+
+// pushLevel adds an empty object level, reusing allocated space.
+func (s *encodeState) pushLevel() {
+	if len(s.levels) < cap(s.levels) {
+		s.levels = s.levels[:len(s.levels)+1]
+		s.levels[len(s.levels)-1].fields = s.levels[len(s.levels)-1].fields[:0]
+		return
+	}
+	s.levels = append(s.levels, object{})
+}
+
+// encode writes the top-level object, followed by a newline, to the buffer.
+func (s *encodeState) encode(c *config) (err error) {
+	s.buf, err = s.appendObject(c, s.buf, 0)
+	if err != nil {
+		return err
+	}
+	s.buf = append(s.buf, '\n')
+	return nil
+}
+
+// appendObject writes the object of the level.
+// With [WithPayloadKey], the top-level attributes are moved to the payload object.
+func (s *encodeState) appendObject(c *config, buf []byte, level int) ([]byte, error) {
+	fields := s.levels[level].fields
+	compare := cmp.Compare[string]
+	if level > 0 {
+		slices.SortStableFunc(fields, func(a, b field) int {
+			return compare(a.key, b.key)
+		})
+		return s.appendFields(c, buf, fields, level, nil)
+	}
+
+	if c.gcpFieldsFirst {
+		compare = compareTopLevelKeys
+	}
+	if c.payloadKey != "" {
+		fields = append(fields, field{key: c.payloadKey, payload: true})
+		s.levels[0].fields = fields
+	}
+	// The payload fields are sorted by key after the top-level fields.
+	slices.SortStableFunc(fields, func(a, b field) int {
+		if r := cmp.Compare(c.payloadRank(a), c.payloadRank(b)); r != 0 || c.payloadRank(a) > 0 {
+			return cmp.Or(r, cmp.Compare(a.key, b.key))
+		}
+		return compare(a.key, b.key)
+	})
+	n := len(fields)
+	if i := slices.IndexFunc(fields, func(f field) bool { return c.payloadRank(f) > 0 }); i >= 0 {
+		n = i
+	}
+	return s.appendFields(c, buf, fields[:n], level, fields[n:])
+}
+
+// appendFields writes the sorted fields as object.
+// The payload fields are written as object in place of the field marked as payload, if any.
+func (s *encodeState) appendFields(c *config, buf []byte, fields []field, level int, payload []field) (_ []byte, err error) {
+	buf = append(buf, '{')
+	first := true
+	var dup duplicates
+	for i, f := range fields {
+		key, ok := dup.key(c, f.key, f.attr, i+1 < len(fields) && fields[i+1].key == f.key)
+		if !ok || (f.payload && len(payload) == 0) {
+			continue
+		}
+		if !first {
+			buf = append(buf, ',')
+		}
+		first = false
+		buf = appendString(buf, key)
+		buf = append(buf, ':')
+		switch {
+		case f.payload:
+			buf, err = s.appendFields(c, buf, payload, level, nil)
+		case f.nested:
+			buf, err = s.appendObject(c, buf, level+1)
+		case f.raw != nil:
+			buf = append(buf, f.raw...)
+		default:
+			buf, err = f.appendValue(c, buf, position{level: level, groups: s.groups[:level]})
+		}
+		if err != nil {
+			return buf, err
+		}
+	}
+	return append(buf, '}'), nil
+}
+
+// handlerConfig is used to encode the values the handler adds itself,
+// which are not subject to the value options.
+var handlerConfig = new(config)
+
+// position is the location of a value in the output.
+type position struct {
+	level   int      // nesting level of the object containing the value, where the top-level object is 0
+	groups  []string // keys of the enclosing groups, only tracked for nested groups when needed by a hook
+	ordered bool     // members of groups keep their order instead of being sorted by key
+	// with is set for the value of an attribute added with WithAttrs.
+	// Unless it is a group, or a [slog.LogValuer] resolving to one,
+	// errors and Stringers are encoded with json.Marshal, and durations as nanoseconds by default.
+	// The members of groups are encoded like the values of record attributes.
+	with bool
+}
+
+// enter returns the position of the members of the group value with key.
+func (p position) enter(c *config, key string) position {
+	p.level++
+	p.with = false
+	if c.replaceAttr != nil || c.redactor != nil {
+		p.groups = append(slices.Clip(p.groups), key)
+	}
+	return p
+}
+
+// appendValue encodes the value of the field.
+func (f *field) appendValue(c *config, buf []byte, pos position) ([]byte, error) {
+	if !f.attr {
+		c = handlerConfig
+	}
+	pos.ordered = pos.ordered || f.ordered
+	pos.with = f.with
+	return c.appendValue(buf, f.key, f.value, pos)
+}
+
+// encodeErrorMarker prefixes the error which replaces a value that cannot be encoded,
+// like the handlers of the slog package do.
+const encodeErrorMarker = "!ERROR:"
+
+// appendValue encodes an attribute value according to
+// the rules documented on [NewErrorReportingHandler].
+// Strings are truncated according to [WithMaxValueBytes].
+// Groups nested deeper than allowed by [WithMaxDepth] are replaced by a marker.
+// The members of groups are passed to [slog.HandlerOptions.ReplaceAttr] and the [Redactor].
+// A value which cannot be encoded, such as a channel or a failing MarshalJSON method,
+// is replaced by a string with the error, so the rest of the entry is still written.
+// The error is passed to the function set through [WithErrorHandler].
+func (c *config) appendValue(buf []byte, key string, v slog.Value, pos position) ([]byte, error) {
+	out, err := c.encodeValue(buf, key, v, pos)
+	if err != nil {
+		c.handleError(fmt.Errorf("sloggcp handler: encode %q: %w", key, err))
+		return appendString(buf, encodeErrorMarker+err.Error()), nil
+	}
+	return out, nil
+}
+
+// encodeValue implements appendValue, returning encoding errors.
+func (c *config) encodeValue(buf []byte, key string, v slog.Value, pos position) ([]byte, error) {
+	v = v.Resolve()
+	if c.typeEncoders != nil {
+		v = c.encodeType(v)
+	}
+	switch v.Kind() {
+	case slog.KindGroup:
+		if c.maxDepth > 0 && pos.level >= c.maxDepth {
+			return appendString(buf, maxDepthMarker), nil
+		}
+		return c.appendGroup(buf, v.Group(), pos.enter(c, key))
+	case slog.KindString:
+		return appendString(buf, c.truncate(v.String())), nil
+	case slog.KindInt64:
+		return strconv.AppendInt(buf, v.Int64(), 10), nil
+	case slog.KindUint64:
+		return strconv.AppendUint(buf, v.Uint64(), 10), nil
+	case slog.KindFloat64:
+		return appendFloat(buf, v.Float64())
+	case slog.KindBool:
+		return strconv.AppendBool(buf, v.Bool()), nil
+	case slog.KindDuration:
+		return c.appendDuration(buf, v.Duration(), pos.with)
+	case slog.KindTime:
+		return appendTime(buf, v.Time())
+	}
+
+	switch tv := v.Any().(type) {
+	case jsonValue:
+		return appendJSON(buf, tv.v)
+	case slog.Value:
+		return c.encodeValue(buf, key, tv, pos)
+	case *slog.Value:
+		if tv == nil {
+			return append(buf, "null"...), nil
+		}
+		return c.encodeValue(buf, key, *tv, pos)
+	case []slog.Value:
+		return c.appendValues(buf, key, tv, pos)
+	case json.RawMessage:
+		return c.appendRawMessage(buf, tv), nil
+	case json.Marshaler, encoding.TextMarshaler:
+		return c.appendMarshaled(buf, tv)
+	case error:
+		if pos.with {
+			return c.appendMarshaled(buf, tv)
+		}
+		return appendString(buf, c.truncate(tv.Error())), nil
+	case fmt.Stringer:
+		if pos.with {
+			return c.appendMarshaled(buf, tv)
+		}
+		return appendString(buf, c.truncate(tv.String())), nil
+	case []byte:
+		return c.appendBytes(buf, tv), nil
+	default:
+		return c.appendMarshaled(buf, tv)
+	}
+}
+
+// appendValues encodes values, wrapped by an attribute value, as JSON array.
+// Like [slog.AnyValue] unwraps a [slog.Value], the elements are encoded as attribute values,
+// instead of passing the internals of slog.Value to the marshaler.
+func (c *config) appendValues(buf []byte, key string, values []slog.Value, pos position) (_ []byte, err error) {
+	buf = append(buf, '[')
+	for i, v := range values {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		if buf, err = c.appendValue(buf, key, v, pos); err != nil {
+			return buf, err
+		}
+	}
+	return append(buf, ']'), nil
+}
+
+// attrSlicePool holds scratch slices for sorting group attributes.
+var attrSlicePool = sync.Pool{
+	New: func() any {
+		s := make([]slog.Attr, 0, 8)
+		return &s
+	},
+}
+
+// appendGroup encodes the attributes as JSON object,
+// sorted by key, where the last of duplicate keys wins, see [WithDuplicateKeys].
+// pos is the position of the members. If pos is ordered, the members keep their order,
+// with duplicate keys moved to the first attribute with the key.
+func (c *config) appendGroup(buf []byte, attrs []slog.Attr, pos position) (_ []byte, err error) {
+	if len(attrs) > 1 || c.replaceAttr != nil || c.redactor != nil || (len(attrs) == 1 && attrs[0].Key == "") {
+		scratch := attrSlicePool.Get().(*[]slog.Attr)
+		defer func() {
+			clear(*scratch) // release references to values
+			*scratch = (*scratch)[:0]
+			attrSlicePool.Put(scratch)
+		}()
+		*scratch = c.appendMembers(*scratch, attrs, pos.groups)
+		attrs = *scratch
+		if pos.ordered {
+			attrs = groupDuplicates(attrs)
+		} else {
+			slices.SortStableFunc(attrs, func(a, b slog.Attr) int {
+				return cmp.Compare(a.Key, b.Key)
+			})
+		}
+	}
+	buf = append(buf, '{')
+	first := true
+	var dup duplicates
+	for i, a := range attrs {
+		key, ok := dup.key(c, a.Key, true, i+1 < len(attrs) && attrs[i+1].Key == a.Key)
+		if !ok {
+			continue
+		}
+		if !first {
+			buf = append(buf, ',')
+		}
+		first = false
+		buf = appendString(buf, key)
+		buf = append(buf, ':')
+		if buf, err = c.appendValue(buf, a.Key, a.Value, pos); err != nil {
+			return buf, err
+		}
+	}
+	return append(buf, '}'), nil
+}
+
+// groupDuplicates moves attributes with duplicate keys right after the first attribute with the key,
+// keeping their relative order, so the rules of [WithDuplicateKeys] apply like to sorted attributes.
+// attrs is returned as is, if no key is duplicated.
+func groupDuplicates(attrs []slog.Attr) []slog.Attr {
+	duplicated := false
+	for i := 1; i < len(attrs) && !duplicated; i++ {
+		duplicated = slices.ContainsFunc(attrs[:i], func(a slog.Attr) bool { return a.Key == attrs[i].Key })
+	}
+	if !duplicated {
+		return attrs
+	}
+	grouped := make([]slog.Attr, 0, len(attrs))
+	for i, a := range attrs {
+		if slices.ContainsFunc(attrs[:i], func(b slog.Attr) bool { return b.Key == a.Key }) {
+			continue // added with the first attribute with the key
+		}
+		for _, b := range attrs[i:] {
+			if b.Key == a.Key {
+				grouped = append(grouped, b)
+			}
+		}
+	}
+	return grouped
+}
+
+// appendMembers appends the members of a group to dst,
+// after passing them to [slog.HandlerOptions.ReplaceAttr] and the [Redactor].
+// Like slog specifies, members without key are dropped and the members of groups without key are inlined.
+func (c *config) appendMembers(dst, attrs []slog.Attr, groups []string) []slog.Attr {
+	for _, a := range attrs {
+		if c.replaceAttr != nil {
+			a = c.replaceAttr(groups, a)
+		}
+		if a.Key == "" {
+			if v := a.Value.Resolve(); v.Kind() == slog.KindGroup {
+				dst = c.appendMembers(dst, v.Group(), groups)
+			}
+			continue
+		}
+		if a, ok := c.redact(groups, a); ok {
+			dst = append(dst, a)
+		}
+	}
+	return dst
+}
+
+func appendJSON(buf []byte, v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, data...), nil
+}
+
+// appendTime encodes t the same way as [time.Time.MarshalJSON].
+func appendTime(buf []byte, t time.Time) ([]byte, error) {
+	if y := t.Year(); y < 0 || y >= 10000 {
+		return appendJSON(buf, t) // returns the error
+	}
+	buf = append(buf, '"')
+	buf = t.AppendFormat(buf, time.RFC3339Nano)
+	return append(buf, '"'), nil
+}
+
+// appendFloat encodes f the same way as [json.Marshal].
+func appendFloat(buf []byte, f float64) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return appendJSON(buf, f) // returns the error
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	buf = strconv.AppendFloat(buf, f, format, -1, 64)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(buf)
+		if n >= 4 && buf[n-4] == 'e' && buf[n-3] == '-' && buf[n-2] == '0' {
+			buf[n-2] = buf[n-1]
+			buf = buf[:n-1]
+		}
+	}
+	return buf, nil
+}
+
+// appendString encodes s as JSON string, the same way as [json.Marshal],
+// including the escaping of HTML characters.
+func appendString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				buf = append(buf, '\\', b)
+			case '\b':
+				buf = append(buf, '\\', 'b')
+			case '\f':
+				buf = append(buf, '\\', 'f')
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				// Control characters and HTML characters <, >, &.
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 is LINE SEPARATOR and U+2029 is PARAGRAPH SEPARATOR.
+		// They are escaped for JSONP compatibility, like encoding/json does.
+		if c == '\u2028' || c == '\u2029' {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', hexDigits[c&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
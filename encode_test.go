@@ -0,0 +1,322 @@
+package sloggcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_appendString(t *testing.T) {
+	tests := []string{
+		"",
+		"simple",
+		`quote " and backslash \`,
+		"control \b\f\n\r\t\x00\x1f",
+		"html <script>&</script>",
+		"unicode äöü 世界 🙂",
+		"invalid \xff utf8",
+		"separators \u2028 \u2029",
+	}
+	for _, s := range tests {
+		t.Run(s, func(t *testing.T) {
+			want, err := json.Marshal(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := appendString(nil, s); string(got) != string(want) {
+				t.Errorf("appendString() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func Fuzz_appendString(f *testing.F) {
+	f.Add("simple")
+	f.Add("html <script>&</script>\n")
+	f.Add("invalid \xff utf8  ")
+	f.Fuzz(func(t *testing.T, s string) {
+		want, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := appendString(nil, s); string(got) != string(want) {
+			t.Errorf("appendString() = %s, want %s", got, want)
+		}
+	})
+}
+
+func Test_appendFloat(t *testing.T) {
+	tests := []float64{0, 1, -1, 0.1, 1.5e-7, -1e-7, 123456789.123, 1e20, 1e21, -1e21, 1e300, math.SmallestNonzeroFloat64}
+	for _, f := range tests {
+		want, err := json.Marshal(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := appendFloat(nil, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("appendFloat(%v) = %s, want %s", f, got, want)
+		}
+	}
+	if _, err := appendFloat(nil, math.NaN()); err == nil {
+		t.Error("appendFloat(NaN) error = nil, want error")
+	}
+}
+
+func Test_appendValue(t *testing.T) {
+	group := slog.GroupValue(slog.String("a", "a"), slog.Int("b", 1))
+	tests := []struct {
+		name  string
+		value slog.Value
+		want  string
+	}{
+		{
+			name:  "string",
+			value: slog.StringValue("foo"),
+			want:  `"foo"`,
+		},
+		{
+			name:  "int",
+			value: slog.IntValue(-42),
+			want:  `-42`,
+		},
+		{
+			name:  "uint",
+			value: slog.Uint64Value(math.MaxUint64),
+			want:  `18446744073709551615`,
+		},
+		{
+			name:  "float",
+			value: slog.Float64Value(1.5),
+			want:  `1.5`,
+		},
+		{
+			name:  "bool",
+			value: slog.BoolValue(true),
+			want:  `true`,
+		},
+		{
+			name:  "duration",
+			value: slog.DurationValue(time.Second),
+			want:  `"1s"`,
+		},
+		{
+			name:  "time",
+			value: slog.TimeValue(time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)),
+			want:  `"2020-01-02T03:04:05.000000006Z"`,
+		},
+		{
+			name: "group sorted, last wins",
+			value: slog.GroupValue(
+				slog.String("b", "first"),
+				slog.String("a", "a"),
+				slog.String("b", "second"),
+			),
+			want: `{"a":"a","b":"second"}`,
+		},
+		{
+			name:  "LogValuer",
+			value: slog.AnyValue(groupTypeTest),
+			want:  `{"bar":"baz","baz":42}`,
+		},
+		{
+			name:  "marshaller",
+			value: slog.AnyValue(marshaller{}),
+			want:  `{"key":"value"}`,
+		},
+		{
+			name:  "error",
+			value: slog.AnyValue(errors.New("oops")),
+			want:  `"oops"`,
+		},
+		{
+			name:  "stringer",
+			value: slog.AnyValue(stringer{}),
+			want:  `"stringer"`,
+		},
+		{
+			name:  "json value",
+			value: slog.AnyValue(jsonValue{&mockReportLocation}),
+			want:  `{"filePath":"file.go","lineNumber":42,"functionName":"package.function"}`,
+		},
+		{
+			name:  "any of group value",
+			value: slog.AnyValue(slog.AnyValue(slog.GroupValue(slog.String("a", "a")))),
+			want:  `{"a":"a"}`,
+		},
+		{
+			name:  "pointer to value",
+			value: slog.AnyValue(&group),
+			want:  `{"a":"a","b":1}`,
+		},
+		{
+			name:  "nil pointer to value",
+			value: slog.AnyValue((*slog.Value)(nil)),
+			want:  `null`,
+		},
+		{
+			name:  "values",
+			value: slog.AnyValue([]slog.Value{slog.GroupValue(slog.String("a", "a")), slog.IntValue(1), slog.AnyValue(groupTypeTest)}),
+			want:  `[{"a":"a"},1,{"bar":"baz","baz":42}]`,
+		},
+		{
+			name:  "other",
+			value: slog.AnyValue([]string{"a", "b"}),
+			want:  `["a","b"]`,
+		},
+		{
+			name:  "unsupported",
+			value: slog.AnyValue(make(chan int)),
+			want:  `"!ERROR:json: unsupported type: chan int"`,
+		},
+		{
+			name:  "NaN",
+			value: slog.Float64Value(math.NaN()),
+			want:  `"!ERROR:json: unsupported value: NaN"`,
+		},
+		{
+			name:  "failing marshaller",
+			value: slog.AnyValue(failingMarshaller{}),
+			want:  `"!ERROR:json: error calling MarshalJSON for type *sloggcp.failingMarshaller: marshal failed"`,
+		},
+		{
+			name:  "unsupported group member",
+			value: slog.GroupValue(slog.String("a", "a"), slog.Any("b", func() {})),
+			want:  `{"a":"a","b":"!ERROR:json: unsupported type: func()"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := new(config).appendValue(nil, "key", tt.value, position{})
+			if err != nil {
+				t.Fatalf("appendValue() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("appendValue() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_anySlogValue(t *testing.T) {
+	group := slog.GroupValue(slog.String("a", "a"), slog.Group("g", "b", 1))
+	var buf bytes.Buffer
+	slog.New(New(&buf)).Info("msg",
+		slog.Any("value", group),
+		slog.Any("pointer", &group),
+		slog.Any("values", []slog.Value{group}),
+	)
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode log output %s: %v", buf.String(), err)
+	}
+	want := map[string]any{"a": "a", "g": map[string]any{"b": float64(1)}}
+	for key, want := range map[string]any{"value": want, "pointer": want, "values": []any{want}} {
+		if !reflect.DeepEqual(got[key], want) {
+			t.Errorf("%s = %v, want %v", key, got[key], want)
+		}
+	}
+}
+
+type failingMarshaller struct{}
+
+func (failingMarshaller) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("marshal failed")
+}
+
+func TestHandler_encodeError(t *testing.T) {
+	var (
+		buf     bytes.Buffer
+		handled []error
+	)
+	h := NewErrorReportingHandler(&buf, nil, WithErrorHandler(func(err error) {
+		handled = append(handled, err)
+	}))
+	slog.New(h).Info("msg", "ok", 1, "bad", make(chan int))
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode log output: %v", err)
+	}
+	if got["ok"] != 1.0 || got["bad"] != "!ERROR:json: unsupported type: chan int" || got[MessageKey] != "msg" {
+		t.Errorf("log output = %v", got)
+	}
+	if len(handled) != 1 {
+		t.Errorf("handled errors = %v, want 1", handled)
+	}
+}
+
+type point struct{ X, Y int }
+
+func (p point) String() string {
+	return strconv.Itoa(p.X) + "," + strconv.Itoa(p.Y)
+}
+
+// TestHandler_encodingCompatibility checks that the output is the same
+// as the output of the map based encoding, which the handler used before encoding directly.
+// Values of attributes added with WithAttrs were encoded with json.Marshal.
+func TestHandler_encodingCompatibility(t *testing.T) {
+	attrs := []slog.Attr{
+		slog.Duration("duration", 1500*time.Millisecond),
+		slog.Any("err", errors.New("boom")),
+		slog.Any("point", point{1, 2}),
+		slog.Any("addr", netip.MustParseAddr("192.0.2.1")),
+		slog.Group("group", slog.Duration("duration", time.Second), slog.Any("point", point{3, 4})),
+	}
+	tests := []struct {
+		name   string
+		with   []slog.Attr
+		record []slog.Attr
+		want   string
+	}{
+		{
+			name:   "record attributes",
+			record: attrs,
+			want:   `{"addr":"192.0.2.1","duration":"1.5s","err":"boom","group":{"duration":"1s","point":"3,4"},"message":"msg","point":"1,2","severity":"INFO"}`,
+		},
+		{
+			name: "with attributes",
+			with: attrs[:4],
+			want: `{"addr":"192.0.2.1","duration":1500000000,"err":{},"message":"msg","point":{"X":1,"Y":2},"severity":"INFO"}`,
+		},
+		{
+			name: "with group",
+			with: attrs[4:],
+			want: `{"group":{"duration":"1s","point":"3,4"},"message":"msg","severity":"INFO"}`,
+		},
+		{
+			name:   "record error",
+			record: []slog.Attr{slog.Any(ErrorKey, errors.New("boom"))},
+			want:   `{"@type":"type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent","error":"boom","message":"boom","severity":"INFO"}`,
+		},
+		{
+			name: "with error",
+			with: []slog.Attr{slog.Any(ErrorKey, errors.New("boom"))},
+			want: `{"@type":"type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent","error":{},"message":"boom","severity":"INFO"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := NewErrorReportingHandler(&buf, nil).WithAttrs(tt.with)
+			r := slog.NewRecord(time.Time{}, slog.LevelInfo, "msg", 0)
+			r.AddAttrs(tt.record...)
+			if err := h.Handle(context.Background(), r); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want+"\n" {
+				t.Errorf("log output =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
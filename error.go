@@ -0,0 +1,78 @@
+package sloggcp
+
+import "runtime"
+
+// Error is an error which records the call stack where it was created.
+// It implements [StackTraceError] and [ReportLocationError],
+// so it is reported with stack trace and location by the error reporting handler.
+// The stack is captured as program counters and only formatted when needed,
+// so creating an Error stays cheap.
+type Error struct {
+	msg string
+	err error
+	pcs []uintptr
+}
+
+var (
+	_ StackTraceError     = (*Error)(nil)
+	_ ReportLocationError = (*Error)(nil)
+)
+
+// NewError returns an [Error] with the given message,
+// recording the call stack of the caller.
+func NewError(msg string) *Error {
+	return newError(msg, nil)
+}
+
+// Wrap returns an [Error] wrapping err, with msg prepended to its message,
+// recording the call stack of the caller.
+// If err is nil, Wrap returns nil.
+func Wrap(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return newError(msg, err)
+}
+
+func newError(msg string, err error) *Error {
+	var pcs [64]uintptr
+	n := runtime.Callers(3, pcs[:]) // skip runtime.Callers, newError and the constructor
+	return &Error{
+		msg: msg,
+		err: err,
+		pcs: append([]uintptr(nil), pcs[:n]...),
+	}
+}
+
+// Error implements [error].
+// The message of a wrapped error is appended, separated by ": ".
+func (e *Error) Error() string {
+	if e.err == nil {
+		return e.msg
+	}
+	return e.msg + ": " + e.err.Error()
+}
+
+// Unwrap returns the wrapped error, if any.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// StackTrace implements [StackTraceError].
+// The stack is formatted like a panic stack trace.
+func (e *Error) StackTrace() ([]byte, bool) {
+	if len(e.pcs) == 0 {
+		return nil, false
+	}
+	// The goroutine that created the error is unknown.
+	return appendFrames([]byte("goroutine 1 [running]:\n"), e.pcs), true
+}
+
+// ReportLocation implements [ReportLocationError].
+// It returns the location where the error was created.
+func (e *Error) ReportLocation() *ReportLocation {
+	if len(e.pcs) == 0 {
+		return nil
+	}
+	return ReportLocationFromPC(e.pcs[0])
+}
@@ -0,0 +1,76 @@
+package sloggcp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// WithErrorHandler sets a function which is called when a record cannot be encoded or written,
+// or when an attribute value cannot be encoded and is replaced by an error string,
+// for example to count failures in a metric or to print them to stderr.
+// It is called after the handler released its lock, so it may log through the same handler.
+// Handlers of the slog package ignore the errors returned by Handle,
+// so without an error handler these failures are not noticed.
+func WithErrorHandler(handler func(error)) Option {
+	return func(c *config) {
+		c.errorHandler = handler
+	}
+}
+
+// WithFallbackWriter sets a writer, such as [os.Stderr], to which a record is written
+// when writing it to the handler's writer fails.
+// If the fallback write succeeds, the record is not lost and Handle returns nil,
+// while the error handler set through [WithErrorHandler] is still called with the original error.
+// An error wrapping [ErrPreviousBatch], which [BatchWriter] returns after adding the record,
+// is not considered failed, so the record is not written twice.
+// The fallback writer is used while the handler holds its lock,
+// so it is never used concurrently by handlers derived from the same handler.
+func WithFallbackWriter(w io.Writer) Option {
+	return func(c *config) {
+		c.fallbackWriter = w
+	}
+}
+
+// write writes an encoded record to the writer, or to the fallback writer if that fails.
+func (h *handler) write(buf []byte) error {
+	lost, err := h.writeLocked(buf)
+	if err == nil {
+		return nil
+	}
+	h.cfg.handleError(err)
+	if !lost {
+		return nil
+	}
+	return err
+}
+
+// writeLocked calls the rotation hook and writes buf while holding the handler's lock.
+// It returns whether the record is lost and the hook and write errors, if any.
+func (h *handler) writeLocked(buf []byte) (lost bool, err error) {
+	h.out.lock()
+	defer h.out.unlock()
+	rotateErr := h.rotate()
+	_, err = h.out.w.Write(buf)
+	if err == nil {
+		return false, rotateErr
+	}
+	err = errors.Join(rotateErr, fmt.Errorf("sloggcp handler: %w", err))
+	if errors.Is(err, ErrPreviousBatch) {
+		// The record was added, the error concerns earlier output, see [BatchWriter.Write].
+		return false, err
+	}
+	if h.cfg.fallbackWriter == nil {
+		return true, err
+	}
+	if _, fallbackErr := h.cfg.fallbackWriter.Write(buf); fallbackErr != nil {
+		return true, errors.Join(err, fmt.Errorf("sloggcp handler: fallback: %w", fallbackErr))
+	}
+	return false, err
+}
+
+func (c *config) handleError(err error) {
+	if c.errorHandler != nil {
+		c.errorHandler(err)
+	}
+}
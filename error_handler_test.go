@@ -0,0 +1,145 @@
+package sloggcp
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failingWriter returns err from every Write, with the full length of p if full is set.
+type failingWriter struct {
+	err  error
+	full bool
+}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	if w.full {
+		return len(p), w.err
+	}
+	return 0, w.err
+}
+
+func TestWithErrorHandler(t *testing.T) {
+	errWrite := errors.New("write failed")
+	errFallback := errors.New("fallback failed")
+	tests := []struct {
+		name        string
+		w           failingWriter
+		fallback    *bytes.Buffer
+		fallbackErr error
+		attrs       []any
+		wantHandled []error
+		wantErr     []error
+		wantOutput  bool
+	}{
+		{
+			name:        "write error",
+			w:           failingWriter{err: errWrite},
+			wantHandled: []error{errWrite},
+			wantErr:     []error{errWrite},
+		},
+		{
+			name:        "fallback",
+			w:           failingWriter{err: errWrite},
+			fallback:    new(bytes.Buffer),
+			wantHandled: []error{errWrite},
+			wantOutput:  true,
+		},
+		{
+			name:        "fallback with full count",
+			w:           failingWriter{err: errWrite, full: true},
+			fallback:    new(bytes.Buffer),
+			wantHandled: []error{errWrite},
+			wantOutput:  true,
+		},
+		{
+			name:        "fallback error",
+			w:           failingWriter{err: errWrite},
+			fallbackErr: errFallback,
+			wantHandled: []error{errWrite, errFallback},
+			wantErr:     []error{errWrite, errFallback},
+		},
+		{
+			name:        "encode error",
+			w:           failingWriter{},
+			attrs:       []any{"nan", math.NaN()},
+			wantHandled: []error{},
+		},
+		{
+			name: "no error",
+			w:    failingWriter{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled []error
+			options := []Option{WithErrorHandler(func(err error) {
+				handled = append(handled, err)
+			})}
+			if tt.fallback != nil {
+				options = append(options, WithFallbackWriter(tt.fallback))
+			}
+			if tt.fallbackErr != nil {
+				options = append(options, WithFallbackWriter(failingWriter{err: tt.fallbackErr}))
+			}
+			h := NewErrorReportingHandler(tt.w, nil, options...)
+			r := slog.NewRecord(time.Now(), slog.LevelInfo, "msg", 0)
+			r.Add(tt.attrs...)
+			err := h.Handle(t.Context(), r)
+
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("Handle() error = %v, want %v", err, tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Errorf("Handle() error = %v, want %v", err, want)
+				}
+			}
+			if (len(handled) > 0) != (tt.wantHandled != nil) {
+				t.Fatalf("handled errors = %v, want %v", handled, tt.wantHandled)
+			}
+			for _, want := range tt.wantHandled {
+				if !errors.Is(handled[0], want) {
+					t.Errorf("handled error = %v, want %v", handled[0], want)
+				}
+			}
+			if tt.fallback != nil && (tt.fallback.Len() > 0) != tt.wantOutput {
+				t.Errorf("fallback output = %q", tt.fallback.String())
+			}
+		})
+	}
+}
+
+func TestWithErrorHandler_logs(t *testing.T) {
+	// The error handler may log through the same handler without deadlock.
+	var (
+		mtx      sync.Mutex
+		fallback bytes.Buffer
+		logger   *slog.Logger
+		calls    int
+	)
+	h := NewErrorReportingHandler(failingWriter{err: errors.New("write failed")}, nil,
+		WithFallbackWriter(&fallback),
+		WithErrorHandler(func(err error) {
+			mtx.Lock()
+			calls++
+			first := calls == 1
+			mtx.Unlock()
+			if first {
+				logger.Warn("log write failed", ErrorKey, err)
+			}
+		}),
+	)
+	logger = slog.New(h)
+	logger.Info("msg")
+	if calls != 2 {
+		t.Errorf("error handler calls = %d, want 2", calls)
+	}
+	if got := bytes.Count(fallback.Bytes(), []byte("\n")); got != 2 {
+		t.Errorf("fallback output = %q, want 2 records", fallback.String())
+	}
+}
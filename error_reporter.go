@@ -0,0 +1,76 @@
+package sloggcp
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrorReport is an error report passed to an [ErrorReporter].
+type ErrorReport struct {
+	// Time of the record.
+	Time time.Time
+	// Error is the logged error value,
+	// or an error with the logged string, if the value is not an error.
+	Error error
+	// Message is the [MessageKey] field of the error report:
+	// the error message, followed by the stack trace, if any.
+	Message string
+	// Stack is the stack trace of the error, formatted like [runtime/debug.Stack].
+	// It is nil if the error has no stack trace, see [StackTraceError] and [WithAutoStackTrace].
+	Stack []byte
+	// Location is the report location of the error, if known.
+	Location *ReportLocation
+}
+
+// ErrorReporter receives the error reports of the handler,
+// in addition to the error reports written as JSON log entries.
+// For example, it may send them to the Error Reporting API,
+// so they are grouped and alerted on without waiting for the logs to be processed.
+type ErrorReporter interface {
+	ReportError(ctx context.Context, report ErrorReport)
+}
+
+// ErrorReporterFunc is a function implementing [ErrorReporter].
+type ErrorReporterFunc func(ctx context.Context, report ErrorReport)
+
+// ReportError implements [ErrorReporter].
+func (f ErrorReporterFunc) ReportError(ctx context.Context, report ErrorReport) {
+	f(ctx, report)
+}
+
+// WithErrorReporter sets a reporter which receives all error reports,
+// after the record is written to the writer. All records, including error reports,
+// are still written to the writer, so they appear in Cloud Logging as usual.
+// The reporter is called synchronously from Handle, and should not block,
+// for example by buffering reports like errorreporting.Client.Report does.
+// When reporter is nil, which is the default, error reports are only written to the writer.
+func WithErrorReporter(reporter ErrorReporter) Option {
+	return func(c *config) {
+		c.errorReporter = reporter
+	}
+}
+
+// newErrorReport creates the report for the error value,
+// with the message and location of the error report written to the log entry.
+// pc is the program counter of the log call, used for [WithAutoStackTrace].
+func (c *config) newErrorReport(value any, message string, location *ReportLocation, pc uintptr) *ErrorReport {
+	report := &ErrorReport{
+		Message:  message,
+		Location: location,
+	}
+	switch v := value.(type) {
+	case error:
+		report.Error = v
+		if trace, ok := stackTrace(v); ok {
+			report.Stack = trace
+		} else if c.autoStackTrace {
+			report.Stack = callerStack(pc)
+		}
+	case string:
+		report.Error = errors.New(v)
+	default:
+		report.Error = errors.New(message)
+	}
+	return report
+}
@@ -0,0 +1,93 @@
+package sloggcp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithErrorReporter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	errTest := errors.New("oops")
+	tests := []struct {
+		name  string
+		attrs []slog.Attr
+		want  []ErrorReport
+	}{
+		{
+			name:  "no error",
+			attrs: []slog.Attr{slog.String("key", "value")},
+		},
+		{
+			name:  "error",
+			attrs: []slog.Attr{slog.Any(ErrorKey, errTest)},
+			want:  []ErrorReport{{Time: now, Error: errTest, Message: "oops"}},
+		},
+		{
+			name:  "string",
+			attrs: []slog.Attr{slog.String(ErrorKey, "oops")},
+			want:  []ErrorReport{{Time: now, Error: errors.New("oops"), Message: "oops"}},
+		},
+		{
+			name:  "stack trace and report location",
+			attrs: []slog.Attr{slog.Any(ErrorKey, mockStackAndReport{returnStack: true})},
+			want: []ErrorReport{{
+				Time:     now,
+				Error:    mockStackAndReport{returnStack: true},
+				Message:  "mockStackAndReport\nstack",
+				Stack:    []byte("stack"),
+				Location: &mockReportLocation,
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				buf bytes.Buffer
+				got []ErrorReport
+			)
+			reporter := ErrorReporterFunc(func(_ context.Context, report ErrorReport) {
+				got = append(got, report)
+			})
+			h := NewErrorReportingHandler(&buf, nil, WithErrorReporter(reporter))
+			r := slog.NewRecord(now, slog.LevelError, "msg", 0)
+			r.AddAttrs(tt.attrs...)
+			if err := h.Handle(context.Background(), r); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reports = %+v, want %+v", got, tt.want)
+			}
+			if lines := strings.Count(buf.String(), "\n"); lines != 1 {
+				t.Errorf("written records = %d, want 1", lines)
+			}
+		})
+	}
+}
+
+func TestWithErrorReporter_nil(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewErrorReportingHandler(&buf, nil, WithErrorReporter(nil)))
+	logger.Error("msg", ErrorKey, errors.New("oops"))
+	if !strings.Contains(buf.String(), ErrorReportTypeValue) {
+		t.Errorf("output = %s, want error report", buf.String())
+	}
+}
+
+func TestWithErrorReporter_autoStackTrace(t *testing.T) {
+	var got ErrorReport
+	reporter := ErrorReporterFunc(func(_ context.Context, report ErrorReport) {
+		got = report
+	})
+	logger := slog.New(NewErrorReportingHandler(&bytes.Buffer{}, nil,
+		WithErrorReporter(reporter), WithAutoStackTrace(true)))
+	logger.Error("msg", ErrorKey, errors.New("oops"))
+	if !strings.Contains(string(got.Stack), "TestWithErrorReporter_autoStackTrace") {
+		t.Errorf("Stack = %s, want stack of the log call", got.Stack)
+	}
+}
@@ -1,15 +1,20 @@
 package sloggcp
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"log/slog"
+	"reflect"
 	"runtime"
+	"slices"
 	"strings"
 
 	_ "runtime/debug"
 )
 
-// Key by which errors are retrieved from slog attributes.
+// Key by which errors are retrieved from slog attributes,
+// unless other keys are set through [WithErrorKeys].
 // The corresponding values can be of type [string], [error], [StackTraceError] and/or [ReportLocationError].
 const (
 	ErrorKey = "error"
@@ -43,19 +48,52 @@ type ReportLocationError interface {
 	ReportLocation() *ReportLocation
 }
 
+// SeverityError is an error that determines the severity of the log entry it is reported in,
+// such as an error carrying an HTTP or gRPC status code, which maps client errors to [slog.LevelWarn]
+// and server errors to [slog.LevelError]. So expected client errors do not trigger alerts on errors.
+//
+// The severity of a record with an error report is the level returned by Severity,
+// mapped like the level of the record, see [SeverityName] and [slog.HandlerOptions.ReplaceAttr].
+// It takes precedence over the level of the record and over [WithErrorReportSeverity].
+// The first SeverityError in the error tree is used, as found by [errors.As].
+type SeverityError interface {
+	error
+	Severity() slog.Level
+}
+
+// errorSeverity returns the level of the first [SeverityError] in the error tree of value.
+func errorSeverity(value any) (slog.Level, bool) {
+	err, ok := value.(error)
+	if !ok {
+		return 0, false
+	}
+	var target SeverityError
+	if !errors.As(err, &target) {
+		return 0, false
+	}
+	return target.Severity(), true
+}
+
 // assertErrorValue inspects the given value and tries to extract
 // the error message and report location information.
 // Supported value types are:
 //   - string
-//   - error (including [StackTraceError] and [ReportLocationError])
+//   - error (including [StackTraceError], [ReportLocationError]
+//     and errors with a program counter stack trace, see stackTrace)
 //
+// The error message is created by formatMessage, or [error.Error] if nil.
+// An empty message is replaced by one naming the type of the value.
 // For unsupported types, a generic error message is returned.
 // If the error contains a stack trace, the error message is kept as header,
 // followed by the stack trace separated by a newline.
-func assertErrorValue(value any) (string, *ReportLocation) {
+// When maxBytes is greater than 0, the stack trace is truncated to fit the message into maxBytes.
+func assertErrorValue(value any, formatMessage ErrorMessageFormatter, maxBytes int) (string, *ReportLocation) {
 	// String type won't match any other type assertions below,
 	// so we can return early.
 	if v, ok := value.(string); ok {
+		if v == "" {
+			return emptyErrorMessage(value), nil
+		}
 		return v, nil
 	}
 
@@ -65,22 +103,65 @@ func assertErrorValue(value any) (string, *ReportLocation) {
 			NewReportLocation(0)
 	}
 
-	var msgBuf strings.Builder
-	msgBuf.WriteString(err.Error())
+	var msg string
+	if formatMessage != nil {
+		msg = formatMessage(err)
+	} else {
+		msg = err.Error()
+	}
+	if msg == "" {
+		msg = emptyErrorMessage(err)
+	}
+	if trace, ok := stackTrace(err); ok {
+		msg = appendStackTrace(msg, trace, maxBytes)
+	}
+	return msg, findReportLocation(err)
+}
 
-	if v, ok := err.(StackTraceError); ok {
-		if trace, traceOk := v.StackTrace(); traceOk {
-			msgBuf.Grow(len(trace) + 1)
-			msgBuf.WriteByte('\n')
-			msgBuf.Write(trace)
-		}
+// emptyErrorMessage returns the message for an error value with an empty message.
+// Error Reporting groups errors by their message, so the type name is used instead.
+func emptyErrorMessage(value any) string {
+	return fmt.Sprintf("sloggcp: empty error message of type %T", value)
+}
+
+// appendStackTrace appends the trace to msg, separated by a newline.
+// When maxBytes is greater than 0, the trace is truncated at a line boundary,
+// so the result fits into maxBytes. See [WithMaxValueBytes].
+func appendStackTrace(msg string, trace []byte, maxBytes int) string {
+	if maxBytes > 0 {
+		trace = truncateStackTrace(trace, max(maxBytes-len(msg)-1, len(truncatedMarker)))
 	}
+	return msg + "\n" + string(trace)
+}
 
-	var reportLocation *ReportLocation
-	if v, ok := err.(ReportLocationError); ok {
-		reportLocation = v.ReportLocation()
+// findReportLocation searches the error tree of err for a [ReportLocationError]
+// returning a non-nil location.
+// The tree is traversed in the same order as [errors.As]:
+// err itself first, followed by depth-first traversal of the errors returned by
+// Unwrap() error or Unwrap() []error, such as created by [errors.Join].
+// Therefore, with joined errors, the location of the first branch providing one is used.
+func findReportLocation(err error) *ReportLocation {
+	for err != nil {
+		if v, ok := err.(ReportLocationError); ok {
+			if location := v.ReportLocation(); location != nil {
+				return location
+			}
+		}
+		switch x := err.(type) {
+		case interface{ Unwrap() error }:
+			err = x.Unwrap()
+		case interface{ Unwrap() []error }:
+			for _, err := range x.Unwrap() {
+				if location := findReportLocation(err); location != nil {
+					return location
+				}
+			}
+			return nil
+		default:
+			return nil
+		}
 	}
-	return msgBuf.String(), reportLocation
+	return nil
 }
 
 type ReportLocation struct {
@@ -107,6 +188,24 @@ func NewReportLocation(skip int) *ReportLocation {
 	}
 }
 
+// ReportLocationFromPC returns the [ReportLocation] of a program counter,
+// such as the call site of a log call in [slog.Record.PC], or a frame returned by [runtime.Callers].
+// It returns nil if pc is 0 or unknown.
+func ReportLocationFromPC(pc uintptr) *ReportLocation {
+	if pc == 0 {
+		return nil
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	if frame.Function == "" {
+		return nil
+	}
+	return &ReportLocation{
+		FilePath:     frame.File,
+		LineNumber:   frame.Line,
+		FunctionName: frame.Function,
+	}
+}
+
 // LogValue implements [slog.LogValuer].
 // It allows a ReportLocation to be used directly in other handlers.
 func (r *ReportLocation) LogValue() slog.Value {
@@ -117,24 +216,328 @@ func (r *ReportLocation) LogValue() slog.Value {
 	)
 }
 
-func checkAndSetErrorReport(a slog.Attr, out map[string]any) bool {
-	if a.Key != ErrorKey {
-		return false
-	}
+// setErrorReport adds the error report attributes for the error attribute a to out.
+// The error attribute itself is added by the caller with addErrorValue, unless it is part of a group.
+// pc is the program counter of the log call, used to capture a stack trace
+// when [WithAutoStackTrace] is enabled, and the report location when [WithAutoReportLocation] is enabled.
+// If an [ErrorReporter] is set, the report for it is returned, otherwise nil.
+func (c *config) setErrorReport(out *object, a slog.Attr, pc uintptr) (report *ErrorReport) {
 	value := a.Value.Any()
-	errMsg, reportLocation := assertErrorValue(value)
-	out[ErrorReportTypeKey] = ErrorReportTypeValue
-	out[MessageKey] = errMsg
-	out[ErrorKey] = value
+	errMsg, reportLocation := assertErrorValue(value, c.errorMessageFormatter, c.maxValueBytes)
+	callerLocations := findReportLocations(value)
+	if reportLocation == nil && len(callerLocations) > 0 {
+		reportLocation = callerLocations[0]
+	}
+	if len(callerLocations) > 0 {
+		callerLocations = callerLocations[1:]
+	}
+	if reportLocation == nil && c.autoReportLocation {
+		reportLocation = ReportLocationFromPC(pc)
+	}
+	if err, ok := value.(error); ok && c.autoStackTrace && !hasStackTrace(err) {
+		errMsg = appendStackTrace(errMsg, callerStack(pc), c.maxValueBytes)
+	}
+	if c.errorReportType != "" {
+		out.add(ErrorReportTypeKey, slog.StringValue(c.errorReportType))
+	}
+	out.add(MessageKey, slog.StringValue(errMsg))
 	if reportLocation != nil {
-		out[ReportLocationKey] = reportLocation
+		out.addJSON(ReportLocationKey, reportLocation)
+	}
+	if len(callerLocations) > 0 {
+		out.addJSON(CallerLocationsKey, callerLocations)
+	}
+	if c.serviceContext != nil {
+		out.addJSON(ServiceContextKey, c.serviceContext)
+	}
+	if c.errorReporter != nil {
+		report = c.newErrorReport(value, errMsg, reportLocation, pc)
+	}
+	return report
+}
+
+// addErrorValue adds the error attribute a to out,
+// with the value according to [WithErrorValueEncoder] and [WithOrderedErrorValue].
+// with is set for an attribute added with WithAttrs, whose value is encoded like those of other such attributes.
+func (c *config) addErrorValue(out *object, a slog.Attr, with bool) {
+	value := a.Value.Any()
+	addAttr := func(v slog.Value, with bool) {
+		out.fields = append(out.fields, field{key: a.Key, value: v, attr: true, ordered: c.orderedErrorValue, with: with})
+	}
+	if c.errorValueEncoder != nil {
+		if v, ok := c.errorValueEncoder(value); ok {
+			addAttr(slog.AnyValue(v), false)
+			return
+		}
 	}
 	switch v := value.(type) {
 	case slog.LogValuer:
-		out[ErrorKey] = extractValue(v.LogValue())
+		addAttr(v.LogValue(), with)
 	case error:
-		out[ErrorKey] = v.Error()
+		if with {
+			addAttr(a.Value, true)
+		} else {
+			addAttr(slog.StringValue(v.Error()), false)
+		}
+	default:
+		addAttr(a.Value, with)
+	}
+}
+
+// WithErrorValueEncoder sets a function which encodes the value of the error attribute of error reports.
+// For example, to keep the error string searchable under [ErrorKey] for all errors,
+// while the structured details of a [slog.LogValuer] are logged under another key:
+//
+//	sloggcp.WithErrorValueEncoder(func(value any) (any, bool) {
+//		if err, ok := value.(error); ok {
+//			return err.Error(), true
+//		}
+//		return nil, false
+//	})
+//	// ...
+//	logger.Error("validation failed", sloggcp.ErrorKey, err, "errorDetails", err)
+//
+// The returned value is written like an attribute value, under the key of the error attribute.
+// When ok is false, the value is written as by default: the result of the LogValue method of a [slog.LogValuer],
+// the string of an error, or the value itself. The error report is not affected.
+// Errors in groups, see [WithGroupedErrors], keep their value.
+func WithErrorValueEncoder(encode func(value any) (any, bool)) Option {
+	return func(c *config) {
+		c.errorValueEncoder = encode
 	}
+}
+
+// WithOrderedErrorValue writes the members of the error attribute value of error reports,
+// such as the group returned by the LogValue method of an error, in the order of the attributes,
+// instead of sorted by key, for reproducible payloads which read like the error was built.
+// This applies to nested groups of the value as well, while all other groups remain sorted by key.
+// Only the order of the members changes, not the data: of duplicate keys, the last value still wins,
+// at the position of the first attribute with the key, see [WithDuplicateKeys].
+// Errors in groups, see [WithGroupedErrors], keep their order as all other group members.
+func WithOrderedErrorValue(enabled bool) Option {
+	return func(c *config) {
+		c.orderedErrorValue = enabled
+	}
+}
+
+// WithErrorKeys sets the attribute keys by which errors are recognized.
+// When a record contains top-level attributes with more than one of the keys,
+// the error report is created from the first key in keys.
+// The attributes with the other keys are logged as regular attributes.
+// By default, only [ErrorKey] is recognized.
+func WithErrorKeys(keys ...string) Option {
+	return func(c *config) {
+		c.errorKeys = keys
+	}
+}
 
-	return true
+// WithErrorReportType sets the value of the [ErrorReportTypeKey] field of error reports,
+// for schemas other than the default [ErrorReportTypeValue].
+// An empty typeURL restores the default.
+func WithErrorReportType(typeURL string) Option {
+	return func(c *config) {
+		if typeURL == "" {
+			typeURL = ErrorReportTypeValue
+		}
+		c.errorReportType = typeURL
+	}
+}
+
+// WithoutErrorReportType omits the [ErrorReportTypeKey] field from error reports,
+// for log pipelines which reject the "@type" field, for example because it conflicts with their schema.
+// The message, report location and service context of error reports are still written,
+// and the [ErrorReporter] is still called. Without the field, Error Reporting only picks up
+// errors whose message contains a stack trace.
+// A later [WithErrorReportType] adds the field again.
+func WithoutErrorReportType() Option {
+	return func(c *config) {
+		c.errorReportType = ""
+	}
+}
+
+// WithGroupedErrors enables error reports for error attributes inside groups,
+// such as added to a logger created with [slog.Logger.WithGroup].
+// The error report is created at the top level, while the error attribute
+// remains part of its group. Top-level error attributes take precedence.
+// By default, only top-level error attributes create error reports.
+func WithGroupedErrors(enabled bool) Option {
+	return func(c *config) {
+		c.groupedErrors = enabled
+	}
+}
+
+// LogMessageKey is the suggested key for [WithLogMessageKey].
+const LogMessageKey = "logMessage"
+
+// WithLogMessageKey preserves the log message of records with an error report under key.
+// The [MessageKey] field of an error report carries the error and stack trace, as required by Error Reporting,
+// so the log message is otherwise discarded. With this option, the message is written under key,
+// unless it is empty. Records without error report keep the message under [MessageKey].
+// Like the other fields of the error report, it overrides an attribute with the same key, see [WithDuplicateKeys].
+// By default, or when key is empty, the log message of error reports is discarded.
+func WithLogMessageKey(key string) Option {
+	return func(c *config) {
+		c.logMessageKey = key
+	}
+}
+
+// WithErrorReportSeverity raises the severity of records with an error report to at least ERROR,
+// as expected by Error Reporting, for example for a partial failure logged at [LevelInfo].
+// Severities set through [slog.HandlerOptions.ReplaceAttr] which are not parsed by [ParseSeverity] are kept.
+// Errors implementing [SeverityError] determine the severity themselves.
+// By default, the severity is determined by the level of the record only,
+// so teams can deliberately log expected errors at a lower severity.
+func WithErrorReportSeverity(enabled bool) Option {
+	return func(c *config) {
+		c.errorReportSeverity = enabled
+	}
+}
+
+// raiseSeverity sets the severity in out to ERROR, if severity is lower.
+// It returns the resulting severity.
+func raiseSeverity(out *object, severity string) string {
+	if level, err := ParseSeverity(severity); err == nil && level < LevelError {
+		out.add(SeverityKey, slog.StringValue(ErrorSeverity))
+		return ErrorSeverity
+	}
+	return severity
+}
+
+// ErrorMessageFormatter returns the message of an error for the error report.
+// The stack trace, if any, is appended to the returned message.
+type ErrorMessageFormatter func(err error) string
+
+// WithErrorMessageFormatter sets the function used to format the message of errors in error reports.
+// By default, the result of [error.Error] is used.
+// See [SingleLineErrorMessage] for errors containing multiple lines, such as created by [errors.Join].
+func WithErrorMessageFormatter(formatter ErrorMessageFormatter) Option {
+	return func(c *config) {
+		c.errorMessageFormatter = formatter
+	}
+}
+
+// SingleLineErrorMessage is an [ErrorMessageFormatter] which joins
+// the lines of multi-line error messages using "; ".
+// Error Reporting treats the first line of the message as the error header,
+// so joined errors remain readable and do not interfere with a stack trace.
+func SingleLineErrorMessage(err error) string {
+	msg := err.Error()
+	if !strings.Contains(msg, "\n") {
+		return msg
+	}
+	return strings.Join(strings.FieldsFunc(msg, func(r rune) bool { return r == '\n' }), "; ")
+}
+
+// WithAutoReportLocation enables using the location of the log call as report location,
+// for errors which do not provide their own through [ReportLocationError],
+// such as sentinel errors created by [errors.New].
+// The location is taken from the program counter of the record, as used for [slog.Record.Source],
+// so it points to the call of the logging method, such as [slog.Logger.Error].
+// It is disabled by default.
+func WithAutoReportLocation(enabled bool) Option {
+	return func(c *config) {
+		c.autoReportLocation = enabled
+	}
+}
+
+// WithAutoStackTrace enables capturing a stack trace for logged errors
+// which do not provide their own through [StackTraceError].
+// The stack trace of the goroutine is captured in Handle,
+// starting at the log call, and appended to the error report message,
+// the same way as for a [StackTraceError].
+// This only applies to [error] values. It is disabled by default.
+func WithAutoStackTrace(enabled bool) Option {
+	return func(c *config) {
+		c.autoStackTrace = enabled
+	}
+}
+
+func hasStackTrace(err error) bool {
+	if v, ok := err.(StackTraceError); ok {
+		_, ok = v.StackTrace()
+		return ok
+	}
+	_, ok := programCounters(err)
+	return ok
+}
+
+// stackTrace returns the stack trace of err, if it provides one.
+// Two conventions are supported:
+//   - [StackTraceError], returning the formatted stack trace.
+//   - A StackTrace method returning a slice of program counters,
+//     such as the StackTrace() errors.StackTrace method of github.com/pkg/errors.
+//     The program counters are formatted like a panic stack trace, so Error Reporting can parse them.
+func stackTrace(err error) ([]byte, bool) {
+	if v, ok := err.(StackTraceError); ok {
+		return v.StackTrace()
+	}
+	if pcs, ok := programCounters(err); ok {
+		// The goroutine that created the error is unknown.
+		return appendFrames([]byte("goroutine 1 [running]:\n"), pcs), true
+	}
+	return nil, false
+}
+
+// programCounters calls the StackTrace method of err,
+// if it returns a slice of uintptr based values, such as
+// the Frame type of github.com/pkg/errors.
+// Reflection is used to avoid a dependency on those packages.
+func programCounters(err error) ([]uintptr, bool) {
+	method := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !method.IsValid() {
+		return nil, false
+	}
+	typ := method.Type()
+	if typ.NumIn() != 0 || typ.NumOut() != 1 ||
+		typ.Out(0).Kind() != reflect.Slice || typ.Out(0).Elem().Kind() != reflect.Uintptr {
+		return nil, false
+	}
+	frames := method.Call(nil)[0]
+	if frames.Len() == 0 {
+		return nil, false
+	}
+	pcs := make([]uintptr, frames.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(frames.Index(i).Uint())
+	}
+	return pcs, true
+}
+
+// callerStack returns the stack trace of the current goroutine,
+// formatted like a panic, starting at the frame of pc.
+// If pc is not found on the stack, for example because the record
+// is handled on another goroutine, the stack starts at the caller of callerStack.
+func callerStack(pc uintptr) []byte {
+	var pcs [64]uintptr
+	n := runtime.Callers(2, pcs[:])
+	callers := pcs[:n]
+	if i := slices.Index(callers, pc); i >= 0 {
+		callers = callers[i:]
+	}
+
+	// The first line of the stack contains the goroutine ID and state.
+	header := make([]byte, 64)
+	header = header[:runtime.Stack(header, false)]
+	if i := bytes.IndexByte(header, '\n'); i >= 0 {
+		header = header[:i]
+	}
+
+	buf := make([]byte, 0, 1024)
+	buf = append(buf, header...)
+	buf = append(buf, '\n')
+	return appendFrames(buf, callers)
+}
+
+// appendFrames formats the frames of the program counters like a panic stack trace.
+func appendFrames(buf []byte, pcs []uintptr) []byte {
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			buf = fmt.Appendf(buf, "%s(...)\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		}
+		if !more {
+			return buf
+		}
+	}
 }
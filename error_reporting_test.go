@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"reflect"
 	"runtime"
 	"strings"
@@ -61,6 +63,21 @@ func Test_assertErrorValue(t *testing.T) {
 			wantErrMsg:         "mockStackAndReport",
 			wantReportLocation: &mockReportLocation,
 		},
+		{
+			name:       "empty string",
+			value:      "",
+			wantErrMsg: "sloggcp: empty error message of type string",
+		},
+		{
+			name:       "empty error message",
+			value:      emptyError{},
+			wantErrMsg: "sloggcp: empty error message of type sloggcp.emptyError",
+		},
+		{
+			name:       "empty error message with stack",
+			value:      emptyStackTraceError{},
+			wantErrMsg: "sloggcp: empty error message of type sloggcp.emptyStackTraceError\nstack",
+		},
 		{
 			name:           "unknown type",
 			value:          42,
@@ -70,7 +87,7 @@ func Test_assertErrorValue(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotErrMsg, gotReportLocation := assertErrorValue(tt.value)
+			gotErrMsg, gotReportLocation := assertErrorValue(tt.value, nil, 0)
 			if tt.wantErrMsg != gotErrMsg {
 				t.Errorf("assertErrorValue() = %v, want %v", gotErrMsg, tt.wantErrMsg)
 			}
@@ -137,6 +154,51 @@ func TestNewReportLocation(t *testing.T) {
 	}
 }
 
+func TestReportLocationFromPC(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	_, _, wantLine, _ := runtime.Caller(0)
+	wantLine-- // previous line
+
+	tests := []struct {
+		name string
+		pc   uintptr
+		want *ReportLocation
+	}{
+		{
+			name: "caller",
+			pc:   pcs[0],
+			want: &ReportLocation{
+				LineNumber:   wantLine,
+				FunctionName: "github.com/zitadel/sloggcp.TestReportLocationFromPC",
+			},
+		},
+		{
+			name: "zero",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ReportLocationFromPC(tt.pc)
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("ReportLocationFromPC() = %v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("ReportLocationFromPC() = nil, want non-nil")
+			}
+			if !strings.HasSuffix(got.FilePath, "error_reporting_test.go") {
+				t.Errorf("ReportLocationFromPC() filePath = %v, want suffix error_reporting_test.go", got.FilePath)
+			}
+			if got.LineNumber != tt.want.LineNumber || got.FunctionName != tt.want.FunctionName {
+				t.Errorf("ReportLocationFromPC() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
 var mockReportLocation = ReportLocation{
 	FilePath:     "file.go",
 	LineNumber:   42,
@@ -168,6 +230,18 @@ func (m mockStackTraceError) StackTrace() ([]byte, bool) {
 	return nil, false
 }
 
+type emptyError struct{}
+
+func (emptyError) Error() string {
+	return ""
+}
+
+type emptyStackTraceError struct{ emptyError }
+
+func (emptyStackTraceError) StackTrace() ([]byte, bool) {
+	return []byte("stack"), true
+}
+
 type mockStackAndReport struct {
 	returnStack bool
 }
@@ -237,3 +311,921 @@ func TestReportLocation_LogValue(t *testing.T) {
 		t.Errorf("LogValue() Location.FunctionName = %v, want suffix %v", got.Location.FunctionName, "TestReportLocation_LogValue")
 	}
 }
+
+func TestHandler_autoStackTrace(t *testing.T) {
+	tests := []struct {
+		name      string
+		enabled   bool
+		err       any
+		wantStack bool
+	}{
+		{
+			name:    "disabled",
+			enabled: false,
+			err:     errors.New("oops"),
+		},
+		{
+			name:      "plain error",
+			enabled:   true,
+			err:       errors.New("oops"),
+			wantStack: true,
+		},
+		{
+			name:    "string",
+			enabled: true,
+			err:     "oops",
+		},
+		{
+			name:    "StackTraceError",
+			enabled: true,
+			err:     mockStackTraceError{true},
+		},
+		{
+			name:      "StackTraceError without stack",
+			enabled:   true,
+			err:       mockStackTraceError{false},
+			wantStack: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(NewErrorReportingHandler(&buf, nil, WithAutoStackTrace(tt.enabled)))
+			logger.Error("msg", ErrorKey, tt.err)
+
+			var got struct {
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			errMsg, _ := assertErrorValue(tt.err, nil, 0)
+			if !tt.wantStack {
+				if got.Message != errMsg {
+					t.Errorf("message = %q, want %q", got.Message, errMsg)
+				}
+				return
+			}
+			lines := strings.Split(got.Message, "\n")
+			if len(lines) < 4 {
+				t.Fatalf("message = %q, want stack trace", got.Message)
+			}
+			if lines[0] != errMsg {
+				t.Errorf("message header = %q, want %q", lines[0], errMsg)
+			}
+			if !strings.HasPrefix(lines[1], "goroutine ") {
+				t.Errorf("stack header = %q, want goroutine", lines[1])
+			}
+			// The top frame is the log call site, not the handler or slog.
+			const wantFunc = "github.com/zitadel/sloggcp.TestHandler_autoStackTrace.func1(...)"
+			if lines[2] != wantFunc {
+				t.Errorf("top frame = %q, want %q", lines[2], wantFunc)
+			}
+			if !strings.Contains(lines[3], "error_reporting_test.go:") {
+				t.Errorf("top frame location = %q, want error_reporting_test.go", lines[3])
+			}
+		})
+	}
+}
+
+// pkgErrorsFrame, pkgErrorsStackTrace and pkgErrorsError
+// mimic the stack trace types of github.com/pkg/errors.
+type pkgErrorsFrame uintptr
+
+type pkgErrorsStackTrace []pkgErrorsFrame
+
+type pkgErrorsError struct {
+	stack []uintptr
+}
+
+func newPkgErrorsError() pkgErrorsError {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	return pkgErrorsError{stack: pcs[:n]}
+}
+
+func (pkgErrorsError) Error() string {
+	return "pkgErrorsError"
+}
+
+func (e pkgErrorsError) StackTrace() pkgErrorsStackTrace {
+	f := make(pkgErrorsStackTrace, len(e.stack))
+	for i := range f {
+		f[i] = pkgErrorsFrame(e.stack[i])
+	}
+	return f
+}
+
+type otherStackTraceError struct{}
+
+func (otherStackTraceError) Error() string {
+	return "otherStackTraceError"
+}
+
+func (otherStackTraceError) StackTrace() []string {
+	return []string{"not program counters"}
+}
+
+func Test_stackTrace(t *testing.T) {
+	err := newPkgErrorsError()
+	trace, ok := stackTrace(err)
+	if !ok {
+		t.Fatal("stackTrace() ok = false, want true")
+	}
+	lines := strings.Split(string(trace), "\n")
+	if len(lines) < 3 {
+		t.Fatalf("stackTrace() = %q, want frames", trace)
+	}
+	if lines[0] != "goroutine 1 [running]:" {
+		t.Errorf("stackTrace() header = %q", lines[0])
+	}
+	const wantFunc = "github.com/zitadel/sloggcp.Test_stackTrace(...)"
+	if lines[1] != wantFunc {
+		t.Errorf("stackTrace() top frame = %q, want %q", lines[1], wantFunc)
+	}
+	if !strings.Contains(lines[2], "error_reporting_test.go:") {
+		t.Errorf("stackTrace() top frame location = %q, want error_reporting_test.go", lines[2])
+	}
+
+	errMsg, _ := assertErrorValue(err, nil, 0)
+	if want := "pkgErrorsError\n" + string(trace); errMsg != want {
+		t.Errorf("assertErrorValue() = %q, want %q", errMsg, want)
+	}
+
+	if _, ok := stackTrace(otherStackTraceError{}); ok {
+		t.Error("stackTrace() of non program counter StackTrace method ok = true, want false")
+	}
+	if _, ok := stackTrace(errors.New("plain")); ok {
+		t.Error("stackTrace() of plain error ok = true, want false")
+	}
+}
+
+type nilReportLocationError struct{}
+
+func (nilReportLocationError) Error() string {
+	return "nilReportLocationError"
+}
+
+func (nilReportLocationError) ReportLocation() *ReportLocation {
+	return nil
+}
+
+type otherReportLocationError struct{}
+
+var otherReportLocation = ReportLocation{
+	FilePath:     "other.go",
+	LineNumber:   1,
+	FunctionName: "package.other",
+}
+
+func (otherReportLocationError) Error() string {
+	return "otherReportLocationError"
+}
+
+func (otherReportLocationError) ReportLocation() *ReportLocation {
+	return &otherReportLocation
+}
+
+func Test_findReportLocation(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want *ReportLocation
+	}{
+		{
+			name: "plain error",
+			err:  errors.New("plain"),
+			want: nil,
+		},
+		{
+			name: "wrapped",
+			err:  fmt.Errorf("wrapped: %w", mockReportLocationError{}),
+			want: &mockReportLocation,
+		},
+		{
+			name: "joined, plain first",
+			err:  errors.Join(errors.New("plain"), mockReportLocationError{}),
+			want: &mockReportLocation,
+		},
+		{
+			name: "joined, location first",
+			err:  errors.Join(mockReportLocationError{}, errors.New("plain")),
+			want: &mockReportLocation,
+		},
+		{
+			name: "joined, first location wins",
+			err:  errors.Join(errors.New("plain"), otherReportLocationError{}, mockReportLocationError{}),
+			want: &otherReportLocation,
+		},
+		{
+			name: "joined, nil location skipped",
+			err:  errors.Join(nilReportLocationError{}, mockReportLocationError{}),
+			want: &mockReportLocation,
+		},
+		{
+			name: "joined, nested in wrapped",
+			err:  fmt.Errorf("wrapped: %w", errors.Join(errors.New("plain"), fmt.Errorf("inner: %w", otherReportLocationError{}))),
+			want: &otherReportLocation,
+		},
+		{
+			name: "joined, no location",
+			err:  errors.Join(errors.New("a"), nilReportLocationError{}),
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findReportLocation(tt.err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findReportLocation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_errorMessageFormatter(t *testing.T) {
+	err := errors.Join(errors.New("first"), mockReportLocationError{})
+	tests := []struct {
+		name    string
+		options []Option
+		want    string
+	}{
+		{
+			name: "default",
+			want: "first\nmockReportLocationError",
+		},
+		{
+			name:    "single line",
+			options: []Option{WithErrorMessageFormatter(SingleLineErrorMessage)},
+			want:    "first; mockReportLocationError",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(NewErrorReportingHandler(&buf, nil, tt.options...))
+			logger.Error("msg", ErrorKey, err)
+
+			var got struct {
+				Message        string         `json:"message"`
+				ReportLocation ReportLocation `json:"reportLocation"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			if got.Message != tt.want {
+				t.Errorf("message = %q, want %q", got.Message, tt.want)
+			}
+			if got.ReportLocation != mockReportLocation {
+				t.Errorf("reportLocation = %v, want %v", got.ReportLocation, mockReportLocation)
+			}
+		})
+	}
+}
+
+func TestHandler_errorKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []Option
+		attrs    []slog.Attr
+		logAttrs []any
+		want     map[string]any
+	}{
+		{
+			name:     "default key",
+			logAttrs: []any{"err", "ignored", ErrorKey, "oops"},
+			want: map[string]any{
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				MessageKey:         "oops",
+				ErrorKey:           "oops",
+				"err":              "ignored",
+			},
+		},
+		{
+			name:     "configured key",
+			options:  []Option{WithErrorKeys("error", "err")},
+			logAttrs: []any{"err", "oops"},
+			want: map[string]any{
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				MessageKey:         "oops",
+				"err":              "oops",
+			},
+		},
+		{
+			name:     "first key wins, lower priority first",
+			options:  []Option{WithErrorKeys("error", "err")},
+			logAttrs: []any{"err", "second", "error", "first"},
+			want: map[string]any{
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				MessageKey:         "first",
+				"error":            "first",
+				"err":              "second",
+			},
+		},
+		{
+			name:     "first key wins, lower priority last",
+			options:  []Option{WithErrorKeys("error", "err")},
+			logAttrs: []any{"error", "first", "err", "second"},
+			want: map[string]any{
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				MessageKey:         "first",
+				"error":            "first",
+				"err":              "second",
+			},
+		},
+		{
+			name:     "first key wins, from WithAttrs",
+			options:  []Option{WithErrorKeys("error", "err")},
+			attrs:    []slog.Attr{slog.String("err", "second")},
+			logAttrs: []any{"error", errors.New("first")},
+			want: map[string]any{
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				MessageKey:         "first",
+				"error":            "first",
+				"err":              "second",
+			},
+		},
+		{
+			name:     "repeated key, last wins",
+			options:  []Option{WithErrorKeys("err")},
+			logAttrs: []any{"err", "first", "err", "second"},
+			want: map[string]any{
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				MessageKey:         "second",
+				"err":              "second",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			handler := NewErrorReportingHandler(&buf, nil, tt.options...).WithAttrs(tt.attrs)
+			slog.New(handler).Error("msg", tt.logAttrs...)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			delete(got, TimeKey)
+			delete(got, SeverityKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_groupedErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		group    string
+		logAttrs []any
+		want     map[string]any
+	}{
+		{
+			name:     "disabled",
+			group:    "svc",
+			logAttrs: []any{ErrorKey, "oops"},
+			want: map[string]any{
+				MessageKey: "msg",
+				"svc":      map[string]any{ErrorKey: "oops"},
+			},
+		},
+		{
+			name:     "WithGroup",
+			enabled:  true,
+			group:    "svc",
+			logAttrs: []any{ErrorKey, errors.New("oops")},
+			want: map[string]any{
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				MessageKey:         "oops",
+				"svc":              map[string]any{ErrorKey: "oops"},
+			},
+		},
+		{
+			name:     "group value",
+			enabled:  true,
+			logAttrs: []any{slog.Group("req", slog.Group("db", ErrorKey, "oops"))},
+			want: map[string]any{
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				MessageKey:         "oops",
+				"req":              map[string]any{"db": map[string]any{ErrorKey: "oops"}},
+			},
+		},
+		{
+			name:     "top-level takes precedence",
+			enabled:  true,
+			logAttrs: []any{ErrorKey, "top", slog.Group("req", ErrorKey, "grouped")},
+			want: map[string]any{
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				MessageKey:         "top",
+				ErrorKey:           "top",
+				"req":              map[string]any{ErrorKey: "grouped"},
+			},
+		},
+		{
+			name:     "top-level takes precedence, when last",
+			enabled:  true,
+			logAttrs: []any{slog.Group("req", ErrorKey, "grouped"), ErrorKey, "top"},
+			want: map[string]any{
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				MessageKey:         "top",
+				ErrorKey:           "top",
+				"req":              map[string]any{ErrorKey: "grouped"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(NewErrorReportingHandler(&buf, nil, WithGroupedErrors(tt.enabled)))
+			if tt.group != "" {
+				logger = logger.WithGroup(tt.group)
+			}
+			logger.Error("msg", tt.logAttrs...)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			delete(got, TimeKey)
+			delete(got, SeverityKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_autoReportLocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		err      any
+		wantLine bool
+		want     *ReportLocation
+	}{
+		{
+			name:    "disabled",
+			enabled: false,
+			err:     errors.New("sentinel"),
+			want:    nil,
+		},
+		{
+			name:     "sentinel error",
+			enabled:  true,
+			err:      errors.New("sentinel"),
+			wantLine: true,
+		},
+		{
+			name:     "string",
+			enabled:  true,
+			err:      "oops",
+			wantLine: true,
+		},
+		{
+			name:    "own report location",
+			enabled: true,
+			err:     mockReportLocationError{},
+			want:    &mockReportLocation,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(NewErrorReportingHandler(&buf, nil, WithAutoReportLocation(tt.enabled)))
+			_, file, line, _ := runtime.Caller(0)
+			logger.Error("msg", ErrorKey, tt.err) // line + 1
+
+			var got struct {
+				ReportLocation *ReportLocation `json:"reportLocation"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			want := tt.want
+			if tt.wantLine {
+				want = &ReportLocation{
+					FilePath:     file,
+					LineNumber:   line + 1,
+					FunctionName: "github.com/zitadel/sloggcp.TestHandler_autoReportLocation.func1",
+				}
+			}
+			if !reflect.DeepEqual(got.ReportLocation, want) {
+				t.Errorf("reportLocation = %+v, want %+v", got.ReportLocation, want)
+			}
+		})
+	}
+}
+
+func TestWithErrorReportSeverity(t *testing.T) {
+	toCustom := func(_ []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.LevelKey {
+			return slog.String(a.Key, "CUSTOM")
+		}
+		return a
+	}
+	tests := []struct {
+		name    string
+		enabled bool
+		opts    *slog.HandlerOptions
+		level   slog.Level
+		attrs   []any
+		want    string
+	}{
+		{
+			name:  "disabled",
+			level: LevelInfo,
+			attrs: []any{ErrorKey, errors.New("partial failure")},
+			want:  InfoSeverity,
+		},
+		{
+			name:    "raised",
+			enabled: true,
+			level:   LevelInfo,
+			attrs:   []any{ErrorKey, errors.New("partial failure")},
+			want:    ErrorSeverity,
+		},
+		{
+			name:    "grouped error raised",
+			enabled: true,
+			level:   LevelWarning,
+			attrs:   []any{slog.Group("g", ErrorKey, errors.New("partial failure"))},
+			want:    WarningSeverity, // grouped errors are not reported by default
+		},
+		{
+			name:    "higher kept",
+			enabled: true,
+			level:   LevelCritical,
+			attrs:   []any{ErrorKey, errors.New("outage")},
+			want:    CriticalSeverity,
+		},
+		{
+			name:    "no error",
+			enabled: true,
+			level:   LevelInfo,
+			want:    InfoSeverity,
+		},
+		{
+			name:    "custom severity kept",
+			enabled: true,
+			opts:    &slog.HandlerOptions{ReplaceAttr: toCustom},
+			level:   LevelInfo,
+			attrs:   []any{ErrorKey, errors.New("partial failure")},
+			want:    "CUSTOM",
+		},
+		{
+			name:    "deep groups",
+			enabled: true,
+			level:   LevelInfo,
+			attrs: []any{
+				ErrorKey, errors.New("partial failure"),
+				slog.Group("a", slog.Group("b", slog.Group("c", slog.Group("d", slog.Group("e", "k", 1))))),
+			},
+			want: ErrorSeverity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := NewErrorReportingHandler(&buf, tt.opts, WithErrorReportSeverity(tt.enabled))
+			slog.New(h).Log(t.Context(), tt.level, "msg", tt.attrs...)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			if got[SeverityKey] != tt.want {
+				t.Errorf("severity = %v, want %v", got[SeverityKey], tt.want)
+			}
+		})
+	}
+}
+
+// statusError is a [SeverityError] with an HTTP status code.
+type statusError struct {
+	code int
+}
+
+func (e statusError) Error() string {
+	return http.StatusText(e.code)
+}
+
+func (e statusError) Severity() slog.Level {
+	if e.code < http.StatusInternalServerError {
+		return LevelWarning
+	}
+	return LevelError
+}
+
+func TestSeverityError(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		level   slog.Level
+		attrs   []any
+		want    map[string]any
+	}{
+		{
+			name:  "client error",
+			level: LevelError,
+			attrs: []any{ErrorKey, statusError{http.StatusNotFound}},
+			want:  map[string]any{SeverityKey: WarningSeverity},
+		},
+		{
+			name:  "server error",
+			level: LevelInfo,
+			attrs: []any{ErrorKey, statusError{http.StatusBadGateway}},
+			want:  map[string]any{SeverityKey: ErrorSeverity},
+		},
+		{
+			name:  "wrapped",
+			level: LevelError,
+			attrs: []any{ErrorKey, fmt.Errorf("get user: %w", statusError{http.StatusNotFound})},
+			want:  map[string]any{SeverityKey: WarningSeverity},
+		},
+		{
+			name:    "precedence over error report severity",
+			options: []Option{WithErrorReportSeverity(true)},
+			level:   LevelInfo,
+			attrs:   []any{ErrorKey, statusError{http.StatusBadRequest}},
+			want:    map[string]any{SeverityKey: WarningSeverity},
+		},
+		{
+			name:    "severity number",
+			options: []Option{WithSeverityNumber(true)},
+			level:   LevelError,
+			attrs:   []any{ErrorKey, statusError{http.StatusNotFound}},
+			want:    map[string]any{SeverityKey: WarningSeverity, SeverityNumberKey: float64(400)},
+		},
+		{
+			name:  "not an error report",
+			level: LevelError,
+			attrs: []any{"cause", statusError{http.StatusNotFound}},
+			want:  map[string]any{SeverityKey: ErrorSeverity},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			slog.New(New(&buf, tt.options...)).Log(t.Context(), tt.level, "msg", tt.attrs...)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			for key, want := range tt.want {
+				if got[key] != want {
+					t.Errorf("%s = %v, want %v", key, got[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_deepGroups(t *testing.T) {
+	// Opening more groups than preallocated must not lose top-level fields added afterwards.
+	for range 2 {
+		var buf bytes.Buffer
+		logger := slog.New(NewErrorReportingHandler(&buf, nil)).
+			WithGroup("a").WithGroup("b").WithGroup("c").WithGroup("d").WithGroup("e").WithGroup("f")
+		logger.Info("msg", "k", 1, Labels(map[string]string{"l": "v"}))
+
+		var got map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("Failed to decode log output: %v", err)
+		}
+		if want := map[string]any{"l": "v"}; !reflect.DeepEqual(got[LabelsKey], want) {
+			t.Errorf("labels = %v, want %v", got[LabelsKey], want)
+		}
+	}
+}
+
+func TestWithLogMessageKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		message string
+		attrs   []any
+		want    map[string]any
+	}{
+		{
+			name:    "disabled",
+			message: "request failed",
+			attrs:   []any{ErrorKey, errors.New("oops")},
+			want:    map[string]any{MessageKey: "oops"},
+		},
+		{
+			name:    "preserved",
+			key:     LogMessageKey,
+			message: "request failed",
+			attrs:   []any{ErrorKey, errors.New("oops")},
+			want:    map[string]any{MessageKey: "oops", LogMessageKey: "request failed"},
+		},
+		{
+			name:  "empty message",
+			key:   LogMessageKey,
+			attrs: []any{ErrorKey, errors.New("oops")},
+			want:  map[string]any{MessageKey: "oops"},
+		},
+		{
+			name:    "no error report",
+			key:     LogMessageKey,
+			message: "all good",
+			want:    map[string]any{MessageKey: "all good"},
+		},
+		{
+			name:    "overrides attribute",
+			key:     "msg",
+			message: "request failed",
+			attrs:   []any{"msg", "attribute", ErrorKey, errors.New("oops")},
+			want:    map[string]any{MessageKey: "oops", "msg": "request failed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			slog.New(NewErrorReportingHandler(&buf, nil, WithLogMessageKey(tt.key))).Error(tt.message, tt.attrs...)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			for _, key := range []string{TimeKey, SeverityKey, ErrorKey, ErrorReportTypeKey} {
+				delete(got, key)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// orderedError expands into a group whose attributes are not sorted by key.
+type orderedError struct{}
+
+func (orderedError) Error() string { return "ordered" }
+
+func (orderedError) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("op", "read"),
+		slog.String("code", "first"),
+		slog.Group("cause", "path", "/tmp", "errno", 2),
+		slog.String("code", "second"),
+		slog.String("attempt", "3"),
+	)
+}
+
+func TestWithOrderedErrorValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		want    string
+	}{
+		{
+			name: "default",
+			want: `"error":{"attempt":"3","cause":{"errno":2,"path":"/tmp"},"code":"second","op":"read"}`,
+		},
+		{
+			name:    "ordered",
+			options: []Option{WithOrderedErrorValue(true)},
+			want:    `"error":{"op":"read","code":"second","cause":{"path":"/tmp","errno":2},"attempt":"3"}`,
+		},
+		{
+			name:    "ordered, duplicate keys",
+			options: []Option{WithOrderedErrorValue(true), WithDuplicateKeys(true)},
+			want:    `"error":{"op":"read","code#1":"first","code":"second","cause":{"path":"/tmp","errno":2},"attempt":"3"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			slog.New(New(&buf, tt.options...)).Error("msg", ErrorKey, orderedError{}, "other", slog.GroupValue(slog.Int("b", 1), slog.Int("a", 2)))
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("log output = %s, want %s", buf.String(), tt.want)
+			}
+			if want := `"other":{"a":2,"b":1}`; !strings.Contains(buf.String(), want) {
+				t.Errorf("log output = %s, want other groups sorted %s", buf.String(), want)
+			}
+		})
+	}
+}
+
+func TestWithErrorValueEncoder(t *testing.T) {
+	errorString := func(value any) (any, bool) {
+		if err, ok := value.(error); ok {
+			return err.Error(), true
+		}
+		return nil, false
+	}
+	valuer := mockStackAndReportValuer{}
+	tests := []struct {
+		name    string
+		encoder func(any) (any, bool)
+		attrs   []any
+		want    map[string]any
+	}{
+		{
+			name:  "default",
+			attrs: []any{ErrorKey, valuer},
+			want:  map[string]any{ErrorKey: map[string]any{"key1": "value1", "key2": float64(42)}},
+		},
+		{
+			name:    "encoded",
+			encoder: errorString,
+			attrs:   []any{ErrorKey, valuer, "errorDetails", valuer},
+			want: map[string]any{
+				ErrorKey:       valuer.Error(),
+				"errorDetails": map[string]any{"key1": "value1", "key2": float64(42)},
+			},
+		},
+		{
+			name:    "structured",
+			encoder: func(value any) (any, bool) { return map[string]any{"type": fmt.Sprintf("%T", value)}, true },
+			attrs:   []any{ErrorKey, errors.New("oops")},
+			want:    map[string]any{ErrorKey: map[string]any{"type": "*errors.errorString"}},
+		},
+		{
+			name:    "not encoded",
+			encoder: errorString,
+			attrs:   []any{ErrorKey, "message"},
+			want:    map[string]any{ErrorKey: "message"},
+		},
+		{
+			name:    "grouped error kept",
+			encoder: func(any) (any, bool) { return "encoded", true },
+			attrs:   []any{slog.Group("g", ErrorKey, errors.New("oops"))},
+			want:    map[string]any{"g": map[string]any{ErrorKey: "oops"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := New(&buf, WithErrorValueEncoder(tt.encoder), WithGroupedErrors(true))
+			slog.New(h).Error("msg", tt.attrs...)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			for _, key := range []string{TimeKey, SeverityKey, MessageKey, ErrorReportTypeKey, ReportLocationKey} {
+				delete(got, key)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithErrorReportType(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		want    string
+	}{
+		{
+			name: "default",
+			want: ErrorReportTypeValue,
+		},
+		{
+			name:    "custom",
+			options: []Option{WithErrorReportType("type.googleapis.com/example.v2.ErrorEvent")},
+			want:    "type.googleapis.com/example.v2.ErrorEvent",
+		},
+		{
+			name:    "empty",
+			options: []Option{WithErrorReportType("")},
+			want:    ErrorReportTypeValue,
+		},
+		{
+			name:    "without",
+			options: []Option{WithoutErrorReportType()},
+		},
+		{
+			name:    "without, then custom",
+			options: []Option{WithoutErrorReportType(), WithErrorReportType("type.googleapis.com/example.v2.ErrorEvent")},
+			want:    "type.googleapis.com/example.v2.ErrorEvent",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			slog.New(NewErrorReportingHandler(&buf, nil, tt.options...)).Error("msg", ErrorKey, NewError("oops"))
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			if typ, ok := got[ErrorReportTypeKey]; tt.want == "" && ok || tt.want != "" && typ != tt.want {
+				t.Errorf("%s = %v, want %q", ErrorReportTypeKey, typ, tt.want)
+			}
+			if message, _ := got[MessageKey].(string); !strings.HasPrefix(message, "oops\ngoroutine ") {
+				t.Errorf("%s = %q, want error message and stack trace", MessageKey, message)
+			}
+			if _, ok := got[ReportLocationKey]; !ok {
+				t.Errorf("%s missing", ReportLocationKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package sloggcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func newTestError() *Error {
+	return NewError("failed") // report location line
+}
+
+func TestNewError(t *testing.T) {
+	err := newTestError()
+	if err.Error() != "failed" {
+		t.Errorf("Error() = %q, want %q", err.Error(), "failed")
+	}
+	if err.Unwrap() != nil {
+		t.Errorf("Unwrap() = %v, want nil", err.Unwrap())
+	}
+	loc := err.ReportLocation()
+	if loc == nil || !strings.HasSuffix(loc.FunctionName, "sloggcp.newTestError") ||
+		!strings.HasSuffix(loc.FilePath, "error_test.go") || loc.LineNumber != 14 {
+		t.Errorf("ReportLocation() = %+v", loc)
+	}
+	trace, ok := err.StackTrace()
+	if !ok {
+		t.Fatal("StackTrace() ok = false")
+	}
+	if !bytes.HasPrefix(trace, []byte("goroutine 1 [running]:\ngithub.com/zitadel/sloggcp.newTestError(...)\n")) ||
+		!bytes.Contains(trace, []byte("sloggcp.TestNewError(...)")) {
+		t.Errorf("StackTrace() = %s", trace)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	if err := Wrap(nil, "msg"); err != nil {
+		t.Errorf("Wrap(nil) = %v, want nil", err)
+	}
+	err := Wrap(io.EOF, "read")
+	if err.Error() != "read: EOF" {
+		t.Errorf("Error() = %q, want %q", err.Error(), "read: EOF")
+	}
+	if !errors.Is(err, io.EOF) {
+		t.Error("errors.Is(err, io.EOF) = false")
+	}
+	var e *Error
+	if !errors.As(err, &e) || !strings.HasSuffix(e.ReportLocation().FunctionName, "sloggcp.TestWrap") {
+		t.Errorf("errors.As(err, *Error) = %v", e)
+	}
+}
+
+func TestError_errorReport(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(NewErrorReportingHandler(&buf, nil)).Error("", ErrorKey, Wrap(newTestError(), "outer"))
+
+	var got struct {
+		Message        string         `json:"message"`
+		ReportLocation ReportLocation `json:"reportLocation"`
+		Error          string         `json:"error"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode log output: %v", err)
+	}
+	if got.Error != "outer: failed" || !strings.HasPrefix(got.Message, "outer: failed\ngoroutine 1 [running]:\n") {
+		t.Errorf("log output = %+v", got)
+	}
+	if !strings.HasSuffix(got.ReportLocation.FunctionName, "sloggcp.TestError_errorReport") {
+		t.Errorf("reportLocation = %+v, want location of outer error", got.ReportLocation)
+	}
+}
@@ -0,0 +1,65 @@
+package sloggcp
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// WithGroupSeparator flattens groups into top-level fields, with the keys of the enclosing groups
+// joined by separator, for log analysis tools which do not support nested objects.
+// For example, with separator ".", the attribute "method" of a logger derived with WithGroup("http")
+// is written as "http.method", instead of {"http":{"method":...}}.
+// Groups added through WithGroup and attributes with group values are flattened alike.
+//
+// The flattened keys are regular attribute keys, so the precedence of [WithDuplicateKeys] applies:
+// when keys collide, such as an attribute "http.method" and a member "method" of a group "http",
+// the last one added wins, or all of them are kept when enabled.
+// Special attributes, such as labels, operations and errors, are recognized as without flattening.
+// So an error in a group only creates an error report with [WithGroupedErrors],
+// while the error attribute keeps its flattened key.
+// [slog.HandlerOptions.ReplaceAttr] and the [Redactor] receive the group keys as usual.
+// Groups nested deeper than [WithMaxDepth] allows are replaced by a marker.
+// By default, or when separator is empty, groups are written as nested objects.
+func WithGroupSeparator(separator string) Option {
+	return func(c *config) {
+		c.groupSeparator = separator
+	}
+}
+
+// addFlatAttr adds an attribute to the top-level object, flattening group values,
+// according to [WithGroupSeparator].
+// The attribute was already passed to ReplaceAttr and the Redactor.
+func (s *encodeState) addFlatAttr(h *handler, groups []string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() != slog.KindGroup {
+		if a.Key == "" {
+			return
+		}
+		s.top().addAttr(flatKey(groups, a.Key, h.cfg.groupSeparator), v)
+		return
+	}
+	if h.cfg.maxDepth > 0 && len(groups) >= h.cfg.maxDepth {
+		s.top().addAttr(flatKey(groups, a.Key, h.cfg.groupSeparator), slog.StringValue(maxDepthMarker))
+		return
+	}
+	if a.Key != "" {
+		groups = append(slices.Clip(groups), a.Key)
+	}
+	for _, member := range v.Group() {
+		if member = h.replaceAttr(groups, member); member.Key == "" && member.Value.Resolve().Kind() != slog.KindGroup {
+			continue
+		}
+		if member, ok := h.cfg.redact(groups, member); ok {
+			s.addFlatAttr(h, groups, member)
+		}
+	}
+}
+
+// flatKey returns the key of a flattened attribute.
+func flatKey(groups []string, key, separator string) string {
+	if len(groups) == 0 {
+		return key
+	}
+	return strings.Join(groups, separator) + separator + key
+}
@@ -0,0 +1,123 @@
+package sloggcp
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestWithGroupSeparator(t *testing.T) {
+	recordTime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	const fields = `"message":"msg","severity":"INFO","time":"2024-05-06T07:08:09Z"`
+	tests := []struct {
+		name    string
+		options []Option
+		derive  func(h slog.Handler) slog.Handler
+		attrs   []slog.Attr
+		want    string
+	}{
+		{
+			name:  "default",
+			attrs: []slog.Attr{slog.Group("http", slog.String("method", "GET"))},
+			want:  `{"http":{"method":"GET"},` + fields + `}` + "\n",
+		},
+		{
+			name:    "group value",
+			options: []Option{WithGroupSeparator(".")},
+			attrs: []slog.Attr{slog.Group("http",
+				slog.String("method", "GET"),
+				slog.Group("response", slog.Int("status", 200)),
+			)},
+			want: `{"http.method":"GET","http.response.status":200,` + fields + `}` + "\n",
+		},
+		{
+			name:    "WithGroup",
+			options: []Option{WithGroupSeparator("_")},
+			derive: func(h slog.Handler) slog.Handler {
+				return h.WithGroup("http").WithAttrs([]slog.Attr{slog.String("method", "GET")}).WithGroup("request")
+			},
+			attrs: []slog.Attr{slog.Int("size", 10)},
+			want:  `{"http_method":"GET","http_request_size":10,` + fields + `}` + "\n",
+		},
+		{
+			name:    "WithGroup without attributes",
+			options: []Option{WithGroupSeparator(".")},
+			derive: func(h slog.Handler) slog.Handler {
+				return h.WithGroup("http").WithAttrs([]slog.Attr{slog.String("method", "GET")}).WithGroup("request")
+			},
+			want: `{"http.method":"GET",` + fields + `}` + "\n",
+		},
+		{
+			name:    "inline group",
+			options: []Option{WithGroupSeparator(".")},
+			attrs: []slog.Attr{
+				slog.Group("", slog.String("a", "1")),
+				slog.Group("http", slog.Group("", slog.String("method", "GET"))),
+			},
+			want: `{"a":"1","http.method":"GET",` + fields + `}` + "\n",
+		},
+		{
+			name:    "collision",
+			options: []Option{WithGroupSeparator(".")},
+			attrs: []slog.Attr{
+				slog.String("http.method", "POST"),
+				slog.Group("http", slog.String("method", "GET")),
+			},
+			want: `{"http.method":"GET",` + fields + `}` + "\n",
+		},
+		{
+			name:    "collision kept",
+			options: []Option{WithGroupSeparator("."), WithDuplicateKeys(true)},
+			attrs: []slog.Attr{
+				slog.String("http.method", "POST"),
+				slog.Group("http", slog.String("method", "GET")),
+			},
+			want: `{"http.method#1":"POST","http.method":"GET",` + fields + `}` + "\n",
+		},
+		{
+			name:    "grouped error",
+			options: []Option{WithGroupSeparator("."), WithGroupedErrors(true)},
+			derive: func(h slog.Handler) slog.Handler {
+				return h.WithGroup("a")
+			},
+			attrs: []slog.Attr{slog.Any(ErrorKey, errors.New("oops"))},
+			want:  `{"@type":"` + ErrorReportTypeValue + `","a.error":"oops","message":"oops","severity":"INFO","time":"2024-05-06T07:08:09Z"}` + "\n",
+		},
+		{
+			name:    "max depth",
+			options: []Option{WithGroupSeparator("."), WithMaxDepth(1)},
+			attrs:   []slog.Attr{slog.Group("a", slog.Group("b", slog.Int("c", 1)))},
+			want:    `{"a.b":"\u003cmax depth exceeded\u003e",` + fields + `}` + "\n",
+		},
+		{
+			name: "ReplaceAttr groups",
+			options: []Option{WithGroupSeparator("."), WithReplaceAttr(func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) == 2 && groups[0] == "a" && groups[1] == "b" && a.Key == "secret" {
+					return slog.Attr{}
+				}
+				return a
+			})},
+			attrs: []slog.Attr{slog.Group("a", slog.Group("b", slog.Int("c", 1), slog.String("secret", "s")))},
+			want:  `{"a.b.c":1,` + fields + `}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			var h slog.Handler = New(&buf, tt.options...)
+			if tt.derive != nil {
+				h = tt.derive(h)
+			}
+			r := slog.NewRecord(recordTime, slog.LevelInfo, "msg", 0)
+			r.AddAttrs(tt.attrs...)
+			if err := h.Handle(t.Context(), r); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("log output = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package sloggcp
+
+import (
+	"bytes"
+	"log/slog"
+	"runtime"
+	"strconv"
+)
+
+// GoroutineKey is the key of the ID of the goroutine which logged the record, see [WithGoroutineID].
+const GoroutineKey = "goroutine"
+
+// WithGoroutineID writes the ID of the goroutine which logged the record under [GoroutineKey],
+// to untangle interleaved logs of concurrent goroutines when debugging.
+// Go does not expose the ID, so it is parsed from the header of [runtime.Stack] for every record,
+// which adds a noticeable cost. The ID is the one shown in panics and stack traces.
+// It is disabled by default.
+func WithGoroutineID(enabled bool) Option {
+	return func(c *config) {
+		c.goroutineID = enabled
+	}
+}
+
+// addGoroutineID adds the ID of the current goroutine to out, if enabled.
+func (c *config) addGoroutineID(out *object) {
+	if !c.goroutineID {
+		return
+	}
+	if id, ok := goroutineID(); ok {
+		out.add(GoroutineKey, slog.Uint64Value(id))
+	}
+}
+
+// goroutineID returns the ID of the current goroutine,
+// parsed from the "goroutine 123 [running]:" header of its stack trace.
+func goroutineID() (uint64, bool) {
+	var buf [64]byte
+	header := buf[:runtime.Stack(buf[:], false)]
+	header, ok := bytes.CutPrefix(header, []byte("goroutine "))
+	if !ok {
+		return 0, false
+	}
+	if i := bytes.IndexByte(header, ' '); i >= 0 {
+		header = header[:i]
+	}
+	id, err := strconv.ParseUint(string(header), 10, 64)
+	return id, err == nil
+}
@@ -0,0 +1,67 @@
+package sloggcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestWithGoroutineID(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+	}{
+		{name: "enabled", enabled: true},
+		{name: "disabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(New(&buf, WithGoroutineID(tt.enabled)))
+
+			ids := make(chan uint64, 2)
+			for range 2 {
+				go func() {
+					id, _ := goroutineID()
+					logger.Info("msg")
+					ids <- id
+				}()
+			}
+			want := map[uint64]bool{<-ids: true, <-ids: true}
+
+			dec := json.NewDecoder(&buf)
+			got := make(map[uint64]bool)
+			for dec.More() {
+				var entry map[string]any
+				if err := dec.Decode(&entry); err != nil {
+					t.Fatalf("Failed to decode log output: %v", err)
+				}
+				id, ok := entry[GoroutineKey].(float64)
+				if ok != tt.enabled {
+					t.Fatalf("%s present = %v, want %v", GoroutineKey, ok, tt.enabled)
+				}
+				got[uint64(id)] = true
+			}
+			if tt.enabled && !reflect.DeepEqual(got, want) {
+				t.Errorf("goroutine IDs = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestGoroutineID(t *testing.T) {
+	id, ok := goroutineID()
+	if !ok || id == 0 {
+		t.Fatalf("goroutineID() = %d, %v, want non-zero ID", id, ok)
+	}
+	other := make(chan uint64)
+	go func() {
+		id, _ := goroutineID()
+		other <- id
+	}()
+	if otherID := <-other; otherID == id {
+		t.Errorf("goroutineID() = %d in another goroutine, want different ID", otherID)
+	}
+}
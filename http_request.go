@@ -0,0 +1,204 @@
+package sloggcp
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPRequestKey is the key of the special field GCP uses to
+// render HTTP request details in the Logs Explorer.
+// See https://cloud.google.com/logging/docs/structured-logging#special-payload-fields.
+const HTTPRequestKey = "httpRequest"
+
+// HTTPRequest holds information about an HTTP request associated with a log entry.
+// Log it under [HTTPRequestKey] at the top level of a record,
+// so GCP picks it up:
+//
+//	logger.Info("done", sloggcp.HTTPRequestKey, req)
+//
+// Zero values are omitted from the output.
+// See https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#HttpRequest.
+type HTTPRequest struct {
+	RequestMethod                  string
+	RequestURL                     string
+	RequestSize                    int64
+	Status                         int
+	ResponseSize                   int64
+	UserAgent                      string
+	RemoteIP                       string
+	ServerIP                       string
+	Referer                        string
+	Latency                        time.Duration
+	CacheLookup                    bool
+	CacheHit                       bool
+	CacheValidatedWithOriginServer bool
+	CacheFillBytes                 int64
+	Protocol                       string
+}
+
+// NewHTTPRequest creates a [HTTPRequest] from a server side [http.Request]
+// and the response information, typically in an HTTP middleware.
+//
+// The remote IP is taken from the X-Forwarded-For header with [ClientIP],
+// with the number of proxies set through [WithTrustedProxies], 0 by default.
+// The server IP is taken from the local address stored in the request context by [http.Server].
+func NewHTTPRequest(r *http.Request, status int, responseSize int64, latency time.Duration, options ...HTTPRequestOption) HTTPRequest {
+	var cfg httpRequestConfig
+	for _, option := range options {
+		option(&cfg)
+	}
+	req := HTTPRequest{
+		RequestMethod: r.Method,
+		RequestURL:    requestURL(r),
+		Status:        status,
+		ResponseSize:  responseSize,
+		UserAgent:     r.UserAgent(),
+		RemoteIP:      ClientIP(r, cfg.trustedProxies),
+		Referer:       r.Referer(),
+		Latency:       latency,
+		Protocol:      r.Proto,
+	}
+	if r.ContentLength > 0 {
+		req.RequestSize = r.ContentLength
+	}
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		req.ServerIP = hostFromAddr(addr.String())
+	}
+	return req
+}
+
+// HTTPRequestOption configures [NewHTTPRequest].
+type HTTPRequestOption func(*httpRequestConfig)
+
+type httpRequestConfig struct {
+	trustedProxies int
+}
+
+// WithTrustedProxies sets the number of proxies in front of the server which append to the X-Forwarded-For header,
+// in addition to the one the server is reached through, to take the remote IP from, see [ClientIP].
+// It depends on the deployment:
+//   - 0, the default, on Cloud Run or behind a reverse proxy, which append the client address only.
+//   - 1 behind a Google Cloud load balancer, which appends the client address and its own address.
+//   - 1 more for every further proxy appending its client address.
+//
+// A count too high takes the remote IP from an entry which the client can set.
+func WithTrustedProxies(n int) HTTPRequestOption {
+	return func(c *httpRequestConfig) {
+		c.trustedProxies = n
+	}
+}
+
+func requestURL(r *http.Request) string {
+	if r.URL == nil {
+		return ""
+	}
+	if r.URL.IsAbs() || r.Host == "" {
+		return r.URL.String()
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+// ClientIP returns the address of the client of r, from the X-Forwarded-For header,
+// where trustedProxies is the number of trailing entries added by the proxies in front of the server.
+// Entries before those may be set by the client, so they cannot be trusted.
+//
+// A Google Cloud load balancer appends "<client-ip>,<load-balancer-ip>" to the header,
+// so the client address is the second-to-last entry, with one trusted proxy.
+// Cloud Run, or a reverse proxy in front of the server, only appends the address of its client,
+// so trustedProxies is 0 and the last entry is used. See [WithTrustedProxies].
+// If the header has fewer entries, the first one is used.
+// Without X-Forwarded-For header, or if the entry is empty, the host part of [http.Request.RemoteAddr] is returned.
+func ClientIP(r *http.Request, trustedProxies int) string {
+	var entries []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		entries = append(entries, strings.Split(value, ",")...)
+	}
+	if len(entries) > 0 {
+		client := entries[max(len(entries)-1-max(trustedProxies, 0), 0)]
+		if client = strings.TrimSpace(client); client != "" {
+			return client
+		}
+	}
+	return hostFromAddr(r.RemoteAddr)
+}
+
+// hostFromAddr returns the host part of a "host:port" address,
+// or the address itself if it has no port.
+func hostFromAddr(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// LogValue implements [slog.LogValuer].
+// Field names and formats follow the GCP HttpRequest specification.
+// 64 bit integers are formatted as strings
+// and the latency as a duration string, such as "0.123s".
+func (r HTTPRequest) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, 15)
+	addString := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, slog.String(key, value))
+		}
+	}
+	addInt64 := func(key string, value int64) {
+		if value != 0 {
+			attrs = append(attrs, slog.String(key, strconv.FormatInt(value, 10)))
+		}
+	}
+	addBool := func(key string, value bool) {
+		if value {
+			attrs = append(attrs, slog.Bool(key, value))
+		}
+	}
+
+	addString("requestMethod", r.RequestMethod)
+	addString("requestUrl", r.RequestURL)
+	addInt64("requestSize", r.RequestSize)
+	if r.Status != 0 {
+		attrs = append(attrs, slog.Int("status", r.Status))
+	}
+	addInt64("responseSize", r.ResponseSize)
+	addString("userAgent", r.UserAgent)
+	addString("remoteIp", r.RemoteIP)
+	addString("serverIp", r.ServerIP)
+	addString("referer", r.Referer)
+	if r.Latency != 0 {
+		attrs = append(attrs, slog.String("latency", formatDuration(r.Latency)))
+	}
+	addBool("cacheLookup", r.CacheLookup)
+	addBool("cacheHit", r.CacheHit)
+	addBool("cacheValidatedWithOriginServer", r.CacheValidatedWithOriginServer)
+	addInt64("cacheFillBytes", r.CacheFillBytes)
+	addString("protocol", r.Protocol)
+	return slog.GroupValue(attrs...)
+}
+
+// formatDuration formats d as the JSON representation of a
+// protobuf Duration: seconds with up to 9 fractional digits,
+// followed by the "s" suffix. For example "0.123s" or "-1.5s".
+func formatDuration(d time.Duration) string {
+	var sign string
+	secs, nanos := int64(d/time.Second), int64(d%time.Second)
+	if d < 0 {
+		sign = "-"
+		secs, nanos = -secs, -nanos
+	}
+	if nanos == 0 {
+		return sign + strconv.FormatInt(secs, 10) + "s"
+	}
+	frac := strconv.FormatInt(nanos+int64(time.Second), 10)[1:] // zero padded to 9 digits
+	for frac[len(frac)-1] == '0' {
+		frac = frac[:len(frac)-1]
+	}
+	return sign + strconv.FormatInt(secs, 10) + "." + frac + "s"
+}
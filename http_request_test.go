@@ -0,0 +1,332 @@
+package sloggcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPRequest_LogValue(t *testing.T) {
+	tests := []struct {
+		name string
+		req  HTTPRequest
+		want map[string]any
+	}{
+		{
+			name: "zero",
+			req:  HTTPRequest{},
+			want: map[string]any{},
+		},
+		{
+			name: "all fields",
+			req: HTTPRequest{
+				RequestMethod:                  "GET",
+				RequestURL:                     "https://example.com/foo?bar=baz",
+				RequestSize:                    123,
+				Status:                         200,
+				ResponseSize:                   1 << 40,
+				UserAgent:                      "curl/8.0",
+				RemoteIP:                       "192.0.2.1",
+				ServerIP:                       "192.0.2.2",
+				Referer:                        "https://example.com/",
+				Latency:                        123 * time.Millisecond,
+				CacheLookup:                    true,
+				CacheHit:                       true,
+				CacheValidatedWithOriginServer: true,
+				CacheFillBytes:                 42,
+				Protocol:                       "HTTP/1.1",
+			},
+			want: map[string]any{
+				"requestMethod":                  "GET",
+				"requestUrl":                     "https://example.com/foo?bar=baz",
+				"requestSize":                    "123",
+				"status":                         float64(200),
+				"responseSize":                   "1099511627776",
+				"userAgent":                      "curl/8.0",
+				"remoteIp":                       "192.0.2.1",
+				"serverIp":                       "192.0.2.2",
+				"referer":                        "https://example.com/",
+				"latency":                        "0.123s",
+				"cacheLookup":                    true,
+				"cacheHit":                       true,
+				"cacheValidatedWithOriginServer": true,
+				"cacheFillBytes":                 "42",
+				"protocol":                       "HTTP/1.1",
+			},
+		},
+		{
+			name: "some fields",
+			req: HTTPRequest{
+				RequestMethod: "POST",
+				Status:        500,
+				Latency:       time.Second,
+			},
+			want: map[string]any{
+				"requestMethod": "POST",
+				"status":        float64(500),
+				"latency":       "1s",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(NewErrorReportingHandler(&buf, nil))
+			logger.Info("done", HTTPRequestKey, tt.req)
+
+			var got struct {
+				HTTPRequest map[string]any `json:"httpRequest"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			if !reflect.DeepEqual(got.HTTPRequest, tt.want) {
+				t.Errorf("httpRequest = %v, want %v", got.HTTPRequest, tt.want)
+			}
+		})
+	}
+}
+
+func Test_formatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{time.Nanosecond, "0.000000001s"},
+		{time.Microsecond, "0.000001s"},
+		{123 * time.Millisecond, "0.123s"},
+		{time.Second, "1s"},
+		{1500 * time.Millisecond, "1.5s"},
+		{time.Hour + time.Nanosecond, "3600.000000001s"},
+		{time.Second + 10*time.Nanosecond, "1.00000001s"},
+		{-1500 * time.Millisecond, "-1.5s"},
+		{-time.Nanosecond, "-0.000000001s"},
+		{math.MaxInt64, "9223372036.854775807s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := formatDuration(tt.d); got != tt.want {
+				t.Errorf("formatDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type testAddr string
+
+func (a testAddr) Network() string { return "tcp" }
+func (a testAddr) String() string  { return string(a) }
+
+func TestNewHTTPRequest(t *testing.T) {
+	newRequest := func(mod func(r *http.Request)) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/foo?bar=baz", strings.NewReader("body"))
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header.Set("User-Agent", "curl/8.0")
+		r.Header.Set("Referer", "https://example.com/")
+		if mod != nil {
+			mod(r)
+		}
+		return r
+	}
+	tests := []struct {
+		name         string
+		r            *http.Request
+		status       int
+		responseSize int64
+		latency      time.Duration
+		options      []HTTPRequestOption
+		want         HTTPRequest
+	}{
+		{
+			name:         "remote address",
+			r:            newRequest(nil),
+			status:       http.StatusOK,
+			responseSize: 42,
+			latency:      123 * time.Millisecond,
+			want: HTTPRequest{
+				RequestMethod: http.MethodPost,
+				RequestURL:    "http://example.com/foo?bar=baz",
+				RequestSize:   4,
+				Status:        http.StatusOK,
+				ResponseSize:  42,
+				UserAgent:     "curl/8.0",
+				RemoteIP:      "192.0.2.1",
+				Referer:       "https://example.com/",
+				Latency:       123 * time.Millisecond,
+				Protocol:      "HTTP/1.1",
+			},
+		},
+		{
+			name: "Cloud Run",
+			r: newRequest(func(r *http.Request) {
+				r.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.1")
+			}),
+			status: http.StatusOK,
+			want: HTTPRequest{
+				RequestMethod: http.MethodPost,
+				RequestURL:    "http://example.com/foo?bar=baz",
+				RequestSize:   4,
+				Status:        http.StatusOK,
+				UserAgent:     "curl/8.0",
+				RemoteIP:      "203.0.113.1",
+				Referer:       "https://example.com/",
+				Protocol:      "HTTP/1.1",
+			},
+		},
+		{
+			name: "load balancer",
+			r: newRequest(func(r *http.Request) {
+				r.Header.Set("X-Forwarded-For", " 198.51.100.1 , 203.0.113.1")
+			}),
+			status:  http.StatusOK,
+			options: []HTTPRequestOption{WithTrustedProxies(1)},
+			want: HTTPRequest{
+				RequestMethod: http.MethodPost,
+				RequestURL:    "http://example.com/foo?bar=baz",
+				RequestSize:   4,
+				Status:        http.StatusOK,
+				UserAgent:     "curl/8.0",
+				RemoteIP:      "198.51.100.1",
+				Referer:       "https://example.com/",
+				Protocol:      "HTTP/1.1",
+			},
+		},
+		{
+			name: "spoofed X-Forwarded-For",
+			r: newRequest(func(r *http.Request) {
+				r.Header.Set("X-Forwarded-For", "10.0.0.1, 198.51.100.1, 203.0.113.1")
+			}),
+			status:  http.StatusOK,
+			options: []HTTPRequestOption{WithTrustedProxies(1)},
+			want: HTTPRequest{
+				RequestMethod: http.MethodPost,
+				RequestURL:    "http://example.com/foo?bar=baz",
+				RequestSize:   4,
+				Status:        http.StatusOK,
+				UserAgent:     "curl/8.0",
+				RemoteIP:      "198.51.100.1",
+				Referer:       "https://example.com/",
+				Protocol:      "HTTP/1.1",
+			},
+		},
+		{
+			name: "empty X-Forwarded-For",
+			r: newRequest(func(r *http.Request) {
+				r.Header.Set("X-Forwarded-For", " , 203.0.113.1")
+			}),
+			status:  http.StatusOK,
+			options: []HTTPRequestOption{WithTrustedProxies(1)},
+			want: HTTPRequest{
+				RequestMethod: http.MethodPost,
+				RequestURL:    "http://example.com/foo?bar=baz",
+				RequestSize:   4,
+				Status:        http.StatusOK,
+				UserAgent:     "curl/8.0",
+				RemoteIP:      "192.0.2.1",
+				Referer:       "https://example.com/",
+				Protocol:      "HTTP/1.1",
+			},
+		},
+		{
+			name: "TLS and server IP",
+			r: newRequest(func(r *http.Request) {
+				r.TLS = &tls.ConnectionState{}
+				r.RemoteAddr = "[2001:db8::1]:1234"
+				*r = *r.WithContext(context.WithValue(r.Context(), http.LocalAddrContextKey, testAddr("[2001:db8::2]:443")))
+			}),
+			status: http.StatusNotFound,
+			want: HTTPRequest{
+				RequestMethod: http.MethodPost,
+				RequestURL:    "https://example.com/foo?bar=baz",
+				RequestSize:   4,
+				Status:        http.StatusNotFound,
+				UserAgent:     "curl/8.0",
+				RemoteIP:      "2001:db8::1",
+				ServerIP:      "2001:db8::2",
+				Referer:       "https://example.com/",
+				Protocol:      "HTTP/1.1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewHTTPRequest(tt.r, tt.status, tt.responseSize, tt.latency, tt.options...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewHTTPRequest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		header         []string
+		trustedProxies int
+		want           string
+	}{
+		{
+			name:           "no header",
+			trustedProxies: 1,
+			want:           "192.0.2.1",
+		},
+		{
+			name:           "load balancer",
+			header:         []string{"198.51.100.1, 203.0.113.1"},
+			trustedProxies: 1,
+			want:           "198.51.100.1",
+		},
+		{
+			name:           "load balancer spoofed",
+			header:         []string{"10.0.0.1,198.51.100.1,203.0.113.1"},
+			trustedProxies: 1,
+			want:           "198.51.100.1",
+		},
+		{
+			name:           "reverse proxy spoofed",
+			header:         []string{"10.0.0.1, 198.51.100.1"},
+			trustedProxies: 0,
+			want:           "198.51.100.1",
+		},
+		{
+			name:           "multiple headers",
+			header:         []string{"10.0.0.1", "198.51.100.1, 203.0.113.1"},
+			trustedProxies: 1,
+			want:           "198.51.100.1",
+		},
+		{
+			name:           "fewer entries than proxies",
+			header:         []string{"198.51.100.1"},
+			trustedProxies: 2,
+			want:           "198.51.100.1",
+		},
+		{
+			name:           "empty entry",
+			header:         []string{"198.51.100.1,,203.0.113.1"},
+			trustedProxies: 1,
+			want:           "192.0.2.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			for _, value := range tt.header {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := ClientIP(r, tt.trustedProxies); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
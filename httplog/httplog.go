@@ -0,0 +1,200 @@
+// Package httplog provides an HTTP middleware which logs one entry per request with the sloggcp handler,
+// with the request details rendered by the Logs Explorer and the trace of the request.
+package httplog
+
+import (
+	"bufio"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/zitadel/sloggcp"
+)
+
+// Option configures the [Middleware].
+type Option func(*config)
+
+type config struct {
+	projectID      string
+	trustedProxies int
+	skip           []func(*http.Request) bool
+}
+
+// WithProjectID qualifies the trace IDs with the project ID, as Cloud Logging requires for grouping,
+// see [sloggcp.TraceLogger].
+func WithProjectID(projectID string) Option {
+	return func(c *config) {
+		c.projectID = projectID
+	}
+}
+
+// WithTrustedProxies sets the number of proxies in front of the server, which append to the X-Forwarded-For header,
+// to take the remote IP of the request from, see [sloggcp.WithTrustedProxies] for the count of each deployment.
+// The default is 0, for Cloud Run reached directly.
+func WithTrustedProxies(n int) Option {
+	return func(c *config) {
+		c.trustedProxies = n
+	}
+}
+
+// WithSkipPaths skips logging requests to the paths, such as health checks polled by a load balancer.
+// The paths are compared to the path of the request URL exactly.
+// The request still carries the request logger.
+func WithSkipPaths(paths ...string) Option {
+	return WithSkip(func(r *http.Request) bool {
+		return slices.Contains(paths, r.URL.Path)
+	})
+}
+
+// WithSkip skips logging requests for which skip returns true.
+// It is called before the request is served. The request still carries the request logger.
+// All functions set through WithSkip and [WithSkipPaths] are consulted.
+func WithSkip(skip func(r *http.Request) bool) Option {
+	return func(c *config) {
+		c.skip = append(c.skip, skip)
+	}
+}
+
+// Middleware returns a function which wraps an [http.Handler], to log one entry per request with logger:
+//
+//	handler = httplog.Middleware(logger, httplog.WithSkipPaths("/healthz"))(handler)
+//
+// The entry is logged when the wrapped handler returns. Its message is the method and path of the request,
+// such as "GET /users", and it carries the [sloggcp.HTTPRequest] under [sloggcp.HTTPRequestKey],
+// with the status and size of the response, captured by a [ResponseWriter], and the latency.
+// The level is [slog.LevelError] for server errors with status 5xx, [slog.LevelWarn]
+// for client errors with status 4xx and [slog.LevelInfo] otherwise.
+// No entry is logged if the handler panics.
+//
+// The trace is read from the [sloggcp.CloudTraceContextHeader] by [sloggcp.TraceLogger].
+// The request context carries the trace, see [sloggcp.TraceFromContext],
+// and a logger writing the trace fields on every record, see [sloggcp.LoggerFromContext].
+// So the entries logged by the handler are grouped with the request entry in the Logs Explorer.
+// Unlike with [sloggcp.RequestLogger], the request details are only logged with the request entry.
+// The trace fields and the request details are written at the top level, even if logger has groups.
+func Middleware(logger *slog.Logger, options ...Option) func(http.Handler) http.Handler {
+	cfg := new(config)
+	for _, option := range options {
+		option(cfg)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ctx, reqLogger := sloggcp.TraceLogger(r, logger, cfg.projectID)
+			if cfg.skipped(r) {
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			rw := NewResponseWriter(w)
+			next.ServeHTTP(rw, r.WithContext(ctx))
+			req := sloggcp.NewHTTPRequest(r, rw.Status(), rw.Size(), time.Since(start), sloggcp.WithTrustedProxies(cfg.trustedProxies))
+			reqLogger.LogAttrs(sloggcp.ContextWithHTTPRequest(ctx, req), statusLevel(rw.Status()), r.Method+" "+r.URL.Path)
+		})
+	}
+}
+
+// skipped reports whether logging r is skipped, see [WithSkip].
+func (c *config) skipped(r *http.Request) bool {
+	return slices.ContainsFunc(c.skip, func(skip func(*http.Request) bool) bool {
+		return skip(r)
+	})
+}
+
+// statusLevel returns the level of the request entry for a response status.
+func statusLevel(status int) slog.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return slog.LevelError
+	case status >= http.StatusBadRequest:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// ResponseWriter wraps an [http.ResponseWriter], to capture the status and size of the response,
+// as used by the [Middleware]. It can wrap the writers of other middlewares, or be wrapped by them.
+// It implements [http.Flusher] and [http.Hijacker], if the wrapped writer does,
+// and supports [http.ResponseController] through Unwrap.
+type ResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+var (
+	_ http.Flusher  = (*ResponseWriter)(nil)
+	_ http.Hijacker = (*ResponseWriter)(nil)
+)
+
+// NewResponseWriter returns a [ResponseWriter] wrapping w.
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+// WriteHeader implements [http.ResponseWriter].
+// The first final status is captured. Informational status codes, such as 103 Early Hints, are passed on only.
+func (w *ResponseWriter) WriteHeader(status int) {
+	if w.status == 0 && (status >= http.StatusOK || status == http.StatusSwitchingProtocols) {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements [http.ResponseWriter].
+// Like the [http.ResponseWriter] of the server, it implies the status 200 OK, if no status was written.
+func (w *ResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush implements [http.Flusher], if the wrapped writer does, and is a no-op otherwise.
+func (w *ResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Hijack implements [http.Hijacker], if the wrapped writer does,
+// and returns [http.ErrNotSupported] otherwise.
+// A hijacked connection is logged with the status 101 Switching Protocols, unless a status was written before.
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the wrapped writer, for [http.ResponseController].
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status returns the status of the response, or 200 OK if the handler did not write any,
+// as the server sends it then.
+func (w *ResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Size returns the number of bytes of the response body written so far.
+func (w *ResponseWriter) Size() int64 {
+	return w.size
+}
@@ -0,0 +1,246 @@
+package httplog
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/zitadel/sloggcp"
+	"github.com/zitadel/sloggcp/sloggcptest"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name         string
+		options      []Option
+		group        string // of the logger passed to the middleware
+		path         string
+		header       string
+		forwardedFor string
+		handler      http.HandlerFunc
+		wantEntries  int
+		wantSeverity string
+		wantRequest  map[string]any
+		wantTrace    map[string]any
+	}{
+		{
+			name: "ok",
+			path: "/users",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("hello"))
+			},
+			wantEntries:  1,
+			wantSeverity: sloggcp.InfoSeverity,
+			wantRequest:  map[string]any{"status": float64(200), "responseSize": "5"},
+		},
+		{
+			name:         "no body",
+			path:         "/users",
+			handler:      func(http.ResponseWriter, *http.Request) {},
+			wantEntries:  1,
+			wantSeverity: sloggcp.InfoSeverity,
+			wantRequest:  map[string]any{"status": float64(200)},
+		},
+		{
+			name: "client error",
+			path: "/users/1",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.NotFound(w, r)
+			},
+			wantEntries:  1,
+			wantSeverity: sloggcp.WarningSeverity,
+			wantRequest:  map[string]any{"status": float64(404), "responseSize": "19"},
+		},
+		{
+			name: "server error",
+			path: "/users",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			wantEntries:  1,
+			wantSeverity: sloggcp.ErrorSeverity,
+			wantRequest:  map[string]any{"status": float64(503)},
+		},
+		{
+			name:    "skipped path",
+			options: []Option{WithSkipPaths("/healthz", "/readyz")},
+			path:    "/healthz",
+			handler: func(http.ResponseWriter, *http.Request) {},
+		},
+		{
+			name: "skip",
+			options: []Option{WithSkipPaths("/healthz"), WithSkip(func(r *http.Request) bool {
+				return r.Method == http.MethodGet
+			})},
+			path:    "/users",
+			handler: func(http.ResponseWriter, *http.Request) {},
+		},
+		{
+			name:    "trace",
+			options: []Option{WithProjectID("my-project")},
+			path:    "/users",
+			header:  "105445aa7843bc8bf206b12000100000/74;o=1",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				sloggcp.LoggerFromContext(r.Context()).Info("handling")
+			},
+			wantEntries:  2,
+			wantSeverity: sloggcp.InfoSeverity,
+			wantRequest:  map[string]any{"status": float64(200)},
+			wantTrace: map[string]any{
+				sloggcp.TraceKey:        "projects/my-project/traces/105445aa7843bc8bf206b12000100000",
+				sloggcp.SpanIDKey:       "000000000000004a",
+				sloggcp.TraceSampledKey: true,
+			},
+		},
+		{
+			name:         "load balancer",
+			options:      []Option{WithTrustedProxies(1)},
+			path:         "/users",
+			forwardedFor: "10.0.0.1, 198.51.100.1, 203.0.113.1",
+			handler:      func(http.ResponseWriter, *http.Request) {},
+			wantEntries:  1,
+			wantSeverity: sloggcp.InfoSeverity,
+			wantRequest:  map[string]any{"status": float64(200), "remoteIp": "198.51.100.1"},
+		},
+		{
+			name:         "Cloud Run",
+			path:         "/users",
+			forwardedFor: "10.0.0.1, 198.51.100.1",
+			handler:      func(http.ResponseWriter, *http.Request) {},
+			wantEntries:  1,
+			wantSeverity: sloggcp.InfoSeverity,
+			wantRequest:  map[string]any{"status": float64(200), "remoteIp": "198.51.100.1"},
+		},
+		{
+			name:    "grouped logger",
+			options: []Option{WithProjectID("my-project")},
+			group:   "app",
+			path:    "/users",
+			header:  "105445aa7843bc8bf206b12000100000/74",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				sloggcp.LoggerFromContext(r.Context()).Info("handling", "a", 1)
+			},
+			wantEntries:  2,
+			wantSeverity: sloggcp.InfoSeverity,
+			wantRequest:  map[string]any{"status": float64(200)},
+			wantTrace: map[string]any{
+				sloggcp.TraceKey:  "projects/my-project/traces/105445aa7843bc8bf206b12000100000",
+				sloggcp.SpanIDKey: "000000000000004a",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := sloggcptest.NewHandler(nil)
+			logger := slog.New(h)
+			if tt.group != "" {
+				logger = logger.WithGroup(tt.group)
+			}
+			handler := Middleware(logger, tt.options...)(tt.handler)
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				r.Header.Set(sloggcp.CloudTraceContextHeader, tt.header)
+			}
+			if tt.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			entries := h.Entries()
+			if len(entries) != tt.wantEntries {
+				t.Fatalf("entries = %d, want %d", len(entries), tt.wantEntries)
+			}
+			if tt.wantEntries == 0 {
+				return
+			}
+			for i, entry := range entries {
+				for key, want := range tt.wantTrace {
+					if got := entry.Fields[key]; got != want {
+						t.Errorf("entry %d: %s = %v, want %v", i, key, got, want)
+					}
+				}
+				if _, ok := entry.Fields[sloggcp.HTTPRequestKey]; ok != (i == len(entries)-1) {
+					t.Errorf("entry %d: %s present = %v", i, sloggcp.HTTPRequestKey, ok)
+				}
+			}
+			entry := entries[len(entries)-1]
+			if entry.Severity != tt.wantSeverity {
+				t.Errorf("severity = %v, want %v", entry.Severity, tt.wantSeverity)
+			}
+			if want := "GET " + tt.path; entry.Message != want {
+				t.Errorf("message = %q, want %q", entry.Message, want)
+			}
+			req, _ := entry.Fields[sloggcp.HTTPRequestKey].(map[string]any)
+			if _, ok := req["latency"]; !ok {
+				t.Errorf("%s = %v, want latency", sloggcp.HTTPRequestKey, req)
+			}
+			delete(req, "latency")
+			wantRequest := map[string]any{
+				"requestMethod": "GET",
+				"requestUrl":    "http://example.com" + tt.path,
+				"remoteIp":      "192.0.2.1",
+				"protocol":      "HTTP/1.1",
+			}
+			for key, value := range tt.wantRequest {
+				wantRequest[key] = value
+			}
+			if !reflect.DeepEqual(req, wantRequest) {
+				t.Errorf("%s = %v, want %v", sloggcp.HTTPRequestKey, req, wantRequest)
+			}
+		})
+	}
+}
+
+func TestResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewResponseWriter(rec)
+	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(http.StatusInternalServerError) // superfluous, ignored by the server
+	if _, err := w.Write([]byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		t.Errorf("Flush() = %v", err)
+	}
+	if got := w.Status(); got != http.StatusCreated {
+		t.Errorf("Status() = %d, want %d", got, http.StatusCreated)
+	}
+	if got := w.Size(); got != 3 {
+		t.Errorf("Size() = %d, want 3", got)
+	}
+	if !rec.Flushed {
+		t.Error("wrapped writer not flushed")
+	}
+	if _, _, err := w.Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("Hijack() = %v, want %v", err, http.ErrNotSupported)
+	}
+}
+
+// headerWriter records the status codes written.
+type headerWriter struct {
+	http.ResponseWriter
+	codes []int
+}
+
+func (w *headerWriter) WriteHeader(status int) {
+	w.codes = append(w.codes, status)
+}
+
+func TestResponseWriter_informational(t *testing.T) {
+	hw := new(headerWriter)
+	w := NewResponseWriter(hw)
+	w.WriteHeader(http.StatusEarlyHints)
+	if got := w.Status(); got != http.StatusOK {
+		t.Errorf("Status() after 103 = %d, want %d", got, http.StatusOK)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	if got := w.Status(); got != http.StatusNoContent {
+		t.Errorf("Status() = %d, want %d", got, http.StatusNoContent)
+	}
+	if want := []int{http.StatusEarlyHints, http.StatusNoContent}; !reflect.DeepEqual(hw.codes, want) {
+		t.Errorf("written codes = %v, want %v", hw.codes, want)
+	}
+}
@@ -0,0 +1,43 @@
+package sloggcp
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"sync/atomic"
+)
+
+// InsertIDKey is the key of the special field holding a unique identifier for the log entry.
+// Cloud Logging orders entries with the same timestamp by their insertId.
+// See https://cloud.google.com/logging/docs/structured-logging#special-payload-fields.
+const InsertIDKey = "logging.googleapis.com/insertId"
+
+// WithInsertIDGenerator enables the [InsertIDKey] field.
+// The generator is called once for every written record,
+// possibly from multiple goroutines, and must return a unique identifier.
+// [NewInsertIDGenerator] provides a default implementation.
+func WithInsertIDGenerator(generator func() string) Option {
+	return func(c *config) {
+		c.insertIDGenerator = generator
+	}
+}
+
+// NewInsertIDGenerator returns an insertId generator, safe for concurrent use.
+// The returned IDs consist of a random prefix, unique per generator,
+// followed by a zero padded, monotonically increasing counter.
+// IDs from the same generator sort lexicographically in creation order,
+// so entries logged in the same instant keep their order in Cloud Logging.
+func NewInsertIDGenerator() func() string {
+	prefix := strconv.FormatUint(rand.Uint64(), 36) + "-"
+	var counter atomic.Uint64
+	return func() string {
+		id := counter.Add(1)
+		buf := make([]byte, 0, len(prefix)+16)
+		buf = append(buf, prefix...)
+		for shift := 60; shift >= 0; shift -= 4 {
+			buf = append(buf, hexDigits[(id>>shift)&0xf])
+		}
+		return string(buf)
+	}
+}
+
+const hexDigits = "0123456789abcdef"
@@ -0,0 +1,74 @@
+package sloggcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewInsertIDGenerator(t *testing.T) {
+	generate := NewInsertIDGenerator()
+
+	const n = 1000
+	var (
+		wg  sync.WaitGroup
+		ids = make([]string, n)
+	)
+	for i := range n {
+		wg.Go(func() {
+			ids[i] = generate()
+		})
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool, n)
+	for _, id := range ids {
+		if seen[id] {
+			t.Fatalf("duplicate insertId %q", id)
+		}
+		seen[id] = true
+	}
+
+	prev := generate()
+	for range 100 {
+		next := generate()
+		if next <= prev {
+			t.Fatalf("insertId %q does not sort after %q", next, prev)
+		}
+		prev = next
+	}
+
+	other := NewInsertIDGenerator()()
+	if prefix, _, _ := strings.Cut(prev, "-"); strings.HasPrefix(other, prefix+"-") {
+		t.Errorf("generators share prefix %q", prefix)
+	}
+}
+
+func TestHandler_insertID(t *testing.T) {
+	var buf bytes.Buffer
+	ids := []string{"a", "b"}
+	generator := func() string {
+		id := ids[0]
+		ids = ids[1:]
+		return id
+	}
+	logger := slog.New(NewErrorReportingHandler(&buf, nil, WithInsertIDGenerator(generator)))
+	logger.Info("first")
+	logger.Info("second")
+
+	dec := json.NewDecoder(&buf)
+	for _, want := range []string{"a", "b"} {
+		var got struct {
+			InsertID string `json:"logging.googleapis.com/insertId"`
+		}
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("Failed to decode log output: %v", err)
+		}
+		if got.InsertID != want {
+			t.Errorf("insertId = %q, want %q", got.InsertID, want)
+		}
+	}
+}
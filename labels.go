@@ -0,0 +1,98 @@
+package sloggcp
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+)
+
+// LabelsKey is the key of the special field holding indexed labels of a log entry.
+// Labels are a flat map of strings, which can be searched in the Logs Explorer.
+// See https://cloud.google.com/logging/docs/structured-logging#special-payload-fields.
+const LabelsKey = "logging.googleapis.com/labels"
+
+// Labels returns an attribute with the given labels under [LabelsKey].
+// It can be passed to [slog.Logger.With] or a log call:
+//
+//	logger = logger.With(sloggcp.Labels(map[string]string{"tenant": "acme"}))
+//
+// The handler collects attributes with the [LabelsKey] from all
+// [slog.Logger.With] and [slog.Logger.WithGroup] derivations and the record,
+// and writes them merged into the top level labels field.
+// On key collision, the most recent value wins.
+func Labels(labels map[string]string) slog.Attr {
+	return slog.Any(LabelsKey, labels)
+}
+
+// WithLabels sets static labels, which are added to every log entry.
+// Labels from attributes take precedence over static labels.
+// See [Labels].
+func WithLabels(labels map[string]string) Option {
+	return func(c *config) {
+		c.labels = maps.Clone(labels)
+	}
+}
+
+// ContextLabelsFunc returns labels from the context passed to the handler,
+// such as the tenant of a request stored by middleware.
+type ContextLabelsFunc func(ctx context.Context) map[string]string
+
+// WithContextLabels sets a function which provides labels from the context of every record,
+// so every entry logged within a request carries its labels.
+// They are merged with the labels of [WithLabels] and of attributes when the record is handled.
+// On key collision, context labels take precedence over static labels and labels from [slog.Logger.With],
+// while labels of the record attributes take precedence over context labels.
+func WithContextLabels(fn ContextLabelsFunc) Option {
+	return func(c *config) {
+		c.contextLabels = fn
+	}
+}
+
+// labelsFromContext returns the labels provided by the [ContextLabelsFunc], if any.
+func (c *config) labelsFromContext(ctx context.Context) map[string]string {
+	if c.contextLabels == nil {
+		return nil
+	}
+	return c.contextLabels(ctx)
+}
+
+// mergeLabels merges the labels from the value of a [LabelsKey] attribute into the labels of the state.
+func (s *encodeState) mergeLabels(v slog.Value) {
+	if !s.labelsOwned {
+		s.labels, s.labelsOwned = maps.Clone(s.labels), true
+	}
+	s.labels = mergeLabels(s.labels, v)
+}
+
+// mergeLabels merges the labels from the value of a [LabelsKey] attribute into dst.
+// The value can be a map[string]string or a group.
+// Labels must be strings: values of group members are converted using [slog.Value.String],
+// nested groups are dropped.
+// Other value types are ignored.
+// dst is allocated when nil and returned.
+func mergeLabels(dst map[string]string, v slog.Value) map[string]string {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		for _, a := range v.Group() {
+			av := a.Value.Resolve()
+			if av.Kind() == slog.KindGroup {
+				continue
+			}
+			if dst == nil {
+				dst = make(map[string]string)
+			}
+			dst[a.Key] = av.String()
+		}
+	case slog.KindAny:
+		labels, ok := v.Any().(map[string]string)
+		if !ok || len(labels) == 0 {
+			return dst
+		}
+		if dst == nil {
+			dst = make(map[string]string, len(labels))
+		}
+		maps.Copy(dst, labels)
+	}
+	return dst
+}
@@ -0,0 +1,125 @@
+package sloggcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+// tenantKey is the context key of the tenant in tests of context labels.
+type tenantKey struct{}
+
+func TestHandler_labels(t *testing.T) {
+	tenantLabels := WithContextLabels(func(ctx context.Context) map[string]string {
+		if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+			return map[string]string{"tenant": tenant}
+		}
+		return nil
+	})
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	tests := []struct {
+		name    string
+		options []Option
+		log     func(logger *slog.Logger)
+		want    map[string]string
+	}{
+		{
+			name: "no labels",
+			log: func(logger *slog.Logger) {
+				logger.Info("msg")
+			},
+			want: nil,
+		},
+		{
+			name:    "static labels",
+			options: []Option{WithLabels(map[string]string{"service": "api"})},
+			log: func(logger *slog.Logger) {
+				logger.Info("msg")
+			},
+			want: map[string]string{"service": "api"},
+		},
+		{
+			name:    "merged across With and WithGroup",
+			options: []Option{WithLabels(map[string]string{"service": "api", "tenant": "default"})},
+			log: func(logger *slog.Logger) {
+				logger = logger.With(Labels(map[string]string{"tenant": "acme"}))
+				logger = logger.WithGroup("group")
+				logger = logger.With(Labels(map[string]string{"component": "db"}))
+				logger.Info("msg", "foo", "bar")
+			},
+			want: map[string]string{"service": "api", "tenant": "acme", "component": "db"},
+		},
+		{
+			name: "record labels win",
+			log: func(logger *slog.Logger) {
+				logger = logger.With(Labels(map[string]string{"tenant": "acme"}))
+				logger.Info("msg", Labels(map[string]string{"tenant": "other"}))
+			},
+			want: map[string]string{"tenant": "other"},
+		},
+		{
+			name:    "context labels override static and With labels",
+			options: []Option{WithLabels(map[string]string{"service": "api", "tenant": "default"}), tenantLabels},
+			log: func(logger *slog.Logger) {
+				logger = logger.With(Labels(map[string]string{"tenant": "other"}))
+				logger.InfoContext(ctx, "msg")
+			},
+			want: map[string]string{"service": "api", "tenant": "acme"},
+		},
+		{
+			name:    "record labels override context labels",
+			options: []Option{tenantLabels},
+			log: func(logger *slog.Logger) {
+				logger.InfoContext(ctx, "msg", Labels(map[string]string{"tenant": "other"}))
+			},
+			want: map[string]string{"tenant": "other"},
+		},
+		{
+			name:    "no context labels",
+			options: []Option{WithLabels(map[string]string{"service": "api"}), tenantLabels},
+			log: func(logger *slog.Logger) {
+				logger.Info("msg")
+			},
+			want: map[string]string{"service": "api"},
+		},
+		{
+			name: "group values are coerced",
+			log: func(logger *slog.Logger) {
+				logger.Info("msg", slog.Group(LabelsKey,
+					slog.String("string", "value"),
+					slog.Int("int", 42),
+					slog.Bool("bool", true),
+					slog.Any("stringer", stringer{}),
+					slog.Group("nested", slog.String("dropped", "value")),
+				))
+			},
+			want: map[string]string{"string": "value", "int": "42", "bool": "true", "stringer": "stringer"},
+		},
+		{
+			name: "unsupported type is ignored",
+			log: func(logger *slog.Logger) {
+				logger.Info("msg", slog.Any(LabelsKey, map[string]int{"foo": 1}))
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.log(slog.New(NewErrorReportingHandler(&buf, nil, tt.options...)))
+
+			var got struct {
+				Labels map[string]string `json:"logging.googleapis.com/labels"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			if !reflect.DeepEqual(got.Labels, tt.want) {
+				t.Errorf("labels = %v, want %v", got.Labels, tt.want)
+			}
+		})
+	}
+}
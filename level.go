@@ -0,0 +1,159 @@
+package sloggcp
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// ParseSeverity returns the [Level] for a GCP severity name, such as "WARNING" or "CRITICAL".
+// The name is case-insensitive. "DEFAULT" parses to [LevelDefault].
+// It can be used to configure the handler's level from the environment,
+// for example LOG_LEVEL=NOTICE.
+func ParseSeverity(name string) (Level, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	for _, s := range severities {
+		if s.name == name {
+			return s.level, nil
+		}
+	}
+	return 0, fmt.Errorf("sloggcp: unknown severity %q", name)
+}
+
+// SeverityName returns the GCP severity name for a [Level],
+// as written to the [SeverityKey] field.
+// It is the inverse of [ParseSeverity].
+//
+// Levels in between the defined levels map to the next lower severity, never to a higher one,
+// like slog names them relative to the next lower level, such as "ERROR+3".
+// So a custom level only raises the severity once it reaches the level of that severity:
+//
+//	Level                             Severity
+//	below LevelDebug                  DEFAULT
+//	LevelDebug … LevelInfo-1          DEBUG
+//	LevelInfo … LevelNotice-1         INFO
+//	LevelNotice … LevelWarning-1      NOTICE
+//	LevelWarning … LevelError-1       WARNING
+//	LevelError … LevelCritical-1      ERROR
+//	LevelCritical … LevelAlert-1      CRITICAL
+//	LevelAlert … LevelEmergency-1     ALERT
+//	LevelEmergency and above          EMERGENCY
+func SeverityName(level Level) string {
+	return severityFromLevel(level)
+}
+
+// SeverityNumber returns the numeric value of the GCP LogSeverity enum for a [Level],
+// such as 400 for WARNING and 500 for ERROR, for processors which sort or filter by number.
+// Levels are mapped to severities like by [SeverityName].
+func SeverityNumber(level Level) int {
+	number, _ := severityNumber(severityFromLevel(level))
+	return number
+}
+
+// SeverityNumberKey is the key of the numeric severity written with [WithSeverityNumber].
+const SeverityNumberKey = "severityNumber"
+
+// WithSeverityNumber enables writing the numeric severity, as returned by [SeverityNumber],
+// under [SeverityNumberKey], in addition to the [SeverityKey] field, which GCP ingests.
+// The number follows the severity of the log entry, including severities set through
+// [slog.HandlerOptions.ReplaceAttr] and [WithErrorReportSeverity].
+// Custom severities which are not parsed by [ParseSeverity] have no number.
+func WithSeverityNumber(enabled bool) Option {
+	return func(c *config) {
+		c.severityNumber = enabled
+	}
+}
+
+// severityNumber returns the number of the severity name, if it is a GCP severity.
+func severityNumber(name string) (int, bool) {
+	for _, s := range severities {
+		if s.name == name {
+			return s.number, true
+		}
+	}
+	return 0, false
+}
+
+// LevelVar is a [Level] variable, to allow a handler level to change dynamically.
+// It implements [slog.Leveler] and is safe for use by multiple goroutines.
+// Pass it as [slog.HandlerOptions.Level] and the handler observes changes immediately.
+// The zero LevelVar corresponds to [LevelInfo].
+type LevelVar struct {
+	slog.LevelVar
+}
+
+// SetSeverity sets the level from a GCP severity name, as parsed by [ParseSeverity].
+// The level is unchanged when the name is invalid.
+func (v *LevelVar) SetSeverity(name string) error {
+	level, err := ParseSeverity(name)
+	if err != nil {
+		return err
+	}
+	v.Set(level)
+	return nil
+}
+
+// Severity returns the GCP severity name of the current level.
+func (v *LevelVar) Severity() string {
+	return SeverityName(v.Level())
+}
+
+// severity returns the severity name for the level of a record.
+// When [slog.HandlerOptions.ReplaceAttr] is set, it is called with
+// the [slog.LevelKey] attribute first, like the handlers of the slog package do,
+// and its result takes precedence over the default mapping of levels:
+//   - A [slog.Level] or integer value is mapped by [SeverityName].
+//   - A string value is used as severity, normalized if it is parsed by [ParseSeverity].
+//
+// For other values and when the attribute is removed, the level is mapped by [SeverityName].
+func (h *handler) severity(level slog.Level) string {
+	if h.opts.ReplaceAttr == nil {
+		return severityFromLevel(level)
+	}
+	a := h.opts.ReplaceAttr(nil, slog.Any(slog.LevelKey, level))
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		if parsed, err := ParseSeverity(v.String()); err == nil {
+			return severityFromLevel(parsed)
+		}
+		return v.String()
+	case slog.KindInt64:
+		return severityFromLevel(slog.Level(v.Int64()))
+	case slog.KindAny:
+		if l, ok := v.Any().(slog.Level); ok {
+			return severityFromLevel(l)
+		}
+	}
+	return severityFromLevel(level)
+}
+
+// severities maps levels to GCP severities, ordered by level.
+// It is the single source of truth for [SeverityName], [SeverityNumber], [ParseSeverity] and [ReplaceAttr].
+// The numbers are the values of the LogSeverity enum.
+var severities = []struct {
+	level  Level
+	name   string
+	number int
+}{
+	{LevelDefault, DefaultSeverity, 0},
+	{LevelDebug, DebugSeverity, 100},
+	{LevelInfo, InfoSeverity, 200},
+	{LevelNotice, NoticeSeverity, 300},
+	{LevelWarning, WarningSeverity, 400},
+	{LevelError, ErrorSeverity, 500},
+	{LevelCritical, CriticalSeverity, 600},
+	{LevelAlert, AlertSeverity, 700},
+	{LevelEmergency, EmergencySeverity, 800},
+}
+
+// severityFromLevel returns the severity of the highest level not above level.
+// Levels below [LevelDefault] map to [DefaultSeverity].
+func severityFromLevel(level slog.Level) string {
+	for i := len(severities) - 1; i > 0; i-- {
+		if level >= severities[i].level {
+			return severities[i].name
+		}
+	}
+	return DefaultSeverity
+}
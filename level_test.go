@@ -0,0 +1,349 @@
+package sloggcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Level
+		wantErr bool
+	}{
+		{name: "DEFAULT", want: LevelDefault},
+		{name: "DEBUG", want: LevelDebug},
+		{name: "INFO", want: LevelInfo},
+		{name: "NOTICE", want: LevelNotice},
+		{name: "WARNING", want: LevelWarning},
+		{name: "ERROR", want: LevelError},
+		{name: "CRITICAL", want: LevelCritical},
+		{name: "ALERT", want: LevelAlert},
+		{name: "EMERGENCY", want: LevelEmergency},
+		{name: "notice", want: LevelNotice},
+		{name: " Critical ", want: LevelCritical},
+		{name: "WARN", wantErr: true},
+		{name: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSeverity(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSeverity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSeverity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeverityName_roundTrip(t *testing.T) {
+	severities := []string{
+		DefaultSeverity,
+		DebugSeverity,
+		InfoSeverity,
+		NoticeSeverity,
+		WarningSeverity,
+		ErrorSeverity,
+		CriticalSeverity,
+		AlertSeverity,
+		EmergencySeverity,
+	}
+	for _, severity := range severities {
+		t.Run(severity, func(t *testing.T) {
+			level, err := ParseSeverity(severity)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := SeverityName(level); got != severity {
+				t.Errorf("SeverityName() = %v, want %v", got, severity)
+			}
+		})
+	}
+}
+
+func TestLevelVar(t *testing.T) {
+	var (
+		buf   bytes.Buffer
+		level LevelVar
+	)
+	logger := slog.New(NewErrorReportingHandler(&buf, &slog.HandlerOptions{Level: &level}))
+	if got := level.Severity(); got != InfoSeverity {
+		t.Errorf("Severity() = %v, want %v", got, InfoSeverity)
+	}
+	if logger.Enabled(t.Context(), LevelDebug) {
+		t.Error("debug enabled at default level")
+	}
+
+	if err := level.SetSeverity("debug"); err != nil {
+		t.Fatal(err)
+	}
+	if !logger.Enabled(t.Context(), LevelDebug) {
+		t.Error("debug disabled after SetSeverity(debug)")
+	}
+
+	if err := level.SetSeverity("critical"); err != nil {
+		t.Fatal(err)
+	}
+	if logger.Enabled(t.Context(), LevelError) {
+		t.Error("error enabled after SetSeverity(critical)")
+	}
+	if got := level.Severity(); got != CriticalSeverity {
+		t.Errorf("Severity() = %v, want %v", got, CriticalSeverity)
+	}
+
+	if err := level.SetSeverity("invalid"); err == nil {
+		t.Error("SetSeverity(invalid) error = nil, want error")
+	}
+	if got := level.Level(); got != LevelCritical {
+		t.Errorf("Level() after invalid SetSeverity = %v, want %v", got, LevelCritical)
+	}
+}
+
+func TestHandler_severityReplaceAttr(t *testing.T) {
+	tests := []struct {
+		name        string
+		replaceAttr func([]string, slog.Attr) slog.Attr
+		level       slog.Level
+		want        string
+	}{
+		{
+			name:  "no ReplaceAttr",
+			level: LevelNotice,
+			want:  NoticeSeverity,
+		},
+		{
+			name:        "unchanged",
+			replaceAttr: func(_ []string, a slog.Attr) slog.Attr { return a },
+			level:       LevelNotice,
+			want:        NoticeSeverity,
+		},
+		{
+			name: "level",
+			replaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.LevelKey {
+					return slog.Any(a.Key, LevelCritical)
+				}
+				return a
+			},
+			level: LevelInfo,
+			want:  CriticalSeverity,
+		},
+		{
+			name: "int",
+			replaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.LevelKey {
+					return slog.Int(a.Key, int(LevelAlert))
+				}
+				return a
+			},
+			level: LevelInfo,
+			want:  AlertSeverity,
+		},
+		{
+			name: "severity string",
+			replaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.LevelKey {
+					return slog.String(a.Key, "warning")
+				}
+				return a
+			},
+			level: LevelInfo,
+			want:  WarningSeverity,
+		},
+		{
+			name: "custom string",
+			replaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.LevelKey {
+					return slog.String(a.Key, "TRACE")
+				}
+				return a
+			},
+			level: LevelDebug,
+			want:  "TRACE",
+		},
+		{
+			name:        "package ReplaceAttr",
+			replaceAttr: ReplaceAttr,
+			level:       LevelError,
+			want:        ErrorSeverity,
+		},
+		{
+			name: "removed",
+			replaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.LevelKey {
+					return slog.Attr{}
+				}
+				return a
+			},
+			level: LevelWarning,
+			want:  WarningSeverity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := NewErrorReportingHandler(&buf, &slog.HandlerOptions{
+				Level:       LevelDefault,
+				ReplaceAttr: tt.replaceAttr,
+			})
+			slog.New(h).Log(context.Background(), tt.level, "test")
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			if got[SeverityKey] != tt.want {
+				t.Errorf("severity = %v, want %v", got[SeverityKey], tt.want)
+			}
+		})
+	}
+}
+
+func Test_severities(t *testing.T) {
+	for i, s := range severities {
+		if i > 0 && s.level <= severities[i-1].level {
+			t.Errorf("severities not ordered at %s", s.name)
+		}
+		if got := severityFromLevel(s.level); got != s.name {
+			t.Errorf("severityFromLevel(%v) = %s, want %s", s.level, got, s.name)
+		}
+		if got := ReplaceAttr(nil, slog.Any(slog.LevelKey, s.level)); got.Value.String() != s.name {
+			t.Errorf("ReplaceAttr(%v) = %v, want %s", s.level, got.Value, s.name)
+		}
+		if got, err := ParseSeverity(s.name); err != nil || got != s.level {
+			t.Errorf("ParseSeverity(%s) = %v, %v, want %v", s.name, got, err, s.level)
+		}
+	}
+}
+
+func TestSeverityName_boundaries(t *testing.T) {
+	tests := []struct {
+		level Level
+		want  string
+	}{
+		{LevelDefault - 1, DefaultSeverity},
+		{LevelDefault + 1, DefaultSeverity},
+		{LevelDebug - 1, DefaultSeverity},
+		{LevelDebug + 1, DebugSeverity},
+		{LevelInfo - 1, DebugSeverity},
+		{LevelInfo + 1, InfoSeverity},
+		{LevelNotice - 1, InfoSeverity},
+		{LevelNotice + 1, NoticeSeverity},
+		{LevelWarning - 1, NoticeSeverity},
+		{LevelWarning + 1, WarningSeverity},
+		{LevelError - 1, WarningSeverity},
+		{LevelError + 1, ErrorSeverity},
+		{LevelCritical - 1, ErrorSeverity},
+		{LevelCritical + 1, CriticalSeverity},
+		{LevelAlert - 1, CriticalSeverity},
+		{LevelAlert + 1, AlertSeverity},
+		{LevelEmergency - 1, AlertSeverity},
+		{LevelEmergency + 1, EmergencySeverity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			if got := SeverityName(tt.level); got != tt.want {
+				t.Errorf("SeverityName(%v) = %v, want %v", tt.level, got, tt.want)
+			}
+			var buf bytes.Buffer
+			slog.New(New(&buf, WithLevel(LevelDefault-1))).Log(t.Context(), tt.level, "msg")
+			if want := `"severity":"` + tt.want + `"`; !bytes.Contains(buf.Bytes(), []byte(want)) {
+				t.Errorf("log output = %s, want %s", buf.String(), want)
+			}
+		})
+	}
+}
+
+func TestSeverityNumber(t *testing.T) {
+	tests := []struct {
+		level Level
+		want  int
+	}{
+		{LevelDefault - 1, 0},
+		{LevelDefault, 0},
+		{LevelDebug, 100},
+		{LevelInfo, 200},
+		{LevelInfo + 1, 200},
+		{LevelNotice, 300},
+		{LevelWarning, 400},
+		{LevelError, 500},
+		{LevelCritical, 600},
+		{LevelAlert, 700},
+		{LevelEmergency, 800},
+		{LevelEmergency + 10, 800},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			if got := SeverityNumber(tt.level); got != tt.want {
+				t.Errorf("SeverityNumber(%v) = %d, want %d", tt.level, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithSeverityNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		level   Level
+		attrs   []any
+		want    any
+	}{
+		{
+			name:  "disabled",
+			level: LevelWarning,
+		},
+		{
+			name:    "enabled",
+			options: []Option{WithSeverityNumber(true)},
+			level:   LevelWarning,
+			want:    float64(400),
+		},
+		{
+			name: "replaced severity",
+			options: []Option{WithSeverityNumber(true), WithReplaceAttr(func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.LevelKey {
+					return slog.String(a.Key, "notice")
+				}
+				return a
+			})},
+			level: LevelInfo,
+			want:  float64(300),
+		},
+		{
+			name: "custom severity",
+			options: []Option{WithSeverityNumber(true), WithReplaceAttr(func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.LevelKey {
+					return slog.String(a.Key, "CUSTOM")
+				}
+				return a
+			})},
+			level: LevelInfo,
+		},
+		{
+			name:    "raised error report severity",
+			options: []Option{WithSeverityNumber(true), WithErrorReportSeverity(true)},
+			level:   LevelInfo,
+			attrs:   []any{ErrorKey, "oops"},
+			want:    float64(500),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			slog.New(New(&buf, tt.options...)).Log(context.Background(), tt.level, "msg", tt.attrs...)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			if got[SeverityNumberKey] != tt.want {
+				t.Errorf("%s = %v, want %v", SeverityNumberKey, got[SeverityNumberKey], tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package sloggcp
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+const (
+	// truncatedMarker is appended to truncated values.
+	truncatedMarker = "…(truncated)"
+	// maxDepthMarker replaces groups nested too deep.
+	maxDepthMarker = "<max depth exceeded>"
+)
+
+// WithMaxValueBytes limits the size of string values to n bytes.
+// Longer values are truncated and the marker "…(truncated)" is appended.
+// This applies to string values, including the results of Error() and String() methods,
+// and []byte values, of which the first n bytes are encoded according to [WithBytesFormat].
+// Stack traces in error reports are truncated at a line boundary,
+// so the message, including the stack trace, fits into n bytes if the error string does.
+// The values the handler adds itself, such as the log message, are not truncated.
+// Cloud Logging rejects entries larger than 256KB, so this prevents a single large
+// value, such as a dumped HTTP body, from losing the whole entry.
+// By default, or when n is 0 or less, values are not truncated.
+func WithMaxValueBytes(n int) Option {
+	return func(c *config) {
+		c.maxValueBytes = n
+	}
+}
+
+// WithMaxDepth limits the nesting of JSON objects to n levels.
+// Group values, including those returned by [slog.LogValuer]s, nested deeper
+// are replaced by the string "<max depth exceeded>".
+// Groups opened by [slog.Logger.WithGroup] count towards the depth, but are not replaced.
+// This guards against unbounded recursion, for example by a LogValue method
+// returning a group which contains its receiver.
+// By default, or when n is 0 or less, the depth is not limited.
+func WithMaxDepth(n int) Option {
+	return func(c *config) {
+		c.maxDepth = n
+	}
+}
+
+// truncate s according to [WithMaxValueBytes], without splitting UTF-8 sequences.
+func (c *config) truncate(s string) string {
+	if c.maxValueBytes <= 0 || len(s) <= c.maxValueBytes {
+		return s
+	}
+	n := c.maxValueBytes
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + truncatedMarker
+}
+
+// truncateStackTrace truncates trace at a line boundary to at most n bytes,
+// including the appended marker.
+// When n is 0 or less, trace is returned unchanged.
+func truncateStackTrace(trace []byte, n int) []byte {
+	if n <= 0 || len(trace) <= n {
+		return trace
+	}
+	n -= len(truncatedMarker)
+	if n <= 0 {
+		return []byte(truncatedMarker)
+	}
+	cut := trace[:n]
+	if i := bytes.LastIndexByte(cut, '\n'); i >= 0 {
+		cut = cut[:i+1]
+	}
+	return append(cut[:len(cut):len(cut)], truncatedMarker...)
+}
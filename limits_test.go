@@ -0,0 +1,234 @@
+package sloggcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_config_truncate(t *testing.T) {
+	tests := []struct {
+		name string
+		max  int
+		s    string
+		want string
+	}{
+		{
+			name: "disabled",
+			max:  0,
+			s:    "hello",
+			want: "hello",
+		},
+		{
+			name: "short",
+			max:  5,
+			s:    "hello",
+			want: "hello",
+		},
+		{
+			name: "long",
+			max:  4,
+			s:    "hello",
+			want: "hell…(truncated)",
+		},
+		{
+			name: "multi-byte rune",
+			max:  2,
+			s:    "aéb",
+			want: "a…(truncated)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &config{maxValueBytes: tt.max}
+			if got := c.truncate(tt.s); got != tt.want {
+				t.Errorf("truncate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_truncateStackTrace(t *testing.T) {
+	trace := "goroutine 1 [running]:\nmain.f(...)\n\tmain.go:1\nmain.main(...)\n\tmain.go:2\n"
+	tests := []struct {
+		name string
+		n    int
+		want string
+	}{
+		{
+			name: "disabled",
+			n:    0,
+			want: trace,
+		},
+		{
+			name: "fits",
+			n:    len(trace),
+			want: trace,
+		},
+		{
+			name: "line boundary",
+			n:    len("goroutine 1 [running]:\nmain.f(...)\n\tmain.go:1\nmain.") + len(truncatedMarker),
+			want: "goroutine 1 [running]:\nmain.f(...)\n\tmain.go:1\n" + truncatedMarker,
+		},
+		{
+			name: "only marker",
+			n:    len(truncatedMarker),
+			want: truncatedMarker,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(truncateStackTrace([]byte(trace), tt.n)); got != tt.want {
+				t.Errorf("truncateStackTrace() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type longStackTraceError struct{}
+
+func (longStackTraceError) Error() string {
+	return "long"
+}
+
+func (longStackTraceError) StackTrace() ([]byte, bool) {
+	return []byte(strings.Repeat("frame\n", 100)), true
+}
+
+func TestWithMaxValueBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		logAttrs []any
+		want     map[string]any
+	}{
+		{
+			name: "values",
+			logAttrs: []any{
+				"s", "0123456789",
+				"short", "0123",
+				"err", errors.New("0123456789"),
+				"bytes", []byte("0123456789"),
+				"group", slog.GroupValue(slog.String("s", "0123456789")),
+			},
+			want: map[string]any{
+				MessageKey: "msg",
+				"s":        "01234567…(truncated)",
+				"short":    "0123",
+				"err":      "01234567…(truncated)",
+				"bytes":    "MDEyMzQ1Njc=…(truncated)",
+				"group":    map[string]any{"s": "01234567…(truncated)"},
+			},
+		},
+		{
+			name:     "stack trace",
+			logAttrs: []any{ErrorKey, longStackTraceError{}},
+			want: map[string]any{
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				MessageKey:         "long\n" + truncatedMarker,
+				ErrorKey:           "long",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(NewErrorReportingHandler(&buf, nil, WithMaxValueBytes(8)))
+			logger.Info("msg", tt.logAttrs...)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			delete(got, TimeKey)
+			delete(got, SeverityKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// recursiveValuer returns a group containing itself.
+type recursiveValuer struct{}
+
+func (r recursiveValuer) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("name", "node"), slog.Any("child", r))
+}
+
+func TestWithMaxDepth(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxDepth int
+		group    string
+		logAttrs []any
+		want     map[string]any
+	}{
+		{
+			name:     "recursive LogValuer",
+			maxDepth: 2,
+			logAttrs: []any{"node", recursiveValuer{}},
+			want: map[string]any{
+				"node": map[string]any{
+					"name": "node",
+					"child": map[string]any{
+						"name":  "node",
+						"child": maxDepthMarker,
+					},
+				},
+			},
+		},
+		{
+			name:     "nested groups",
+			maxDepth: 1,
+			logAttrs: []any{slog.Group("a", slog.Group("b", "c", 1)), "d", 2},
+			want: map[string]any{
+				"a": map[string]any{"b": maxDepthMarker},
+				"d": float64(2),
+			},
+		},
+		{
+			name:     "WithGroup counts",
+			maxDepth: 1,
+			group:    "g",
+			logAttrs: []any{slog.Group("a", "b", 1), "c", 2},
+			want: map[string]any{
+				"g": map[string]any{
+					"a": maxDepthMarker,
+					"c": float64(2),
+				},
+			},
+		},
+		{
+			name:     "within limit",
+			maxDepth: 2,
+			logAttrs: []any{slog.Group("a", slog.Group("b", "c", 1))},
+			want: map[string]any{
+				"a": map[string]any{"b": map[string]any{"c": float64(1)}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(NewErrorReportingHandler(&buf, nil, WithMaxDepth(tt.maxDepth)))
+			if tt.group != "" {
+				logger = logger.WithGroup(tt.group)
+			}
+			logger.Info("", tt.logAttrs...)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			delete(got, TimeKey)
+			delete(got, SeverityKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package sloggcp
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+)
+
+// NewGCPMiddleware returns a handler which adds the GCP semantics of the error reporting handler to records,
+// and passes them to next for encoding, for example a [slog.JSONHandler],
+// a handler of a test or a handler sending records over the network:
+//
+//	next := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{ReplaceAttr: sloggcp.ReplaceAttr})
+//	logger := slog.New(sloggcp.NewGCPMiddleware(next, sloggcp.WithProjectID("my-project")))
+//
+// The level, message, time and source of records are encoded by next,
+// and [ReplaceAttr] writes them under the GCP keys, mapping levels to severities.
+// The middleware transforms the records passed to next:
+//   - A top-level record attribute with one of the error keys, see [WithErrorKeys], creates an error report.
+//     The message of the record is replaced by the error message and stack trace, the error attribute by
+//     the error string or the value of its [slog.LogValuer], and the [ErrorReportTypeKey], [ReportLocationKey]
+//     and [ServiceContextKey] attributes are added. The level is set by a [SeverityError]
+//     or raised by [WithErrorReportSeverity]. Benign errors, see [WithBenignErrors], do not create error reports.
+//   - The trace attributes are added from the context, see [WithTraceExtractor].
+//   - The labels set through [WithLabels] and [WithContextLabels] and of the record attributes
+//     with [LabelsKey] are merged into one attribute.
+//
+// The other options concerning error reports, such as [WithLogMessageKey] and [WithErrorReporter], apply as well.
+// Options concerning the encoding, such as [WithLevel], [WithMaxDepth] or [WithTimeFormat], have no effect.
+// Enabled is delegated to next. Attributes added through WithAttrs are passed to next and are not inspected.
+// After WithGroup, the record attributes are part of the group and do not create error reports,
+// while the attributes added by the middleware remain at the top level.
+//
+// [New] remains the default, which encodes the records itself, with less overhead.
+func NewGCPMiddleware(next slog.Handler, options ...Option) slog.Handler {
+	return &gcpMiddleware{next: next, cfg: newConfig(options)}
+}
+
+type gcpMiddleware struct {
+	next slog.Handler
+	cfg  *config
+	// goas are the groups and attributes added after the first group,
+	// which are applied to the record attributes in Handle,
+	// so the attributes added by the middleware remain at the top level.
+	goas []groupOrAttrs
+}
+
+// Enabled implements [slog.Handler].
+func (m *gcpMiddleware) Enabled(ctx context.Context, level slog.Level) bool {
+	return m.next.Enabled(ctx, level)
+}
+
+// Handle implements [slog.Handler].
+// It passes a new record to next, so r is not modified.
+func (m *gcpMiddleware) Handle(ctx context.Context, r slog.Record) error {
+	var (
+		attrs       = make([]slog.Attr, 0, r.NumAttrs())
+		labels      = m.cfg.labels
+		labelsOwned bool
+		errorAttr   slog.Attr
+		errorIndex  = -1
+	)
+	if contextLabels := m.cfg.labelsFromContext(ctx); len(contextLabels) > 0 {
+		labels, labelsOwned = mergeLabels(maps.Clone(labels), slog.AnyValue(contextLabels)), true
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == LabelsKey {
+			if !labelsOwned {
+				labels, labelsOwned = maps.Clone(labels), true
+			}
+			labels = mergeLabels(labels, a.Value)
+			return true
+		}
+		i := slices.Index(m.cfg.errorKeys, a.Key)
+		switch {
+		case i < 0 || len(m.goas) > 0 || (errorIndex >= 0 && i > errorIndex):
+			attrs = append(attrs, a)
+		case errorIndex >= 0 && i < errorIndex:
+			attrs = append(attrs, errorAttr)
+			errorAttr, errorIndex = a, i
+		default: // a repeated error key replaces the previous attribute
+			errorAttr, errorIndex = a, i
+		}
+		return true
+	})
+
+	out := &object{}
+	m.cfg.setTrace(ctx, out)
+	setHTTPRequest(ctx, out)
+	if len(labels) > 0 {
+		out.add(LabelsKey, slog.AnyValue(labels))
+	}
+	level, message := r.Level, r.Message
+	var report *ErrorReport
+	switch {
+	case errorIndex >= 0 && m.cfg.isBenignError(errorAttr.Value.Any()):
+		level = min(level, LevelWarning)
+		attrs = append(attrs, errorAttr)
+	case errorIndex >= 0:
+		if l, ok := errorSeverity(errorAttr.Value.Any()); ok {
+			level = l
+		} else if m.cfg.errorReportSeverity && level < LevelError {
+			level = LevelError
+		}
+		if m.cfg.logMessageKey != "" && r.Message != "" {
+			out.add(m.cfg.logMessageKey, slog.StringValue(r.Message))
+		}
+		report = m.cfg.setErrorReport(out, errorAttr, r.PC)
+		m.cfg.addErrorValue(out, errorAttr, false)
+	}
+
+	entry := slog.NewRecord(r.Time, level, message, r.PC)
+	entry.AddAttrs(m.nest(attrs)...)
+	for _, f := range out.fields {
+		if f.key == MessageKey && !f.attr {
+			entry.Message = f.value.String()
+			continue
+		}
+		v := f.value
+		if j, ok := v.Any().(jsonValue); ok {
+			v = slog.AnyValue(j.v)
+		}
+		entry.AddAttrs(slog.Attr{Key: f.key, Value: v})
+	}
+	err := m.next.Handle(ctx, entry)
+	if report != nil {
+		report.Time = r.Time
+		m.cfg.errorReporter.ReportError(ctx, *report)
+	}
+	return err
+}
+
+// nest returns attrs, nested in the groups and preceded by the attributes added after the first group.
+func (m *gcpMiddleware) nest(attrs []slog.Attr) []slog.Attr {
+	for i := len(m.goas) - 1; i >= 0; i-- {
+		if goa := m.goas[i]; goa.group != "" {
+			attrs = []slog.Attr{{Key: goa.group, Value: slog.GroupValue(attrs...)}}
+		} else {
+			attrs = append(slices.Clip(goa.attrs), attrs...)
+		}
+	}
+	return attrs
+}
+
+// WithAttrs implements [slog.Handler].
+func (m *gcpMiddleware) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return m
+	}
+	m2 := *m
+	if len(m.goas) == 0 {
+		m2.next = m.next.WithAttrs(attrs)
+	} else {
+		m2.goas = append(slices.Clip(m.goas), groupOrAttrs{attrs: attrs})
+	}
+	return &m2
+}
+
+// WithGroup implements [slog.Handler].
+func (m *gcpMiddleware) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return m
+	}
+	m2 := *m
+	m2.goas = append(slices.Clip(m.goas), groupOrAttrs{group: name})
+	return &m2
+}
@@ -0,0 +1,189 @@
+package sloggcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewGCPMiddleware(t *testing.T) {
+	traceExtractor := func(context.Context) (string, string, *bool) {
+		return "abc", "def", nil
+	}
+	tests := []struct {
+		name    string
+		options []Option
+		log     func(*slog.Logger)
+		want    map[string]any
+	}{
+		{
+			name: "plain",
+			log:  func(l *slog.Logger) { l.Info("msg", "k", "v") },
+			want: map[string]any{SeverityKey: InfoSeverity, MessageKey: "msg", "k": "v"},
+		},
+		{
+			name: "error report",
+			log:  func(l *slog.Logger) { l.Warn("msg", "k", "v", ErrorKey, errors.New("oops")) },
+			want: map[string]any{
+				SeverityKey:        WarningSeverity,
+				MessageKey:         "oops",
+				ErrorKey:           "oops",
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				"k":                "v",
+			},
+		},
+		{
+			name:    "error report options",
+			options: []Option{WithErrorReportSeverity(true), WithLogMessageKey(LogMessageKey), WithServiceContext("api", "v1")},
+			log:     func(l *slog.Logger) { l.Info("msg", ErrorKey, errors.New("oops")) },
+			want: map[string]any{
+				SeverityKey:        ErrorSeverity,
+				MessageKey:         "oops",
+				LogMessageKey:      "msg",
+				ErrorKey:           "oops",
+				ErrorReportTypeKey: ErrorReportTypeValue,
+				ServiceContextKey:  map[string]any{"service": "api", "version": "v1"},
+			},
+		},
+		{
+			name: "benign error",
+			log:  func(l *slog.Logger) { l.Error("msg", "k", "v", ErrorKey, context.Canceled) },
+			want: map[string]any{SeverityKey: WarningSeverity, MessageKey: "msg", ErrorKey: "context canceled", "k": "v"},
+		},
+		{
+			name:    "error keys precedence",
+			options: []Option{WithErrorKeys(ErrorKey, "err")},
+			log: func(l *slog.Logger) {
+				l.Error("msg", "err", errors.New("second"), ErrorKey, errors.New("first"))
+			},
+			want: map[string]any{
+				SeverityKey:        ErrorSeverity,
+				MessageKey:         "first",
+				ErrorKey:           "first",
+				"err":              "second",
+				ErrorReportTypeKey: ErrorReportTypeValue,
+			},
+		},
+		{
+			name: "trace and labels",
+			options: []Option{
+				WithTraceExtractor(traceExtractor),
+				WithProjectID("p"),
+				WithLabels(map[string]string{"a": "1", "b": "1", "c": "1"}),
+				WithContextLabels(func(context.Context) map[string]string { return map[string]string{"b": "2", "c": "2"} }),
+			},
+			log: func(l *slog.Logger) { l.Info("msg", Labels(map[string]string{"c": "3"})) },
+			want: map[string]any{
+				SeverityKey: InfoSeverity,
+				MessageKey:  "msg",
+				TraceKey:    "projects/p/traces/abc",
+				SpanIDKey:   "def",
+				LabelsKey:   map[string]any{"a": "1", "b": "2", "c": "3"},
+			},
+		},
+		{
+			name:    "groups",
+			options: []Option{WithTraceExtractor(traceExtractor)},
+			log: func(l *slog.Logger) {
+				l.With("a", 1).WithGroup("g").With("b", 2).WithGroup("h").
+					Error("msg", "c", 3, ErrorKey, "not reported", Labels(map[string]string{"l": "v"}))
+			},
+			want: map[string]any{
+				SeverityKey: ErrorSeverity,
+				MessageKey:  "msg",
+				TraceKey:    "abc",
+				SpanIDKey:   "def",
+				LabelsKey:   map[string]any{"l": "v"},
+				"a":         float64(1),
+				"g": map[string]any{
+					"b": float64(2),
+					"h": map[string]any{"c": float64(3), ErrorKey: "not reported"},
+				},
+			},
+		},
+		{
+			name: "request logger",
+			log: func(l *slog.Logger) {
+				r := httptest.NewRequest(http.MethodGet, "http://example.com/path", nil)
+				r.Header.Set(CloudTraceContextHeader, "105445aa7843bc8bf206b12000100000/74")
+				_, reqLogger := RequestLogger(r, l.WithGroup("g"), "p")
+				reqLogger.Info("msg", "k", "v")
+			},
+			want: map[string]any{
+				SeverityKey: InfoSeverity,
+				MessageKey:  "msg",
+				TraceKey:    "projects/p/traces/105445aa7843bc8bf206b12000100000",
+				SpanIDKey:   "000000000000004a",
+				HTTPRequestKey: map[string]any{
+					"requestMethod": http.MethodGet,
+					"requestUrl":    "http://example.com/path",
+					"remoteIp":      "192.0.2.1",
+					"protocol":      "HTTP/1.1",
+				},
+				"g": map[string]any{"k": "v"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			next := slog.NewJSONHandler(&buf, &slog.HandlerOptions{ReplaceAttr: ReplaceAttr})
+			tt.log(slog.New(NewGCPMiddleware(next, tt.options...)))
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output %s: %v", buf.String(), err)
+			}
+			delete(got, TimeKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewGCPMiddleware_errorReport(t *testing.T) {
+	var (
+		buf     bytes.Buffer
+		reports []ErrorReport
+	)
+	next := slog.NewJSONHandler(&buf, &slog.HandlerOptions{ReplaceAttr: ReplaceAttr})
+	reporter := ErrorReporterFunc(func(_ context.Context, r ErrorReport) {
+		reports = append(reports, r)
+	})
+	logger := slog.New(NewGCPMiddleware(next, WithErrorReporter(reporter)))
+	logger.Error("msg", ErrorKey, NewError("oops"))
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode log output: %v", err)
+	}
+	if message, _ := got[MessageKey].(string); !strings.HasPrefix(message, "oops\ngoroutine ") {
+		t.Errorf("%s = %q, want error message and stack trace", MessageKey, message)
+	}
+	location, _ := got[ReportLocationKey].(map[string]any)
+	if fn, _ := location[FunctionNameKey].(string); !strings.HasSuffix(fn, "TestNewGCPMiddleware_errorReport") {
+		t.Errorf("%s = %v, want location of the test", ReportLocationKey, got[ReportLocationKey])
+	}
+	if len(reports) != 1 || reports[0].Message != got[MessageKey] {
+		t.Errorf("reports = %+v, want the logged report", reports)
+	}
+}
+
+func TestNewGCPMiddleware_enabled(t *testing.T) {
+	next := slog.NewJSONHandler(&bytes.Buffer{}, &slog.HandlerOptions{Level: slog.LevelWarn})
+	h := NewGCPMiddleware(next, WithLevel(slog.LevelDebug))
+	if h.Enabled(t.Context(), slog.LevelInfo) {
+		t.Error("Enabled(Info) = true, want delegated to next")
+	}
+	if !h.WithGroup("g").Enabled(t.Context(), slog.LevelWarn) {
+		t.Error("Enabled(Warn) = false, want delegated to next")
+	}
+}
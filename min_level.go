@@ -0,0 +1,50 @@
+package sloggcp
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// MinLevelKey is the key of the attribute returned by [MinLevel].
+const MinLevelKey = "sloggcp.minLevel"
+
+// MinLevel returns an attribute which raises the minimum level of a derived logger,
+// for example to log only warnings and above from a background worker:
+//
+//	workerLogger := logger.With(sloggcp.MinLevel(slog.LevelWarn))
+//
+// The handler takes it from the attributes passed to [slog.Logger.With] and keeps it in the derived handler,
+// whose Enabled method then reports false for records below level.
+// The level can only be raised: it applies in addition to the level of the handler
+// and of the loggers the logger was derived from, so a lower level has no effect.
+// A [slog.LevelVar] can be passed to change the level at runtime.
+// The attribute is not written, and is ignored when passed to a log call.
+func MinLevel(level slog.Leveler) slog.Attr {
+	return slog.Any(MinLevelKey, level)
+}
+
+// withMinLevels removes the [MinLevel] attributes from attrs,
+// and adds their levels to the minimum levels of the handler.
+func (h *handler) withMinLevels(attrs []slog.Attr) []slog.Attr {
+	for _, a := range attrs {
+		if a.Key != MinLevelKey {
+			continue
+		}
+		if level, ok := a.Value.Resolve().Any().(slog.Leveler); ok {
+			h.minLevels = append(slices.Clip(h.minLevels), level)
+		}
+	}
+	return slices.DeleteFunc(slices.Clone(attrs), func(a slog.Attr) bool {
+		return a.Key == MinLevelKey
+	})
+}
+
+// belowMinLevel reports whether level is below one of the levels set through [MinLevel].
+func (h *handler) belowMinLevel(level slog.Level) bool {
+	for _, minLevel := range h.minLevels {
+		if level < minLevel.Level() {
+			return true
+		}
+	}
+	return false
+}
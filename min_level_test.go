@@ -0,0 +1,77 @@
+package sloggcp
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestMinLevel(t *testing.T) {
+	var levelVar slog.LevelVar
+	levelVar.Set(slog.LevelError)
+	tests := []struct {
+		name   string
+		level  slog.Level
+		derive func(*slog.Logger) *slog.Logger
+		want   map[slog.Level]bool
+	}{
+		{
+			name:   "raised",
+			derive: func(l *slog.Logger) *slog.Logger { return l.With(MinLevel(slog.LevelWarn)) },
+			want:   map[slog.Level]bool{slog.LevelInfo: false, slog.LevelWarn: true},
+		},
+		{
+			name:   "not lowered below handler level",
+			level:  slog.LevelWarn,
+			derive: func(l *slog.Logger) *slog.Logger { return l.With(MinLevel(slog.LevelDebug)) },
+			want:   map[slog.Level]bool{slog.LevelInfo: false, slog.LevelWarn: true},
+		},
+		{
+			name: "not lowered below parent",
+			derive: func(l *slog.Logger) *slog.Logger {
+				return l.With(MinLevel(slog.LevelWarn)).WithGroup("g").With(MinLevel(slog.LevelDebug))
+			},
+			want: map[slog.Level]bool{slog.LevelInfo: false, slog.LevelWarn: true},
+		},
+		{
+			name:   "level var",
+			derive: func(l *slog.Logger) *slog.Logger { return l.With(MinLevel(&levelVar)) },
+			want:   map[slog.Level]bool{slog.LevelWarn: false, slog.LevelError: true},
+		},
+		{
+			name:   "other value",
+			derive: func(l *slog.Logger) *slog.Logger { return l.With(MinLevelKey, "warn") },
+			want:   map[slog.Level]bool{slog.LevelInfo: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := slog.New(New(&bytes.Buffer{}, WithLevel(tt.level)))
+			logger := tt.derive(root)
+			for level, want := range tt.want {
+				if got := logger.Enabled(context.Background(), level); got != want {
+					t.Errorf("Enabled(%v) = %v, want %v", level, got, want)
+				}
+			}
+			if !root.Enabled(context.Background(), tt.level) {
+				t.Error("root logger affected by derived logger")
+			}
+		})
+	}
+}
+
+func TestMinLevel_notWritten(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(New(&buf)).With(MinLevel(slog.LevelWarn), "k", "v")
+	logger.Warn("msg", MinLevel(slog.LevelError))
+
+	out := buf.String()
+	if strings.Contains(out, MinLevelKey) {
+		t.Errorf("output contains %s: %s", MinLevelKey, out)
+	}
+	if !strings.Contains(out, `"k":"v"`) {
+		t.Errorf("output misses other attributes: %s", out)
+	}
+}
@@ -0,0 +1,104 @@
+package sloggcp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+)
+
+// MultiHandler returns a handler which dispatches records to all handlers,
+// for example to write to stdout for the logging agent and to an audit file
+// with a different level.
+// Each handler processes the record on its own, so handlers created by
+// [NewErrorReportingHandler] create error reports as usual.
+//
+// Enabled reports true if any handler is enabled for the level.
+// Handle passes the record only to the handlers enabled for its level,
+// and returns the errors of all handlers joined by [errors.Join].
+// WithAttrs and WithGroup are applied to all handlers.
+// The returned handler implements [Flusher], [Syncer] and [io.Closer],
+// which flush, sync and close the handlers implementing them.
+func MultiHandler(handlers ...slog.Handler) slog.Handler {
+	return &multiHandler{handlers: handlers}
+}
+
+type multiHandler struct {
+	handlers []slog.Handler
+}
+
+// Enabled implements [slog.Handler].
+func (m *multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m.handlers {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle implements [slog.Handler].
+func (m *multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range m.handlers {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		// Handlers may retain the record, so each one gets its own copy.
+		if err := h.Handle(ctx, r.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithAttrs implements [slog.Handler].
+func (m *multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, len(m.handlers))
+	for i, h := range m.handlers {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return &multiHandler{handlers: handlers}
+}
+
+// WithGroup implements [slog.Handler].
+func (m *multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, len(m.handlers))
+	for i, h := range m.handlers {
+		handlers[i] = h.WithGroup(name)
+	}
+	return &multiHandler{handlers: handlers}
+}
+
+// Flush implements [Flusher].
+func (m *multiHandler) Flush() error {
+	var errs []error
+	for _, h := range m.handlers {
+		if f, ok := h.(Flusher); ok {
+			errs = append(errs, f.Flush())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Sync implements [Syncer].
+func (m *multiHandler) Sync() error {
+	var errs []error
+	for _, h := range m.handlers {
+		if s, ok := h.(Syncer); ok {
+			errs = append(errs, s.Sync())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close implements [io.Closer].
+func (m *multiHandler) Close() error {
+	var errs []error
+	for _, h := range m.handlers {
+		if c, ok := h.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,164 @@
+package sloggcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMultiHandler(t *testing.T) {
+	var info, errs bytes.Buffer
+	h := MultiHandler(
+		NewErrorReportingHandler(&info, &slog.HandlerOptions{Level: slog.LevelInfo}),
+		NewErrorReportingHandler(&errs, &slog.HandlerOptions{Level: slog.LevelError}),
+	)
+	if h.Enabled(t.Context(), slog.LevelDebug) {
+		t.Error("Enabled(Debug) = true, want false")
+	}
+	if !h.Enabled(t.Context(), slog.LevelInfo) {
+		t.Error("Enabled(Info) = false, want true")
+	}
+
+	logger := slog.New(h).With("a", 1).WithGroup("g")
+	logger.Info("info", "b", 2)
+	logger.Error("failed", "b", 3)
+
+	decode := func(data []byte) []map[string]any {
+		var entries []map[string]any
+		dec := json.NewDecoder(bytes.NewReader(data))
+		for dec.More() {
+			var entry map[string]any
+			if err := dec.Decode(&entry); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			delete(entry, TimeKey)
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+	wantInfo := map[string]any{
+		SeverityKey: InfoSeverity,
+		MessageKey:  "info",
+		"a":         float64(1),
+		"g":         map[string]any{"b": float64(2)},
+	}
+	wantError := map[string]any{
+		SeverityKey: ErrorSeverity,
+		MessageKey:  "failed",
+		"a":         float64(1),
+		"g":         map[string]any{"b": float64(3)},
+	}
+	if got, want := decode(info.Bytes()), []map[string]any{wantInfo, wantError}; !reflect.DeepEqual(got, want) {
+		t.Errorf("info output = %v, want %v", got, want)
+	}
+	if got, want := decode(errs.Bytes()), []map[string]any{wantError}; !reflect.DeepEqual(got, want) {
+		t.Errorf("error output = %v, want %v", got, want)
+	}
+}
+
+type failingHandler struct {
+	slog.Handler
+	err error
+}
+
+func (h failingHandler) Handle(context.Context, slog.Record) error {
+	return h.err
+}
+
+func TestMultiHandler_sameRecord(t *testing.T) {
+	var first, second bytes.Buffer
+	h := MultiHandler(
+		NewErrorReportingHandler(&first, nil),
+		NewErrorReportingHandler(&second, nil, WithSeparateErrors(ErrorsKey)),
+	)
+	r := slog.NewRecord(time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), slog.LevelError, "msg", 0)
+	r.AddAttrs(
+		slog.Any(ErrorKey, errors.New("oops")),
+		slog.Any(ErrorsKey, []error{errors.New("a")}),
+		slog.Group("g", slog.Int("a", 1), slog.Int("b", 2), slog.Int("c", 3)),
+		slog.Int("d", 4), slog.Int("e", 5), slog.Int("f", 6),
+	)
+	for range 2 {
+		if err := h.Handle(t.Context(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, buf := range map[string]*bytes.Buffer{"first": &first, "second": &second} {
+		lines := bytes.Split(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), []byte("\n"))
+		if len(lines) != 2 || !bytes.Equal(lines[0], lines[1]) {
+			t.Errorf("%s handler output = %s, want the same entry twice", name, buf.String())
+		}
+	}
+}
+
+func TestMultiHandler_errors(t *testing.T) {
+	err1, err2 := errors.New("first"), errors.New("second")
+	var buf bytes.Buffer
+	h := MultiHandler(
+		failingHandler{Handler: NewErrorReportingHandler(nil, nil), err: err1},
+		NewErrorReportingHandler(&buf, nil),
+		failingHandler{Handler: NewErrorReportingHandler(nil, nil), err: err2},
+	)
+	err := slog.New(h).Handler().Handle(t.Context(), slog.NewRecord(someTime, slog.LevelInfo, "msg", 0))
+	if !errors.Is(err, err1) || !errors.Is(err, err2) {
+		t.Errorf("Handle() error = %v, want both errors", err)
+	}
+	if buf.Len() == 0 {
+		t.Error("record not written by the handler without error")
+	}
+}
+
+func TestMultiHandler_FlushClose(t *testing.T) {
+	var first, second closeWriter
+	bufFirst := bufio.NewWriter(&first)
+	h := MultiHandler(
+		NewErrorReportingHandler(struct {
+			*bufio.Writer
+			io.Closer
+		}{bufFirst, &first}, nil),
+		NewErrorReportingHandler(&second, nil),
+		slog.DiscardHandler,
+	)
+	slog.New(h).Info("msg")
+	if first.Len() != 0 {
+		t.Fatalf("output written before Flush: %s", first.String())
+	}
+	if err := h.(Flusher).Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if first.Len() == 0 || second.Len() == 0 {
+		t.Errorf("output after Flush = %q, %q", first.String(), second.String())
+	}
+	if err := h.(io.Closer).Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !first.closed || !second.closed {
+		t.Errorf("closed = %v, %v, want true", first.closed, second.closed)
+	}
+}
+
+func TestMultiHandler_Sync(t *testing.T) {
+	errSync := errors.New("sync failed")
+	first, second := &syncWriter{}, &syncWriter{err: errSync}
+	h := MultiHandler(
+		NewErrorReportingHandler(first, nil),
+		NewErrorReportingHandler(second, nil),
+		slog.DiscardHandler,
+	)
+	derived := h.WithGroup("g")
+	slog.New(derived).Info("msg")
+	if err := derived.(Syncer).Sync(); !errors.Is(err, errSync) {
+		t.Errorf("Sync() error = %v, want %v", err, errSync)
+	}
+	if first.synced == "" || second.synced == "" {
+		t.Errorf("synced output = %q, %q, want both synced", first.synced, second.synced)
+	}
+}
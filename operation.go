@@ -0,0 +1,54 @@
+package sloggcp
+
+import "log/slog"
+
+// OperationKey is the key of the special field holding information
+// about a potentially long-running operation the log entry is associated with.
+// See https://cloud.google.com/logging/docs/structured-logging#special-payload-fields.
+const OperationKey = "logging.googleapis.com/operation"
+
+// Operation groups log entries of a long-running operation in the Logs Explorer.
+// The handler places an attribute with an Operation value at the top level
+// under [OperationKey], regardless of the attribute's key and group:
+//
+//	logger.Info("started", slog.Any("operation", sloggcp.Operation{ID: "42", Producer: "importer", First: true}))
+//
+// See https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogEntryOperation.
+type Operation struct {
+	ID       string `json:"id,omitempty"`       // An arbitrary operation identifier.
+	Producer string `json:"producer,omitempty"` // An arbitrary producer identifier.
+	First    bool   `json:"first,omitempty"`    // Set this to true if this is the first log entry in the operation.
+	Last     bool   `json:"last,omitempty"`     // Set this to true if this is the last log entry in the operation.
+}
+
+// LogValue implements [slog.LogValuer].
+// It allows an Operation to be used directly in other handlers.
+// Empty fields are omitted.
+func (o Operation) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, 4)
+	if o.ID != "" {
+		attrs = append(attrs, slog.String("id", o.ID))
+	}
+	if o.Producer != "" {
+		attrs = append(attrs, slog.String("producer", o.Producer))
+	}
+	if o.First {
+		attrs = append(attrs, slog.Bool("first", true))
+	}
+	if o.Last {
+		attrs = append(attrs, slog.Bool("last", true))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// operationFromAttr returns the [Operation] held by the attribute value, if any.
+func operationFromAttr(a slog.Attr) (*Operation, bool) {
+	switch v := a.Value.Any().(type) {
+	case Operation:
+		return &v, true
+	case *Operation:
+		return v, v != nil
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,87 @@
+package sloggcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestHandler_operation(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(logger *slog.Logger)
+		want map[string]any
+	}{
+		{
+			name: "first",
+			log: func(logger *slog.Logger) {
+				logger.Info("msg", slog.Any("operation", Operation{ID: "42", Producer: "importer", First: true}))
+			},
+			want: map[string]any{
+				MessageKey:  "msg",
+				SeverityKey: InfoSeverity,
+				OperationKey: map[string]any{
+					"id":       "42",
+					"producer": "importer",
+					"first":    true,
+				},
+			},
+		},
+		{
+			name: "last, pointer from WithAttrs in group",
+			log: func(logger *slog.Logger) {
+				logger = logger.WithGroup("group").With("op", &Operation{ID: "42", Last: true})
+				logger.Info("msg", "foo", "bar")
+			},
+			want: map[string]any{
+				MessageKey:  "msg",
+				SeverityKey: InfoSeverity,
+				OperationKey: map[string]any{
+					"id":   "42",
+					"last": true,
+				},
+				"group": map[string]any{
+					"foo": "bar",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.log(slog.New(NewErrorReportingHandler(&buf, nil)))
+
+			got := make(map[string]any)
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			delete(got, TimeKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOperation_LogValue(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	logger.Info("msg", "operation", Operation{ID: "42", Producer: "importer", Last: true})
+
+	var got struct {
+		Operation map[string]any `json:"operation"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode log output: %v", err)
+	}
+	want := map[string]any{
+		"id":       "42",
+		"producer": "importer",
+		"last":     true,
+	}
+	if !reflect.DeepEqual(got.Operation, want) {
+		t.Errorf("operation = %v, want %v", got.Operation, want)
+	}
+}
@@ -0,0 +1,143 @@
+package sloggcp
+
+import (
+	"io"
+	"log/slog"
+	"reflect"
+	"time"
+)
+
+// Option configures GCP specific behavior of the handler,
+// which cannot be expressed through [slog.HandlerOptions].
+type Option func(*config)
+
+// config holds the GCP specific handler configuration.
+// It is built once at construction time and not modified afterwards,
+// so it can be shared between derived handlers.
+type config struct {
+	handlerOptions     slog.HandlerOptions
+	traceExtractor     TraceExtractor
+	projectID          string
+	serviceContext     *ServiceContext
+	autoDetectResource bool
+	labels             map[string]string
+	contextLabels      ContextLabelsFunc
+	insertIDGenerator  func() string
+	sourceFormatter    SourceFormatter
+	sourceLevel        slog.Level
+	sourceLevelSet     bool
+	autoStackTrace     bool
+	contextAttrs       ContextAttrsFunc
+	contextValues      []contextValue
+	contextLevel       ContextLevelFunc
+	cancelLevel        slog.Level
+	cancelLevelSet     bool
+	maxValueBytes      int
+	maxDepth           int
+	redactor           Redactor
+	sampler            *sampler
+	keepDuplicateKeys  bool
+	timeFormat         string
+	timeAsEpoch        bool
+	alwaysTime         bool
+	clock              func() time.Time
+	errorHandler       func(error)
+	fallbackWriter     io.Writer
+	rotationHook       func() error
+	bytesFormat        BytesFormat
+	durationFormat     DurationFormat
+	marshal            func(v any) ([]byte, error)
+	typeEncoders       map[reflect.Type]func(any) any
+	gcpFieldsFirst     bool
+	payloadKey         string
+	groupSeparator     string
+	defaultAttrs       []slog.Attr
+	severityNumber     bool
+	goroutineID        bool
+	// replaceAttr is [slog.HandlerOptions.ReplaceAttr], applied to the members of groups.
+	replaceAttr func(groups []string, a slog.Attr) slog.Attr
+
+	errorKeys             []string
+	errorReportType       string
+	groupedErrors         bool
+	autoReportLocation    bool
+	errorReportSeverity   bool
+	logMessageKey         string
+	separateErrorsKey     string
+	errorReporter         ErrorReporter
+	errorMessageFormatter ErrorMessageFormatter
+	errorValueEncoder     func(value any) (any, bool)
+	orderedErrorValue     bool
+	benignErrors          []error
+}
+
+func newConfig(options []Option) *config {
+	cfg := &config{
+		handlerOptions:  DefaultOpts,
+		errorKeys:       []string{ErrorKey},
+		errorReportType: ErrorReportTypeValue,
+		benignErrors:    defaultBenignErrors,
+	}
+	for _, option := range options {
+		option(cfg)
+	}
+	if cfg.handlerOptions.Level == nil {
+		cfg.handlerOptions.Level = DefaultOpts.Level
+	}
+	if cfg.autoDetectResource {
+		cfg.detectResource()
+	}
+	return cfg
+}
+
+// WithLevel sets the minimum level of records to handle,
+// like [slog.HandlerOptions.Level]. A [LevelVar] allows changing it at runtime.
+// By default, [slog.LevelInfo] is used.
+func WithLevel(level slog.Leveler) Option {
+	return func(c *config) {
+		c.handlerOptions.Level = level
+	}
+}
+
+// WithAddSource enables the source location of the log call,
+// like [slog.HandlerOptions.AddSource].
+func WithAddSource(enabled bool) Option {
+	return func(c *config) {
+		c.handlerOptions.AddSource = enabled
+	}
+}
+
+// WithReplaceAttr sets the function to rewrite attributes,
+// like [slog.HandlerOptions.ReplaceAttr].
+// See [NewErrorReportingHandler] for the details of when it is called.
+func WithReplaceAttr(replaceAttr func(groups []string, a slog.Attr) slog.Attr) Option {
+	return func(c *config) {
+		c.handlerOptions.ReplaceAttr = replaceAttr
+	}
+}
+
+// withHandlerOptions sets all [slog.HandlerOptions] at once, for [NewErrorReportingHandler].
+func withHandlerOptions(opts slog.HandlerOptions) Option {
+	return func(c *config) {
+		c.handlerOptions = opts
+	}
+}
+
+// WithTraceExtractor sets the function used to obtain trace information
+// from the context passed to the handler.
+// See [TraceExtractor] for details.
+func WithTraceExtractor(extractor TraceExtractor) Option {
+	return func(c *config) {
+		c.traceExtractor = extractor
+	}
+}
+
+// WithProjectID sets the GCP project ID used to qualify trace IDs.
+// When set, the trace attribute is written as
+// "projects/PROJECT_ID/traces/TRACE_ID", as expected by Cloud Logging.
+// When empty (default), the trace ID is written as returned by the [TraceExtractor].
+func WithProjectID(projectID string) Option {
+	return func(c *config) {
+		c.projectID = projectID
+	}
+}
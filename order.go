@@ -0,0 +1,39 @@
+package sloggcp
+
+import "cmp"
+
+// WithGCPFieldsFirst changes the order of the top-level fields, so
+// severity, time and message come first, followed by all other fields sorted by key.
+// When the time is written as epoch (see [WithTimeAsEpoch]),
+// [TimestampSecondsKey] and [TimestampNanosKey] take the place of the time.
+// The order is stable for the same set of fields, which suits golden file tests,
+// and makes raw log output easier to read.
+// By default, all fields are sorted by key, like [json.Marshal] sorts the keys of maps.
+// The fields of groups are always sorted by key.
+func WithGCPFieldsFirst(enabled bool) Option {
+	return func(c *config) {
+		c.gcpFieldsFirst = enabled
+	}
+}
+
+// leadingFieldRank returns the position of the key among the fields written first
+// with [WithGCPFieldsFirst], or a rank after all of them for other keys.
+func leadingFieldRank(key string) int {
+	switch key {
+	case SeverityKey:
+		return 0
+	case TimeKey, TimestampSecondsKey, TimestampNanosKey:
+		return 1
+	case MessageKey:
+		return 2
+	}
+	return 3
+}
+
+// compareTopLevelKeys orders the keys of the top-level object according to [WithGCPFieldsFirst].
+func compareTopLevelKeys(a, b string) int {
+	if c := cmp.Compare(leadingFieldRank(a), leadingFieldRank(b)); c != 0 {
+		return c
+	}
+	return cmp.Compare(a, b)
+}
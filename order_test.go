@@ -0,0 +1,63 @@
+package sloggcp
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestWithGCPFieldsFirst(t *testing.T) {
+	recordTime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	tests := []struct {
+		name    string
+		options []Option
+		attrs   []any
+		want    string
+	}{
+		{
+			name:  "default",
+			attrs: []any{"b", 1, "a", slog.GroupValue(slog.Int("z", 1), slog.Int("y", 2))},
+			want:  `{"a":{"y":2,"z":1},"b":1,"message":"msg","severity":"INFO","time":"2024-05-06T07:08:09Z"}` + "\n",
+		},
+		{
+			name:    "enabled",
+			options: []Option{WithGCPFieldsFirst(true)},
+			attrs:   []any{"b", 1, "a", slog.GroupValue(slog.Int("z", 1), slog.Int("y", 2))},
+			want:    `{"severity":"INFO","time":"2024-05-06T07:08:09Z","message":"msg","a":{"y":2,"z":1},"b":1}` + "\n",
+		},
+		{
+			name:    "error report",
+			options: []Option{WithGCPFieldsFirst(true)},
+			attrs:   []any{ErrorKey, errors.New("oops")},
+			want:    `{"severity":"INFO","time":"2024-05-06T07:08:09Z","message":"oops","@type":"` + ErrorReportTypeValue + `","error":"oops"}` + "\n",
+		},
+		{
+			name:    "epoch",
+			options: []Option{WithGCPFieldsFirst(true), WithTimeAsEpoch(true)},
+			attrs:   []any{"a", 1},
+			want:    `{"severity":"INFO","timestampNanos":0,"timestampSeconds":1714979289,"message":"msg","a":1}` + "\n",
+		},
+		{
+			name:    "disabled",
+			options: []Option{WithGCPFieldsFirst(false)},
+			attrs:   []any{"a", 1},
+			want:    `{"a":1,"message":"msg","severity":"INFO","time":"2024-05-06T07:08:09Z"}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := NewErrorReportingHandler(&buf, nil, tt.options...)
+			r := slog.NewRecord(recordTime, slog.LevelInfo, "msg", 0)
+			r.Add(tt.attrs...)
+			if err := h.Handle(t.Context(), r); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("log output = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package sloggcp
+
+import "strings"
+
+// WithPayloadKey places all attributes, including groups and the error attribute,
+// in a nested object under key, such as "jsonPayload", for logging agents configured
+// to expect the structured content nested, rather than at the top level.
+// The fields of the handler, such as severity, time, message, trace and the error report,
+// stay at the top level. Attributes with the keys of special fields recognized by Cloud Logging,
+// [HTTPRequestKey] and all keys starting with "logging.googleapis.com/", stay at the top level as well.
+// The nested object is omitted when there are no attributes.
+// By default, or when key is empty, attributes are written at the top level.
+func WithPayloadKey(key string) Option {
+	return func(c *config) {
+		c.payloadKey = key
+	}
+}
+
+// specialFieldPrefix is the prefix of the special fields of Cloud Logging.
+const specialFieldPrefix = "logging.googleapis.com/"
+
+// payloadRank orders the top-level fields before the fields written to the object of [WithPayloadKey].
+func (c *config) payloadRank(f field) int {
+	if c.payloadKey != "" && f.inPayload() {
+		return 1
+	}
+	return 0
+}
+
+// inPayload reports whether a top-level field belongs to the object of [WithPayloadKey].
+func (f *field) inPayload() bool {
+	if f.payload {
+		return false
+	}
+	if f.nested {
+		return true
+	}
+	return f.attr && f.key != HTTPRequestKey && !strings.HasPrefix(f.key, specialFieldPrefix)
+}
@@ -0,0 +1,85 @@
+package sloggcp
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestWithPayloadKey(t *testing.T) {
+	recordTime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	tests := []struct {
+		name    string
+		options []Option
+		with    []any
+		group   string
+		attrs   []any
+		want    string
+	}{
+		{
+			name:  "default",
+			attrs: []any{"b", 1, "a", 2},
+			want:  `{"a":2,"b":1,"message":"msg","severity":"INFO","time":"2024-05-06T07:08:09Z"}` + "\n",
+		},
+		{
+			name:    "attributes",
+			options: []Option{WithPayloadKey("jsonPayload")},
+			with:    []any{"c", 3},
+			attrs:   []any{"b", 1, "a", slog.GroupValue(slog.Int("z", 1))},
+			want:    `{"jsonPayload":{"a":{"z":1},"b":1,"c":3},"message":"msg","severity":"INFO","time":"2024-05-06T07:08:09Z"}` + "\n",
+		},
+		{
+			name:    "groups",
+			options: []Option{WithPayloadKey("data")},
+			with:    []any{"a", 1},
+			group:   "g",
+			attrs:   []any{"b", 2},
+			want:    `{"data":{"a":1,"g":{"b":2}},"message":"msg","severity":"INFO","time":"2024-05-06T07:08:09Z"}` + "\n",
+		},
+		{
+			name:    "no attributes",
+			options: []Option{WithPayloadKey("data")},
+			want:    `{"message":"msg","severity":"INFO","time":"2024-05-06T07:08:09Z"}` + "\n",
+		},
+		{
+			name:    "special fields",
+			options: []Option{WithPayloadKey("data")},
+			attrs:   []any{TraceKey, "t", "severity", "custom", "a", 1},
+			want:    `{"data":{"a":1,"severity":"custom"},"logging.googleapis.com/trace":"t","message":"msg","severity":"INFO","time":"2024-05-06T07:08:09Z"}` + "\n",
+		},
+		{
+			name:    "error report",
+			options: []Option{WithPayloadKey("data")},
+			attrs:   []any{ErrorKey, errors.New("oops")},
+			want:    `{"@type":"` + ErrorReportTypeValue + `","data":{"error":"oops"},"message":"oops","severity":"INFO","time":"2024-05-06T07:08:09Z"}` + "\n",
+		},
+		{
+			name:    "GCP fields first",
+			options: []Option{WithPayloadKey("data"), WithGCPFieldsFirst(true)},
+			attrs:   []any{"b", 1, "a", 2},
+			want:    `{"severity":"INFO","time":"2024-05-06T07:08:09Z","message":"msg","data":{"a":2,"b":1}}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := NewErrorReportingHandler(&buf, nil, tt.options...)
+			if tt.with != nil {
+				h = slog.New(h).With(tt.with...).Handler()
+			}
+			if tt.group != "" {
+				h = h.WithGroup(tt.group)
+			}
+			r := slog.NewRecord(recordTime, slog.LevelInfo, "msg", 0)
+			r.Add(tt.attrs...)
+			if err := h.Handle(t.Context(), r); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("log output = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package sloggcp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// RecoverAndLog recovers from a panic and logs it as error report,
+// at [LevelError] under [ErrorKey], including the stack trace of the panicking goroutine.
+// It must be called directly by defer:
+//
+//	defer sloggcp.RecoverAndLog(logger)
+//
+// The panic is not propagated, see [RecoverLogAndPanic] to panic again after logging.
+func RecoverAndLog(logger *slog.Logger) {
+	if v := recover(); v != nil {
+		logPanic(logger, v)
+	}
+}
+
+// RecoverLogAndPanic is like [RecoverAndLog], but panics again with the recovered value after logging,
+// for example to let the program crash after the error report was written.
+func RecoverLogAndPanic(logger *slog.Logger) {
+	if v := recover(); v != nil {
+		logPanic(logger, v)
+		panic(v)
+	}
+}
+
+// PanicError is the error logged by [RecoverAndLog] and [RecoverLogAndPanic].
+// It implements [StackTraceError] with the stack trace of the panicking goroutine,
+// and [ReportLocationError] with the location of the panic,
+// so Error Reporting points to the panicking function instead of the deferred recover.
+type PanicError struct {
+	Value    any             // the recovered value
+	Stack    []byte          // formatted by [debug.Stack]
+	Location *ReportLocation // where the panic originated, may be nil
+}
+
+// Error implements [error].
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// StackTrace implements [StackTraceError].
+func (e *PanicError) StackTrace() ([]byte, bool) {
+	return e.Stack, len(e.Stack) > 0
+}
+
+// ReportLocation implements [ReportLocationError].
+func (e *PanicError) ReportLocation() *ReportLocation {
+	return e.Location
+}
+
+// Unwrap returns the recovered value, if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// logPanic is called by the deferred function,
+// so the caller of logPanic is the recover function.
+func logPanic(logger *slog.Logger, v any) {
+	ctx := context.Background()
+	if !logger.Enabled(ctx, LevelError) {
+		return
+	}
+	pc := panicPC()
+	err := &PanicError{Value: v, Stack: debug.Stack(), Location: ReportLocationFromPC(pc)}
+	r := slog.NewRecord(time.Now(), LevelError, err.Error(), pc)
+	r.AddAttrs(slog.Any(ErrorKey, err))
+	_ = logger.Handler().Handle(ctx, r)
+}
+
+// panicPC returns the program counter of the function which panicked:
+// the first frame after the runtime panic functions,
+// skipping the deferred recover function and the runtime frames running it.
+func panicPC() uintptr {
+	var pcs [32]uintptr
+	n := runtime.Callers(3, pcs[:]) // skip runtime.Callers, panicPC and logPanic
+	inRuntime := false
+	for _, pc := range pcs[:n] {
+		fn := runtime.FuncForPC(pc - 1)
+		if fn != nil && strings.HasPrefix(fn.Name(), "runtime.") {
+			inRuntime = true
+		} else if inRuntime {
+			return pc
+		}
+	}
+	return 0
+}
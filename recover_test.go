@@ -0,0 +1,117 @@
+package sloggcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func panicking(logger *slog.Logger, v any) {
+	defer RecoverAndLog(logger)
+	panic(v) // panicking line
+}
+
+func recovering(logger *slog.Logger) {
+	defer RecoverAndLog(logger)
+	panickingHelper()
+}
+
+func panickingHelper() {
+	var m map[string]int
+	m["boom"]++ // runtime error
+}
+
+func TestRecoverAndLog_reportLocation(t *testing.T) {
+	var buf bytes.Buffer
+	recovering(slog.New(NewErrorReportingHandler(&buf, nil)))
+
+	var got struct {
+		ReportLocation ReportLocation `json:"reportLocation"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode log output: %v", err)
+	}
+	if !strings.HasSuffix(got.ReportLocation.FunctionName, "sloggcp.panickingHelper") {
+		t.Errorf("reportLocation function = %s, want panickingHelper", got.ReportLocation.FunctionName)
+	}
+	if !strings.HasSuffix(got.ReportLocation.FilePath, "recover_test.go") || got.ReportLocation.LineNumber != 25 {
+		t.Errorf("reportLocation = %+v, want recover_test.go:25", got.ReportLocation)
+	}
+}
+
+func TestRecoverAndLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		wantMsg string
+	}{
+		{
+			name:    "string",
+			value:   "boom",
+			wantMsg: "panic: boom",
+		},
+		{
+			name:    "error",
+			value:   io.EOF,
+			wantMsg: "panic: EOF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(NewErrorReportingHandler(&buf, &slog.HandlerOptions{AddSource: true}))
+			panicking(logger, tt.value)
+
+			var got struct {
+				Type     string         `json:"@type"`
+				Message  string         `json:"message"`
+				Severity string         `json:"severity"`
+				Error    string         `json:"error"`
+				Source   SourceLocation `json:"logging.googleapis.com/sourceLocation"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			if got.Type != ErrorReportTypeValue || got.Severity != ErrorSeverity || got.Error != tt.wantMsg {
+				t.Errorf("log output = %+v", got)
+			}
+			if !strings.HasPrefix(got.Message, tt.wantMsg+"\ngoroutine ") || !strings.Contains(got.Message, "sloggcp.panicking(") {
+				t.Errorf("message = %q, want panic message and stack trace", got.Message)
+			}
+			if !strings.HasSuffix(got.Source.Function, "sloggcp.panicking") || got.Source.Line != 15 {
+				t.Errorf("source = %+v, want panicking line", got.Source)
+			}
+		})
+	}
+}
+
+func TestRecoverLogAndPanic(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewErrorReportingHandler(&buf, nil))
+	defer func() {
+		if v := recover(); v != "boom" {
+			t.Errorf("recovered %v, want boom", v)
+		}
+		if !strings.Contains(buf.String(), `"error":"panic: boom"`) {
+			t.Errorf("log output = %s", buf.String())
+		}
+	}()
+	func() {
+		defer RecoverLogAndPanic(logger)
+		panic("boom")
+	}()
+}
+
+func TestPanicError_Unwrap(t *testing.T) {
+	err := &PanicError{Value: io.EOF}
+	if !errors.Is(err, io.EOF) {
+		t.Error("errors.Is(PanicError{io.EOF}, io.EOF) = false")
+	}
+	if (&PanicError{Value: "x"}).Unwrap() != nil {
+		t.Error("Unwrap() of non-error value is not nil")
+	}
+}
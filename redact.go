@@ -0,0 +1,37 @@
+package sloggcp
+
+import "log/slog"
+
+// Redactor inspects an attribute value before it is written.
+// It returns the value to write, which can be a masked version of v,
+// or false to omit the attribute.
+// groups are the keys of the groups containing the attribute,
+// including the groups opened by [slog.Logger.WithGroup].
+// v is not resolved, see [slog.Value.Resolve] to inspect [slog.LogValuer] values.
+type Redactor func(groups []string, key string, v slog.Value) (slog.Value, bool)
+
+// WithRedactor sets a function to mask or omit sensitive attribute values,
+// such as secrets or personal information.
+// Unlike [slog.HandlerOptions.ReplaceAttr], it is called for all attributes,
+// including the members of groups and of the groups returned by [slog.LogValuer]s.
+// For attributes of the logger and the record, it is called after ReplaceAttr.
+// Group attributes are passed to the redactor before their members,
+// so a group can be omitted or replaced as a whole.
+func WithRedactor(redactor Redactor) Option {
+	return func(c *config) {
+		c.redactor = redactor
+	}
+}
+
+// redact calls the [Redactor], if any, for a.
+func (c *config) redact(groups []string, a slog.Attr) (slog.Attr, bool) {
+	if c.redactor == nil {
+		return a, true
+	}
+	v, ok := c.redactor(groups, a.Key, a.Value)
+	if !ok {
+		return slog.Attr{}, false
+	}
+	a.Value = v
+	return a, true
+}
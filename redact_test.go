@@ -0,0 +1,139 @@
+package sloggcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type userValuer struct {
+	name     string
+	password string
+}
+
+func (u userValuer) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("name", u.name), slog.String("password", u.password))
+}
+
+func TestWithRedactor(t *testing.T) {
+	type call struct {
+		groups []string
+		key    string
+	}
+	tests := []struct {
+		name      string
+		logger    func(*slog.Logger) *slog.Logger
+		logAttrs  []any
+		want      map[string]any
+		wantCalls []call
+	}{
+		{
+			name:     "top level",
+			logAttrs: []any{"password", "secret", "token", "abc", "user", "u1"},
+			want: map[string]any{
+				"password": "***",
+				"user":     "u1",
+			},
+			wantCalls: []call{
+				{nil, "password"},
+				{nil, "token"},
+				{nil, "user"},
+			},
+		},
+		{
+			name:     "group value",
+			logAttrs: []any{slog.Group("req", slog.Group("auth", "password", "secret", "user", "u1"))},
+			want: map[string]any{
+				"req": map[string]any{
+					"auth": map[string]any{
+						"password": "***",
+						"user":     "u1",
+					},
+				},
+			},
+			wantCalls: []call{
+				{nil, "req"},
+				{[]string{"req"}, "auth"},
+				{[]string{"req", "auth"}, "password"},
+				{[]string{"req", "auth"}, "user"},
+			},
+		},
+		{
+			name:     "LogValuer",
+			logAttrs: []any{"user", userValuer{name: "u1", password: "secret"}},
+			want: map[string]any{
+				"user": map[string]any{
+					"name":     "u1",
+					"password": "***",
+				},
+			},
+			wantCalls: []call{
+				{nil, "user"},
+				{[]string{"user"}, "name"},
+				{[]string{"user"}, "password"},
+			},
+		},
+		{
+			name: "WithGroup and WithAttrs",
+			logger: func(l *slog.Logger) *slog.Logger {
+				return l.WithGroup("g").With("password", "secret", slog.Group("h", "token", "abc"))
+			},
+			logAttrs: []any{"user", "u1"},
+			want: map[string]any{
+				"g": map[string]any{
+					"password": "***",
+					"h":        map[string]any{},
+					"user":     "u1",
+				},
+			},
+			wantCalls: []call{
+				{[]string{"g"}, "password"},
+				{[]string{"g"}, "h"},
+				{[]string{"g", "h"}, "token"},
+				{[]string{"g"}, "user"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []call
+			redactor := func(groups []string, key string, v slog.Value) (slog.Value, bool) {
+				var g []string
+				if len(groups) > 0 {
+					g = slices.Clone(groups)
+				}
+				calls = append(calls, call{g, key})
+				switch strings.ToLower(key) {
+				case "password":
+					return slog.StringValue("***"), true
+				case "token":
+					return slog.Value{}, false
+				}
+				return v, true
+			}
+			var buf bytes.Buffer
+			logger := slog.New(NewErrorReportingHandler(&buf, nil, WithRedactor(redactor)))
+			if tt.logger != nil {
+				logger = tt.logger(logger)
+			}
+			logger.Info("", tt.logAttrs...)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			delete(got, TimeKey)
+			delete(got, SeverityKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("redactor calls = %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -5,6 +5,9 @@ import "log/slog"
 // ReplaceAttr replaces slog default attributes with GCP compatible ones
 // https://cloud.google.com/logging/docs/structured-logging
 // https://cloud.google.com/logging/docs/agent/logging/configuration#special-fields
+//
+// Levels are mapped to severities like by the error reporting handler, see [SeverityName],
+// including the GCP specific levels such as [LevelNotice] and [LevelCritical].
 func ReplaceAttr(groups []string, a slog.Attr) slog.Attr {
 	// only handle top-level attributes
 	if len(groups) > 0 {
@@ -23,29 +26,12 @@ func ReplaceAttr(groups []string, a slog.Attr) slog.Attr {
 	return a
 }
 
-var (
-	severityDebug   = slog.String(SeverityKey, DebugSeverity)
-	severityInfo    = slog.String(SeverityKey, InfoSeverity)
-	severityWarn    = slog.String(SeverityKey, WarningSeverity)
-	severityError   = slog.String(SeverityKey, ErrorSeverity)
-	severityDefault = slog.String(SeverityKey, DefaultSeverity)
-)
-
+// replaceLevelAttr maps the level to its GCP severity, like the error reporting handler does.
+// Values other than [slog.Level] map to [DefaultSeverity].
 func replaceLevelAttr(a slog.Attr) slog.Attr {
 	logLevel, ok := a.Value.Any().(slog.Level)
 	if !ok {
-		return severityDefault
-	}
-	switch logLevel {
-	case slog.LevelDebug:
-		return severityDebug
-	case slog.LevelInfo:
-		return severityInfo
-	case slog.LevelWarn:
-		return severityWarn
-	case slog.LevelError:
-		return severityError
-	default:
-		return severityDefault
+		return slog.String(SeverityKey, DefaultSeverity)
 	}
+	return slog.String(SeverityKey, severityFromLevel(logLevel))
 }
@@ -86,13 +86,53 @@ func TestReplaceAttr(t *testing.T) {
 			want: slog.String("severity", "ERROR"),
 		},
 		{
-			name: "LevelKey Invalid level",
+			name: "LevelKey Default",
 			args: args{
 				groups: []string{},
-				a:      slog.Any(slog.LevelKey, slog.Level(-1)),
+				a:      slog.Any(slog.LevelKey, LevelDefault),
 			},
 			want: slog.String("severity", "DEFAULT"),
 		},
+		{
+			name: "LevelKey Notice",
+			args: args{
+				groups: []string{},
+				a:      slog.Any(slog.LevelKey, LevelNotice),
+			},
+			want: slog.String("severity", "NOTICE"),
+		},
+		{
+			name: "LevelKey Critical",
+			args: args{
+				groups: []string{},
+				a:      slog.Any(slog.LevelKey, LevelCritical),
+			},
+			want: slog.String("severity", "CRITICAL"),
+		},
+		{
+			name: "LevelKey Alert",
+			args: args{
+				groups: []string{},
+				a:      slog.Any(slog.LevelKey, LevelAlert),
+			},
+			want: slog.String("severity", "ALERT"),
+		},
+		{
+			name: "LevelKey Emergency",
+			args: args{
+				groups: []string{},
+				a:      slog.Any(slog.LevelKey, LevelEmergency),
+			},
+			want: slog.String("severity", "EMERGENCY"),
+		},
+		{
+			name: "LevelKey in between levels",
+			args: args{
+				groups: []string{},
+				a:      slog.Any(slog.LevelKey, slog.Level(-1)),
+			},
+			want: slog.String("severity", "DEBUG"),
+		},
 		{
 			name: "LevelKey Invalid type",
 			args: args{
@@ -178,9 +218,19 @@ func TestReplaceAttr_LogOutput(t *testing.T) {
 			wantSeverity: ErrorSeverity,
 		},
 		{
-			name:         "Default",
+			name:         "Notice",
+			level:        LevelNotice,
+			wantSeverity: NoticeSeverity,
+		},
+		{
+			name:         "Critical",
+			level:        LevelCritical,
+			wantSeverity: CriticalSeverity,
+		},
+		{
+			name:         "In between",
 			level:        slog.Level(-1),
-			wantSeverity: DefaultSeverity,
+			wantSeverity: DebugSeverity,
 		},
 	}
 	for _, tt := range tests {
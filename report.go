@@ -0,0 +1,68 @@
+package sloggcp
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// Report logs err as error report, at [LevelError] under [ErrorKey],
+// with the error message as log message and the optional attributes in args,
+// as [slog.Logger.Log] adds them:
+//
+//	sloggcp.Report(ctx, logger, err, "user", userID)
+//
+// Errors without stack trace or report location, such as sentinel errors or errors of other packages,
+// are wrapped to provide them from the caller of Report, see [StackTraceError] and [ReportLocationError].
+// The wrapper keeps the error message and unwraps to err. The information err provides itself takes precedence.
+// Nothing is logged if err is nil, or if the logger is not enabled for LevelError in ctx.
+// The handler of the logger must recognize ErrorKey, which the handlers of this package do by default.
+func Report(ctx context.Context, logger *slog.Logger, err error, args ...any) {
+	if err == nil || !logger.Enabled(ctx, LevelError) {
+		return
+	}
+	var pcs [64]uintptr
+	n := runtime.Callers(2, pcs[:]) // skip runtime.Callers and Report
+	if n > 0 && (!hasStackTrace(err) || findReportLocation(err) == nil) {
+		err = &reportedError{err: err, pcs: pcs[:n:n]}
+	}
+	r := slog.NewRecord(time.Now(), LevelError, err.Error(), pcs[0])
+	r.Add(args...)
+	r.AddAttrs(slog.Any(ErrorKey, err))
+	_ = logger.Handler().Handle(ctx, r)
+}
+
+// reportedError adds the stack trace and report location of the call to [Report] to err,
+// if it does not provide them.
+type reportedError struct {
+	err error
+	pcs []uintptr
+}
+
+// Error implements [error].
+func (e *reportedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the reported error.
+func (e *reportedError) Unwrap() error {
+	return e.err
+}
+
+// StackTrace implements [StackTraceError].
+func (e *reportedError) StackTrace() ([]byte, bool) {
+	if trace, ok := stackTrace(e.err); ok {
+		return trace, true
+	}
+	// The goroutine that called Report is unknown, when the error is formatted.
+	return appendFrames([]byte("goroutine 1 [running]:\n"), e.pcs), true
+}
+
+// ReportLocation implements [ReportLocationError].
+func (e *reportedError) ReportLocation() *ReportLocation {
+	if location := findReportLocation(e.err); location != nil {
+		return location
+	}
+	return ReportLocationFromPC(e.pcs[0])
+}
@@ -0,0 +1,129 @@
+package sloggcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	errSentinel := errors.New("sentinel")
+	created := NewError("created")
+	tests := []struct {
+		name         string
+		err          error
+		args         []any
+		wantMessage  string
+		wantFunction string
+		wantAttrs    map[string]any
+	}{
+		{
+			name:         "plain error",
+			err:          errSentinel,
+			wantMessage:  "sentinel\ngoroutine 1 [running]:\ngithub.com/zitadel/sloggcp.TestReport",
+			wantFunction: "github.com/zitadel/sloggcp.TestReport.func1",
+		},
+		{
+			name:         "Error",
+			err:          created,
+			wantMessage:  "created\ngoroutine 1 [running]:\ngithub.com/zitadel/sloggcp.TestReport",
+			wantFunction: "github.com/zitadel/sloggcp.TestReport",
+		},
+		{
+			name:         "attributes",
+			err:          errSentinel,
+			args:         []any{"user", "u1", slog.Int("attempt", 2)},
+			wantMessage:  "sentinel\n",
+			wantFunction: "github.com/zitadel/sloggcp.TestReport.func1",
+			wantAttrs:    map[string]any{"user": "u1", "attempt": float64(2)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(NewErrorReportingHandler(&buf, nil))
+			Report(t.Context(), logger, tt.err, tt.args...)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			if got[SeverityKey] != ErrorSeverity {
+				t.Errorf("%s = %v, want %s", SeverityKey, got[SeverityKey], ErrorSeverity)
+			}
+			if got[ErrorKey] != tt.err.Error() {
+				t.Errorf("%s = %v, want %s", ErrorKey, got[ErrorKey], tt.err.Error())
+			}
+			if message, _ := got[MessageKey].(string); !strings.HasPrefix(message, tt.wantMessage) {
+				t.Errorf("%s = %q, want prefix %q", MessageKey, message, tt.wantMessage)
+			}
+			location, _ := got[ReportLocationKey].(map[string]any)
+			if location[FunctionNameKey] != tt.wantFunction {
+				t.Errorf("%s = %v, want function %s", ReportLocationKey, location, tt.wantFunction)
+			}
+			for key, want := range tt.wantAttrs {
+				if got[key] != want {
+					t.Errorf("%s = %v, want %v", key, got[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestReport_unwrap(t *testing.T) {
+	errSentinel := errors.New("sentinel")
+	var reported any
+	h := NewErrorReportingHandler(&bytes.Buffer{}, nil, WithReplaceAttr(func(_ []string, a slog.Attr) slog.Attr {
+		if a.Key == ErrorKey {
+			reported = a.Value.Any()
+		}
+		return a
+	}))
+	Report(t.Context(), slog.New(h), errSentinel)
+	if err, ok := reported.(error); !ok || !errors.Is(err, errSentinel) {
+		t.Errorf("reported error = %v, want wrapping %v", reported, errSentinel)
+	}
+}
+
+func TestReport_notLogged(t *testing.T) {
+	type levelKey struct{}
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		options []Option
+		err     error
+	}{
+		{
+			name: "nil error",
+			ctx:  context.Background(),
+		},
+		{
+			name:    "level disabled",
+			ctx:     context.Background(),
+			options: []Option{WithLevel(LevelCritical)},
+			err:     errors.New("oops"),
+		},
+		{
+			name: "context level disabled",
+			ctx:  context.WithValue(context.Background(), levelKey{}, LevelAlert),
+			options: []Option{WithContextLevel(func(ctx context.Context) slog.Level {
+				level, _ := ctx.Value(levelKey{}).(slog.Level)
+				return level
+			})},
+			err: errors.New("oops"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			Report(tt.ctx, slog.New(New(&buf, tt.options...)), tt.err)
+			if buf.Len() != 0 {
+				t.Errorf("log output = %s, want none", buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package sloggcp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ServiceContextKey is the key of the service context of error reports.
+const ServiceContextKey = "serviceContext"
+
+// ServiceContext identifies the service in error reports,
+// so Error Reporting groups and filters errors by service and version.
+// See https://cloud.google.com/error-reporting/docs/formatting-error-messages.
+type ServiceContext struct {
+	Service string `json:"service"`
+	Version string `json:"version,omitempty"`
+}
+
+// WithServiceContext adds the service context to all error reports.
+// It is not added to other log entries.
+// When service is empty, no service context is added.
+func WithServiceContext(service, version string) Option {
+	return func(c *config) {
+		c.serviceContext = nil
+		if service != "" {
+			c.serviceContext = &ServiceContext{Service: service, Version: version}
+		}
+	}
+}
+
+// WithAutoDetectResource detects the project ID and the service context
+// from the GCP runtime environment, when the handler is created.
+// Values set through [WithProjectID] and [WithServiceContext] take precedence,
+// no matter the order of the options.
+//
+// The project ID is read from the GOOGLE_CLOUD_PROJECT or GCP_PROJECT environment variables.
+// If neither is set, it is queried from the metadata server, with a timeout of one second.
+// The host of the metadata server can be overridden by the GCE_METADATA_HOST environment variable.
+// Outside of GCP, the query fails and the project ID remains empty.
+//
+// The service context is read from the environment variables set by the runtime:
+//   - Cloud Run services and Cloud Run functions: K_SERVICE and K_REVISION.
+//   - Cloud Run jobs: CLOUD_RUN_JOB and CLOUD_RUN_EXECUTION.
+//   - App Engine: GAE_SERVICE and GAE_VERSION.
+func WithAutoDetectResource() Option {
+	return func(c *config) {
+		c.autoDetectResource = true
+	}
+}
+
+// metadataTimeout limits the query of the project ID from the metadata server.
+const metadataTimeout = time.Second
+
+// detectResource fills in the project ID and service context, if not set by options.
+func (c *config) detectResource() {
+	if c.projectID == "" {
+		c.projectID = firstEnv("GOOGLE_CLOUD_PROJECT", "GCP_PROJECT")
+	}
+	if c.projectID == "" {
+		c.projectID = metadataProjectID()
+	}
+	if c.serviceContext != nil {
+		return
+	}
+	for _, env := range [][2]string{
+		{"K_SERVICE", "K_REVISION"},
+		{"CLOUD_RUN_JOB", "CLOUD_RUN_EXECUTION"},
+		{"GAE_SERVICE", "GAE_VERSION"},
+	} {
+		if service := os.Getenv(env[0]); service != "" {
+			c.serviceContext = &ServiceContext{Service: service, Version: os.Getenv(env[1])}
+			return
+		}
+	}
+}
+
+// firstEnv returns the value of the first non-empty environment variable.
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// metadataProjectID queries the project ID from the metadata server.
+// It returns an empty string on failure.
+func metadataProjectID() string {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), metadataTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+"/computeMetadata/v1/project/project-id", nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,132 @@
+package sloggcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWithServiceContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		attrs   []any
+		want    any
+	}{
+		{
+			name:    "error report",
+			options: []Option{WithServiceContext("api", "v1")},
+			attrs:   []any{ErrorKey, "oops"},
+			want:    map[string]any{"service": "api", "version": "v1"},
+		},
+		{
+			name:    "without version",
+			options: []Option{WithServiceContext("api", "")},
+			attrs:   []any{ErrorKey, "oops"},
+			want:    map[string]any{"service": "api"},
+		},
+		{
+			name:    "no error report",
+			options: []Option{WithServiceContext("api", "v1")},
+		},
+		{
+			name:    "empty service",
+			options: []Option{WithServiceContext("api", "v1"), WithServiceContext("", "v2")},
+			attrs:   []any{ErrorKey, "oops"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			slog.New(New(&buf, tt.options...)).Error("msg", tt.attrs...)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			if !reflect.DeepEqual(got[ServiceContextKey], tt.want) {
+				t.Errorf("%s = %v, want %v", ServiceContextKey, got[ServiceContextKey], tt.want)
+			}
+		})
+	}
+}
+
+// metadataServer returns the host of a fake metadata server, returning projectID,
+// or 404 when projectID is empty.
+func metadataServer(t *testing.T, projectID string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if projectID == "" || r.URL.Path != "/computeMetadata/v1/project/project-id" || r.Header.Get("Metadata-Flavor") != "Google" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(projectID))
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestWithAutoDetectResource(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		metadata    string
+		options     []Option
+		wantProject string
+		wantService *ServiceContext
+	}{
+		{
+			name:        "cloud run",
+			env:         map[string]string{"GOOGLE_CLOUD_PROJECT": "p", "K_SERVICE": "api", "K_REVISION": "api-0001"},
+			wantProject: "p",
+			wantService: &ServiceContext{Service: "api", Version: "api-0001"},
+		},
+		{
+			name:        "cloud run job",
+			env:         map[string]string{"GCP_PROJECT": "p", "CLOUD_RUN_JOB": "job", "CLOUD_RUN_EXECUTION": "job-abc"},
+			wantProject: "p",
+			wantService: &ServiceContext{Service: "job", Version: "job-abc"},
+		},
+		{
+			name:        "app engine",
+			env:         map[string]string{"GAE_SERVICE": "default", "GAE_VERSION": "1"},
+			metadata:    "meta-project",
+			wantProject: "meta-project",
+			wantService: &ServiceContext{Service: "default", Version: "1"},
+		},
+		{
+			name: "not on GCP",
+		},
+		{
+			name:        "options take precedence",
+			env:         map[string]string{"GOOGLE_CLOUD_PROJECT": "p", "K_SERVICE": "api", "K_REVISION": "api-0001"},
+			options:     []Option{WithProjectID("explicit"), WithServiceContext("svc", "v1")},
+			wantProject: "explicit",
+			wantService: &ServiceContext{Service: "svc", Version: "v1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{
+				"GOOGLE_CLOUD_PROJECT", "GCP_PROJECT",
+				"K_SERVICE", "K_REVISION", "CLOUD_RUN_JOB", "CLOUD_RUN_EXECUTION", "GAE_SERVICE", "GAE_VERSION",
+			} {
+				t.Setenv(key, tt.env[key])
+			}
+			t.Setenv("GCE_METADATA_HOST", metadataServer(t, tt.metadata))
+
+			cfg := newConfig(append([]Option{WithAutoDetectResource()}, tt.options...))
+			if cfg.projectID != tt.wantProject {
+				t.Errorf("projectID = %q, want %q", cfg.projectID, tt.wantProject)
+			}
+			if !reflect.DeepEqual(cfg.serviceContext, tt.wantService) {
+				t.Errorf("serviceContext = %+v, want %+v", cfg.serviceContext, tt.wantService)
+			}
+		})
+	}
+}
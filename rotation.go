@@ -0,0 +1,38 @@
+package sloggcp
+
+import "fmt"
+
+// WithRotationHook sets a function which is called before each write of a record,
+// while the handler holds the lock around its writer.
+// It lets a rotating writer, such as a writer of size-based log files, swap its file between records:
+// no record is written while the hook runs, so lines are neither interleaved nor torn by the rotation,
+// also when the handler is shared by several goroutines.
+//
+// A typical hook checks the size of the current file, and when it exceeds the limit,
+// flushes any buffered output, closes the file and opens the next one:
+//
+//	rw := &rotatingWriter{path: "app.log", maxBytes: 100 << 20}
+//	handler := sloggcp.New(rw, sloggcp.WithRotationHook(rw.rotateIfFull))
+//
+// The hook is called by all handlers derived from the handler, but not by Flush, Sync and Close.
+// With [NewAsyncHandler] and [NewBatchWriter], records reach the wrapped writer later,
+// outside of the handler's lock, so a rotating writer wrapped by them must synchronize itself.
+// It must not log through the handler, nor call methods of the handler, such as SetWriter,
+// as the lock is held. If the hook returns an error, the record is written anyway,
+// and the error is passed to the function set through [WithErrorHandler].
+func WithRotationHook(hook func() error) Option {
+	return func(c *config) {
+		c.rotationHook = hook
+	}
+}
+
+// rotate calls the rotation hook, if any. h.out.mtx must be held.
+func (h *handler) rotate() error {
+	if h.cfg.rotationHook == nil {
+		return nil
+	}
+	if err := h.cfg.rotationHook(); err != nil {
+		return fmt.Errorf("sloggcp handler: rotation hook: %w", err)
+	}
+	return nil
+}
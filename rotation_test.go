@@ -0,0 +1,90 @@
+package sloggcp
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// rotatingWriter writes to the last of its files,
+// and starts a new file in rotateIfFull when the last one reached maxBytes.
+// It is not synchronized: the handler calls rotateIfFull and Write under its lock.
+type rotatingWriter struct {
+	maxBytes int
+	files    []*bytes.Buffer
+	err      error
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	return w.files[len(w.files)-1].Write(p)
+}
+
+func (w *rotatingWriter) rotateIfFull() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.files) == 0 || w.files[len(w.files)-1].Len() >= w.maxBytes {
+		w.files = append(w.files, new(bytes.Buffer))
+	}
+	return nil
+}
+
+func TestWithRotationHook(t *testing.T) {
+	rw := &rotatingWriter{maxBytes: 1000}
+	logger := slog.New(New(rw, WithRotationHook(rw.rotateIfFull)))
+
+	const goroutines, records = 8, 50
+	var wg sync.WaitGroup
+	for i := range goroutines {
+		wg.Go(func() {
+			logger := logger.With("goroutine", i)
+			for range records {
+				logger.Info(strings.Repeat("x", 100))
+			}
+		})
+	}
+	wg.Wait()
+
+	if len(rw.files) < 2 {
+		t.Fatalf("files = %d, want rotation", len(rw.files))
+	}
+	var lines int
+	for i, f := range rw.files {
+		if i < len(rw.files)-1 && f.Len() < rw.maxBytes {
+			t.Errorf("file %d has %d bytes, want rotation at %d", i, f.Len(), rw.maxBytes)
+		}
+		for _, line := range strings.SplitAfter(f.String(), "\n") {
+			if line == "" {
+				continue
+			}
+			if !strings.HasPrefix(line, "{") || !strings.HasSuffix(line, "}\n") {
+				t.Fatalf("file %d has torn line %q", i, line)
+			}
+			lines++
+		}
+	}
+	if lines != goroutines*records {
+		t.Errorf("lines = %d, want %d", lines, goroutines*records)
+	}
+}
+
+func TestWithRotationHook_error(t *testing.T) {
+	errRotate := errors.New("rotate failed")
+	rw := &rotatingWriter{files: []*bytes.Buffer{new(bytes.Buffer)}, err: errRotate}
+	var handled []error
+	logger := slog.New(New(rw,
+		WithRotationHook(rw.rotateIfFull),
+		WithErrorHandler(func(err error) { handled = append(handled, err) }),
+	))
+	logger.Info("msg")
+
+	if rw.files[0].Len() == 0 {
+		t.Error("record was not written")
+	}
+	if len(handled) != 1 || !errors.Is(handled[0], errRotate) {
+		t.Errorf("handled errors = %v, want %v", handled, errRotate)
+	}
+}
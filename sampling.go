@@ -0,0 +1,95 @@
+package sloggcp
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+)
+
+// SamplingKey is the key of the group in the summary entry of dropped records.
+// See [WithSampling].
+const SamplingKey = "sampling"
+
+// WithSampling limits the number of records logged per severity, to reduce log volume.
+// During every interval of length tick, the first records of each severity are logged,
+// and thereafter only every thereafter-th record. When thereafter is 0 or less,
+// all records exceeding first are dropped. Records of [LevelError] and above are never dropped.
+// When tick is 0 or less, sampling is disabled and all records are logged.
+//
+// When records were dropped, a summary entry is logged with the first record of the next interval,
+// at [LevelWarning], by the handler returned by the constructor. So it has the attributes set through
+// [WithDefaultAttrs], but no attributes of derived handlers and no trace or attributes from the context.
+// It holds the number of dropped records per severity in the "dropped" member of the [SamplingKey] group.
+//
+// The sampling state is shared by all handlers derived with WithAttrs and WithGroup.
+func WithSampling(tick time.Duration, first, thereafter int) Option {
+	return func(c *config) {
+		if tick <= 0 {
+			c.sampler = nil
+			return
+		}
+		c.sampler = &sampler{
+			tick:       tick,
+			first:      max(first, 0),
+			thereafter: max(thereafter, 0),
+			now:        time.Now,
+			counts:     make(map[string]int),
+			dropped:    make(map[string]int),
+		}
+	}
+}
+
+type sampler struct {
+	tick       time.Duration
+	first      int
+	thereafter int
+	now        func() time.Time
+
+	mtx     sync.Mutex
+	start   time.Time      // of the current interval
+	counts  map[string]int // records per severity in the current interval
+	dropped map[string]int // dropped records per severity in the current interval
+}
+
+// sample reports whether a record of the given level is kept.
+// When a new interval starts and records were dropped in the previous one,
+// the dropped counts of the previous interval are returned.
+func (s *sampler) sample(level slog.Level) (keep bool, dropped map[string]int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if now := s.now(); now.Sub(s.start) >= s.tick {
+		s.start = now
+		if len(s.dropped) > 0 {
+			dropped = maps.Clone(s.dropped)
+			clear(s.dropped)
+		}
+		clear(s.counts)
+	}
+	if level >= LevelError {
+		return true, dropped
+	}
+	severity := severityFromLevel(level)
+	s.counts[severity]++
+	n := s.counts[severity]
+	if n <= s.first || (s.thereafter > 0 && (n-s.first)%s.thereafter == 0) {
+		return true, dropped
+	}
+	s.dropped[severity]++
+	return false, dropped
+}
+
+// logSamplingSummary logs the number of dropped records like the root handler,
+// with the attributes set through [WithDefaultAttrs], but without the attributes and groups of derived handlers.
+// The summary concerns no particular request, so the context of the record which triggered it is not used.
+func (h *handler) logSamplingSummary(dropped map[string]int) error {
+	root := *h
+	root.prepared = h.root
+	r := slog.NewRecord(time.Now(), LevelWarning, "sloggcp: records dropped by sampling", 0)
+	r.AddAttrs(slog.Group(SamplingKey,
+		slog.Any("dropped", dropped),
+		slog.Duration("interval", h.cfg.sampler.tick),
+	))
+	return root.handle(context.Background(), r)
+}
@@ -0,0 +1,143 @@
+package sloggcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_sampler_sample(t *testing.T) {
+	tests := []struct {
+		name       string
+		first      int
+		thereafter int
+		level      slog.Level
+		n          int
+		wantKept   int
+	}{
+		{
+			name:       "first only",
+			first:      3,
+			thereafter: 0,
+			level:      LevelInfo,
+			n:          10,
+			wantKept:   3,
+		},
+		{
+			name:       "thereafter",
+			first:      2,
+			thereafter: 3,
+			level:      LevelInfo,
+			n:          11,
+			wantKept:   5, // 1, 2, 5, 8, 11
+		},
+		{
+			name:     "errors are never dropped",
+			first:    1,
+			level:    LevelError,
+			n:        10,
+			wantKept: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConfig([]Option{WithSampling(time.Second, tt.first, tt.thereafter)})
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			c.sampler.now = func() time.Time { return now }
+			var kept int
+			for range tt.n {
+				if keep, _ := c.sampler.sample(tt.level); keep {
+					kept++
+				}
+			}
+			if kept != tt.wantKept {
+				t.Errorf("kept %d records, want %d", kept, tt.wantKept)
+			}
+
+			// The next interval starts over and reports the dropped records.
+			now = now.Add(time.Second)
+			keep, dropped := c.sampler.sample(tt.level)
+			if !keep {
+				t.Error("first record of the next interval dropped")
+			}
+			if got := dropped[severityFromLevel(tt.level)]; got != tt.n-tt.wantKept {
+				t.Errorf("dropped = %d, want %d", got, tt.n-tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestWithSampling(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewErrorReportingHandler(&buf, &slog.HandlerOptions{Level: LevelDebug},
+		WithSampling(time.Second, 1, 0),
+		WithDefaultAttrs(slog.String("service", "api")),
+		WithContextValue(requestIDKey{}, "requestId"),
+		WithTraceExtractor(func(ctx context.Context) (traceID, spanID string, sampled *bool) {
+			id, _ := ctx.Value(requestIDKey{}).(string)
+			return id, "", nil
+		}),
+	).(*handler)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.cfg.sampler.now = func() time.Time { return now }
+
+	// Derived handlers share the sampling state.
+	logger := slog.New(h).With("a", 1).WithGroup("g")
+	logger.Info("info 1")
+	logger.Info("info 2")
+	logger.Debug("debug 1")
+	logger.Debug("debug 2")
+	logger.Debug("debug 3")
+	logger.Error("error 1")
+	logger.Error("error 2")
+	now = now.Add(time.Second)
+	// The summary is not correlated with the request of the record which triggers it.
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "105445aa7843bc8bf206b12000100000")
+	logger.InfoContext(ctx, "info 3")
+
+	var messages []string
+	var summary map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var entry map[string]any
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatalf("Failed to decode log output: %v", err)
+		}
+		messages = append(messages, entry[MessageKey].(string))
+		if s, ok := entry[SamplingKey]; ok {
+			summary = s.(map[string]any)
+			if entry[SeverityKey] != WarningSeverity || entry["a"] != nil || entry["service"] != "api" ||
+				entry["requestId"] != nil || entry[TraceKey] != nil {
+				t.Errorf("summary entry = %v", entry)
+			}
+		}
+	}
+	wantMessages := []string{"info 1", "debug 1", "error 1", "error 2", "sloggcp: records dropped by sampling", "info 3"}
+	if !reflect.DeepEqual(messages, wantMessages) {
+		t.Errorf("messages = %v, want %v", messages, wantMessages)
+	}
+	wantSummary := map[string]any{
+		"dropped":  map[string]any{InfoSeverity: float64(1), DebugSeverity: float64(2)},
+		"interval": "1s",
+	}
+	if !reflect.DeepEqual(summary, wantSummary) {
+		t.Errorf("summary = %v, want %v", summary, wantSummary)
+	}
+}
+
+func TestWithSampling_nonPositiveTick(t *testing.T) {
+	for _, tick := range []time.Duration{0, -time.Second} {
+		var buf bytes.Buffer
+		logger := slog.New(NewErrorReportingHandler(&buf, nil, WithSampling(tick, 1, 0)))
+		for range 5 {
+			logger.Info("msg")
+		}
+		if got := bytes.Count(buf.Bytes(), []byte("\n")); got != 5 {
+			t.Errorf("tick %v: logged %d records, want 5", tick, got)
+		}
+	}
+}
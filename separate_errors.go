@@ -0,0 +1,104 @@
+package sloggcp
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+)
+
+// ErrorsKey is the suggested key for [WithSeparateErrors].
+const ErrorsKey = "errors"
+
+// WithSeparateErrors enables one error report per error, for errors logged together under key,
+// such as the failures of validating many fields:
+//
+//	logger.Error("invalid request", sloggcp.ErrorsKey, []error{errName, errEmail})
+//
+// The value of a top-level record attribute with key may be an []error,
+// or an error with an Unwrap() []error method, such as returned by [errors.Join].
+// For each non-nil error, a separate entry is written, which is a copy of the record
+// with the attribute replaced by the error under the first error key, see [WithErrorKeys].
+// So every entry is a proper error report, which Error Reporting groups and counts on its own.
+// The time, message, trace, labels and all other attributes of the record are repeated on every entry,
+// while a new insertId is generated for each, see [WithInsertIDGenerator].
+// Record attributes with the first error key are dropped from the entries.
+//
+// When the value contains no errors, the record is written once, without the attribute.
+// Other values are logged as regular attributes.
+// Attributes added through WithAttrs are not inspected.
+// By default, or when key is empty, no errors are reported separately.
+func WithSeparateErrors(key string) Option {
+	return func(c *config) {
+		c.separateErrorsKey = key
+	}
+}
+
+// handleSeparateErrors writes one entry per error in the attribute with [WithSeparateErrors] key.
+// It reports false if the record has no such attribute.
+func (h *handler) handleSeparateErrors(ctx context.Context, r slog.Record) (bool, error) {
+	errorKey := ErrorKey
+	if len(h.cfg.errorKeys) > 0 {
+		errorKey = h.cfg.errorKeys[0]
+	}
+	var (
+		errs  []error
+		found bool
+		rest  = make([]slog.Attr, 0, r.NumAttrs())
+	)
+	r.Attrs(func(a slog.Attr) bool {
+		if !found && a.Key == h.cfg.separateErrorsKey {
+			if errs, found = separateErrors(a.Value.Resolve().Any()); found {
+				return true
+			}
+		}
+		rest = append(rest, a)
+		return true
+	})
+	if !found {
+		return false, nil
+	}
+	if len(errs) == 0 {
+		return true, h.handle(ctx, newRecord(r, rest))
+	}
+	rest = slices.DeleteFunc(rest, func(a slog.Attr) bool {
+		return a.Key == errorKey
+	})
+	var handleErrs []error
+	for _, err := range errs {
+		entry := newRecord(r, rest)
+		entry.AddAttrs(slog.Any(errorKey, err))
+		if err := h.handle(ctx, entry); err != nil {
+			handleErrs = append(handleErrs, err)
+		}
+	}
+	return true, errors.Join(handleErrs...)
+}
+
+// separateErrors returns the non-nil errors of an []error,
+// or of an error with an Unwrap() []error method.
+func separateErrors(value any) ([]error, bool) {
+	var errs []error
+	switch v := value.(type) {
+	case []error:
+		errs = v
+	case interface{ Unwrap() []error }:
+		errs = v.Unwrap()
+	default:
+		return nil, false
+	}
+	nonNil := make([]error, 0, len(errs))
+	for _, err := range errs {
+		if err != nil {
+			nonNil = append(nonNil, err)
+		}
+	}
+	return nonNil, true
+}
+
+// newRecord returns a record with the metadata of r and the given attributes.
+func newRecord(r slog.Record, attrs []slog.Attr) slog.Record {
+	entry := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	entry.AddAttrs(attrs...)
+	return entry
+}
@@ -0,0 +1,130 @@
+package sloggcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWithSeparateErrors(t *testing.T) {
+	errA := errors.New("a failed")
+	errB := errors.New("b failed")
+	tests := []struct {
+		name  string
+		attrs []any
+		want  []map[string]any
+	}{
+		{
+			name:  "slice",
+			attrs: []any{"field", "v", ErrorsKey, []error{errA, nil, errB}},
+			want: []map[string]any{
+				{MessageKey: "a failed", ErrorKey: "a failed", "field": "v"},
+				{MessageKey: "b failed", ErrorKey: "b failed", "field": "v"},
+			},
+		},
+		{
+			name:  "joined",
+			attrs: []any{ErrorsKey, errors.Join(errA, errB)},
+			want: []map[string]any{
+				{MessageKey: "a failed", ErrorKey: "a failed"},
+				{MessageKey: "b failed", ErrorKey: "b failed"},
+			},
+		},
+		{
+			name:  "error attribute dropped",
+			attrs: []any{ErrorKey, "other", ErrorsKey, []error{errA}},
+			want: []map[string]any{
+				{MessageKey: "a failed", ErrorKey: "a failed"},
+			},
+		},
+		{
+			name:  "no errors",
+			attrs: []any{"field", "v", ErrorsKey, []error{nil}},
+			want: []map[string]any{
+				{MessageKey: "msg", "field": "v"},
+			},
+		},
+		{
+			name:  "no errors keeps error attribute",
+			attrs: []any{ErrorKey, "other", ErrorsKey, []error{}},
+			want: []map[string]any{
+				{MessageKey: "other", ErrorKey: "other"},
+			},
+		},
+		{
+			name:  "other value",
+			attrs: []any{ErrorsKey, "not a list"},
+			want: []map[string]any{
+				{MessageKey: "msg", ErrorsKey: "not a list"},
+			},
+		},
+		{
+			name:  "no attribute",
+			attrs: []any{ErrorKey, errA},
+			want: []map[string]any{
+				{MessageKey: "a failed", ErrorKey: "a failed"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(NewErrorReportingHandler(&buf, nil, WithSeparateErrors(ErrorsKey)))
+			logger.Error("msg", tt.attrs...)
+
+			var got []map[string]any
+			for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+				var entry map[string]any
+				if err := json.Unmarshal([]byte(line), &entry); err != nil {
+					t.Fatalf("Failed to decode log output %q: %v", line, err)
+				}
+				for _, key := range []string{TimeKey, SeverityKey, ErrorReportTypeKey} {
+					delete(entry, key)
+				}
+				got = append(got, entry)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("entries = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithSeparateErrors_metadata(t *testing.T) {
+	var buf bytes.Buffer
+	ids := 0
+	h := NewErrorReportingHandler(&buf, nil,
+		WithSeparateErrors(ErrorsKey),
+		WithLabels(map[string]string{"service": "api"}),
+		WithInsertIDGenerator(func() string {
+			ids++
+			return strconv.Itoa(ids)
+		}),
+	)
+	slog.New(h).With("request", "r1").Error("msg", ErrorsKey, []error{errors.New("a"), errors.New("b")})
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("entries = %d, want 2", len(lines))
+	}
+	var first, second map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{TimeKey, LabelsKey, "request"} {
+		if !reflect.DeepEqual(first[key], second[key]) || first[key] == nil {
+			t.Errorf("%s = %v and %v, want equal", key, first[key], second[key])
+		}
+	}
+	if first[InsertIDKey] == second[InsertIDKey] {
+		t.Errorf("%s = %v for both entries, want unique", InsertIDKey, first[InsertIDKey])
+	}
+}
@@ -3,13 +3,12 @@ package sloggcp
 
 import (
 	"context"
-	"encoding"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"slices"
 	"sync"
-	"time"
 )
 
 // Keys for attributes used in GCP structured logging.
@@ -24,14 +23,15 @@ type Level = slog.Level
 
 // Slog level aliases and extensions for GCP logging.
 const (
-	LevelDebug     Level = slog.LevelDebug    // Debug or trace information
-	LevelInfo      Level = slog.LevelInfo     // Routine information, such as ongoing status or performance
-	LevelNotice    Level = slog.LevelInfo + 2 // Normal but significant events
-	LevelWarning   Level = slog.LevelWarn     // Warning events might cause problems
-	LevelError     Level = slog.LevelError    // Error events are likely to cause problems
-	LevelCritical  Level = LevelError + 2     // Critical events cause more severe problems or outages
-	LevelAlert     Level = LevelError + 4     // A person must take an action immediately
-	LevelEmergency Level = LevelError + 6     // One or more systems are unusable
+	LevelDefault   Level = slog.LevelDebug - 4 // The log entry has no assigned severity level
+	LevelDebug     Level = slog.LevelDebug     // Debug or trace information
+	LevelInfo      Level = slog.LevelInfo      // Routine information, such as ongoing status or performance
+	LevelNotice    Level = slog.LevelInfo + 2  // Normal but significant events
+	LevelWarning   Level = slog.LevelWarn      // Warning events might cause problems
+	LevelError     Level = slog.LevelError     // Error events are likely to cause problems
+	LevelCritical  Level = LevelError + 2      // Critical events cause more severe problems or outages
+	LevelAlert     Level = LevelError + 4      // A person must take an action immediately
+	LevelEmergency Level = LevelError + 6      // One or more systems are unusable
 )
 
 // Severity values defined by GCP logging.
@@ -63,124 +63,351 @@ var DefaultOpts = slog.HandlerOptions{
 // Attribute values are encoded according to the following rules, in order:
 //   - Attributes with [slog.KindGroup] values are expanded into nested JSON objects.
 //   - Attributes with [slog.LogValuer] values are replaced by the result of their LogValue() method.
+//   - Attributes with values of a type registered through [WithTypeEncoder] are replaced by the result of the encoder.
+//   - Attributes with [json.RawMessage] values are embedded as-is. Invalid JSON is encoded as string.
 //   - Attributes with [json.Marshaler] or [encoding.TextMarshaler] values are encoded using the respective marshaling method.
 //   - Attributes with [error] values are replaced by the result of their Error() method.
 //   - Attributes with [fmt.Stringer] values are replaced by the result of their String() method.
 //   - All other attribute values are used as-is and handled according to [json.Marshal] rules.
 //
+// The function set through [WithMarshaler] replaces json.Marshal in the rules above.
+//
+// The values of attributes added with WithAttrs, including those set through [WithDefaultAttrs],
+// differ from the rules above: errors and Stringers, which do not implement a marshaling interface,
+// are encoded with json.Marshal, usually as JSON objects of their exported fields,
+// and durations as integer nanoseconds, see [WithDurationFormat].
+// The members of their groups are encoded according to the rules above.
+//
+// Values which cannot be encoded, for example channels or values with a failing MarshalJSON method,
+// are replaced by the string "!ERROR:" followed by the error, like [slog.JSONHandler] does.
+// The rest of the record is written as usual.
+//
 // When opts is nil, [DefaultOpts] is used.
 // If ReplaceAttr is set in opts, it is called before error reporting handling.
+// It is also called with the [slog.LevelKey] attribute of each record, to override the severity,
+// and with the [slog.TimeKey] attribute, to reformat or remove the time.
+// It is called for all attributes, including the members of groups and of groups returned by [slog.LogValuer]s,
+// with the keys of the enclosing groups. Attributes for which it returns an attribute without key are omitted.
+// Like slog specifies, the members of groups without key are inlined.
+// GCP specific behavior can be configured through additional [Option]s.
 //
-// When a record contains an attribute with key [ErrorKey],
-// an error report is created according to GCP error reporting specifications.
+// The returned handler, and the handlers derived from it, implement [Flusher], [Syncer], [io.Closer] and [WriterSetter].
+// Flush flushes the writer, such as a [bufio.Writer], if it implements [Flusher].
+// Sync flushes the writer and commits it to stable storage, if it implements [Syncer], such as an [os.File].
+// Close flushes the writer and closes it, if it implements [io.Closer].
+// Call Close on shutdown, for example in a deferred function in main,
+// so no buffered output is lost.
+//
+// When a record contains an attribute with key [ErrorKey]
+// (or one of the keys set through [WithErrorKeys]), an error report is created according to GCP error reporting specifications.
 // The message attribute will then contain error details, as required by GCP error reporting.
-// The passed log message is ignored.
+// The passed log message is ignored, unless it is preserved through [WithLogMessageKey].
+// Benign errors, by default [context.Canceled] and [context.DeadlineExceeded], do not create error reports,
+// see [WithBenignErrors].
 //
 // Certain attributes depend on the type of the error value.
 // The "message" ([MessageKey]) attribute value is determined in the following order:
-//  1. [StackTraceError] type: The stack trace output.
+//  1. [StackTraceError] type, or an error with a github.com/pkg/errors style StackTrace method:
+//     The error string, followed by the stack trace.
 //  2. [string] and [error] types: The error string.
 //
 // The "reportLocation" ([ReportLocationKey]) attribute is added
-// if the error value implements [ReportLocationError].
+// if the error value implements [ReportLocationError] or [ReportLocationsError].
+// The further locations of a ReportLocationsError are added under [CallerLocationsKey].
 //
 // The value associated with [ErrorKey] is determined in the following order:
 //  1. [slog.LogValuer] type: The result of its LogValue() method.
 //  2. [string] and [error] types: The error string.
-func NewErrorReportingHandler(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+//     An error attribute added with WithAttrs keeps its value, encoded like other such attributes.
+//
+// [New] creates the same handler, with the [slog.HandlerOptions] set through [Option]s as well.
+func NewErrorReportingHandler(w io.Writer, opts *slog.HandlerOptions, options ...Option) slog.Handler {
 	if opts == nil {
 		opts = &DefaultOpts
 	}
-	if opts.Level == nil {
-		opts.Level = DefaultOpts.Level
+	return New(w, append([]Option{withHandlerOptions(*opts)}, options...)...)
+}
+
+// New outputs GCP compatible JSON logs to the given writer, like [NewErrorReportingHandler],
+// with all behavior configured through options.
+// The [slog.HandlerOptions] are set with [WithLevel], [WithAddSource] and [WithReplaceAttr].
+// Without options, the handler behaves like NewErrorReportingHandler with [DefaultOpts].
+func New(w io.Writer, options ...Option) slog.Handler {
+	cfg := newConfig(options)
+	cfg.replaceAttr = cfg.handlerOptions.ReplaceAttr
+	h := &handler{
+		opts:     &cfg.handlerOptions,
+		cfg:      cfg,
+		prepared: &prepared{levels: make([][]field, 1), labels: cfg.labels},
+		out:      newOutput(w),
 	}
-	return &handler{
-		opts:    opts,
-		mtx:     new(sync.Mutex),
-		encoder: json.NewEncoder(w),
+	if len(cfg.defaultAttrs) > 0 {
+		h = h.withGroupOrAttrs(groupOrAttrs{attrs: cfg.defaultAttrs})
 	}
+	h.root = h.prepared
+	return h
 }
 
+// NewUnsynchronizedHandler creates a handler like [New], which does not lock around writes.
+//
+// WARNING: The handler is not safe for concurrent use. Handling records from more than one goroutine
+// at a time, through the handler or any handler derived from it with WithAttrs and WithGroup,
+// interleaves or corrupts the output and is a data race. This includes calls of Flush, Sync, Close
+// and SetWriter concurrent with logging, and writers and reporters which log themselves on another goroutine.
+// Only use it where the caller guarantees that all logging happens serially, such as in a CLI or a script
+// which logs from a single goroutine. If in doubt, use New: an uncontended lock costs little.
+func NewUnsynchronizedHandler(w io.Writer, options ...Option) slog.Handler {
+	h := New(w, options...).(*handler)
+	h.out.unsynchronized = true
+	return h
+}
+
+// handler is the handler returned by [NewErrorReportingHandler].
+// Handlers derived with WithAttrs and WithGroup share the options, the config and the output,
+// which are never modified after construction, except for the output under its mutex.
+// Each derived handler owns a new prepared state, so no handler modifies state visible to another.
+// Per record state is kept in an encodeState, which is not shared.
 type handler struct {
-	opts    *slog.HandlerOptions
-	goas    []groupOrAttrs
-	mtx     *sync.Mutex // protects encoder
-	encoder *json.Encoder
+	opts      *slog.HandlerOptions // shared, read-only
+	cfg       *config              // shared, read-only
+	prepared  *prepared            // owned, read-only after creation
+	root      *prepared            // prepared state of the handler returned by New, shared, read-only
+	out       *output              // shared
+	minLevels []slog.Leveler       // set through MinLevel, read-only after creation
 }
 
-// Enabled implements [slog.Handler].
-func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
-	return level >= h.opts.Level.Level()
+// output is the writer shared by a handler and the handlers derived from it.
+type output struct {
+	mtx            sync.Mutex // protects w, syncer and writes to w
+	unsynchronized bool       // mtx is not used, see NewUnsynchronizedHandler
+	w              io.Writer
+	syncer         Syncer // w, if it implements Syncer
 }
 
-// Handle implements [slog.Handler].
-func (h *handler) Handle(_ context.Context, r slog.Record) error {
-	n := 4 + r.NumAttrs() + len(h.goas)
-	out := make(map[string]any, n)
-	if !r.Time.IsZero() {
-		out[TimeKey] = r.Time.Format(time.RFC3339Nano)
+// newOutput returns the output for w.
+func newOutput(w io.Writer) *output {
+	o := &output{}
+	o.setWriter(w)
+	return o
+}
+
+// lock locks o.mtx, unless o is unsynchronized.
+func (o *output) lock() {
+	if !o.unsynchronized {
+		o.mtx.Lock()
 	}
-	if h.opts.AddSource {
-		if source := r.Source(); source != nil {
-			out[SourceLocationKey] = source
-		}
+}
+
+// unlock unlocks o.mtx, unless o is unsynchronized.
+func (o *output) unlock() {
+	if !o.unsynchronized {
+		o.mtx.Unlock()
 	}
-	if r.Message != "" {
-		out[MessageKey] = r.Message
+}
+
+// setWriter replaces the writer. o.mtx must be held, unless o is not shared yet.
+func (o *output) setWriter(w io.Writer) {
+	o.w = w
+	o.syncer, _ = w.(Syncer)
+}
+
+// Enabled implements [slog.Handler].
+// The levels set through [MinLevel], [WithSuppressOnCancel] and [WithContextLevel], if any, are consulted as well.
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if level < h.opts.Level.Level() || h.belowMinLevel(level) || h.cfg.suppressedOnCancel(ctx, level) {
+		return false
 	}
-	// Handle state from WithGroup and WithAttrs.
-	goas := h.goas
-	out[SeverityKey] = severityFromLevel(r.Level)
-	if r.NumAttrs() == 0 {
-		// If the record has no Attrs, remove groups at the end of the list; they are empty.
-		for len(goas) > 0 && goas[len(goas)-1].group != "" {
-			goas = goas[:len(goas)-1]
-		}
+	if h.cfg.contextLevel != nil && ctx != nil {
+		return level >= h.cfg.contextLevel(ctx)
 	}
-	// Try to find error attributes only in top-level attrs.
-	for _, goa := range goas {
-		if goa.group != "" {
-			break
-		}
-		for _, a := range goa.attrs {
-			if checkAndSetErrorReport(a, out) {
-				break
+	return true
+}
+
+// Handle implements [slog.Handler].
+// It neither modifies nor retains r, so the same record can be passed to other handlers.
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if h.cfg.sampler != nil {
+		keep, dropped := h.cfg.sampler.sample(r.Level)
+		if dropped != nil {
+			if err := h.logSamplingSummary(dropped); err != nil {
+				return err
 			}
 		}
+		if !keep {
+			return nil
+		}
+	}
+	if h.cfg.separateErrorsKey != "" {
+		if ok, err := h.handleSeparateErrors(ctx, r); ok {
+			return err
+		}
 	}
+	return h.handle(ctx, r)
+}
 
-	var (
-		groups []string
-		group  = out
-	)
-	for _, goa := range goas {
-		if goa.group != "" {
-			// start a new group
-			newGroup := make(map[string]any)
-			group[goa.group] = newGroup
-			group = newGroup
-			groups = append(groups, goa.group)
-		} else {
-			for _, a := range goa.attrs {
-				a = h.replaceAttr(groups, a)
-				group[a.Key] = a.Value.Any()
+// handle encodes and writes the record.
+func (h *handler) handle(ctx context.Context, r slog.Record) error {
+	s := newEncodeState()
+	defer s.free()
+	out := s.top()
+	t := r.Time
+	if t.IsZero() && h.cfg.clock != nil {
+		t = h.cfg.clock()
+	}
+	if !t.IsZero() || h.cfg.alwaysTime {
+		h.addRecordTime(out, t)
+	}
+	if h.addSource(r.Level) {
+		if source := r.Source(); source != nil && (source.Function != "" || source.File != "") {
+			if v := h.cfg.formatSource(source); v != nil {
+				out.add(SourceLocationKey, slog.AnyValue(v))
 			}
 		}
 	}
+	if r.Message != "" {
+		out.add(MessageKey, slog.StringValue(r.Message))
+	}
+	severity := h.severity(r.Level)
+	out.add(SeverityKey, slog.StringValue(severity))
+	h.cfg.setTrace(ctx, out)
+	setHTTPRequest(ctx, out)
+	if h.cfg.insertIDGenerator != nil {
+		out.add(InsertIDKey, slog.StringValue(h.cfg.insertIDGenerator()))
+	}
+	h.cfg.addGoroutineID(out)
 
-	// handle record attrs
+	// Add state from WithGroup and WithAttrs.
+	// If the record has no Attrs, trailing empty groups are omitted.
+	s.load(h.prepared, r.NumAttrs() == 0)
+	if labels := h.cfg.labelsFromContext(ctx); len(labels) > 0 {
+		s.mergeLabels(slog.AnyValue(labels))
+	}
+	s.addContextAttrs(ctx, h)
 	r.Attrs(func(a slog.Attr) bool {
-		a = h.replaceAttr(groups, a)
-		if len(groups) == 0 {
-			checkAndSetErrorReport(a, out)
-		}
-		group[a.Key] = extractValue(a.Value)
+		s.addAttr(h, a)
 		return true
 	})
-	h.mtx.Lock()
-	defer h.mtx.Unlock()
-	if err := h.encoder.Encode(out); err != nil {
-		return fmt.Errorf("sloggcp handler: %w", err)
+	out = s.top() // opening groups may have reallocated the objects
+	if len(s.labels) > 0 {
+		out.add(LabelsKey, slog.AnyValue(s.labels))
+	}
+	var report *ErrorReport
+	switch {
+	case s.errorFound && h.cfg.isBenignError(s.errorAttr.Value.Any()):
+		severity = lowerSeverity(out, severity)
+	case s.errorFound:
+		if level, ok := errorSeverity(s.errorAttr.Value.Any()); ok {
+			severity = h.severity(level)
+			out.add(SeverityKey, slog.StringValue(severity))
+		} else if h.cfg.errorReportSeverity {
+			severity = raiseSeverity(out, severity)
+		}
+		if h.cfg.logMessageKey != "" && r.Message != "" {
+			out.add(h.cfg.logMessageKey, slog.StringValue(r.Message))
+		}
+		report = h.cfg.setErrorReport(out, s.errorAttr, r.PC)
+	}
+	if s.errorFound && !s.errorGroup {
+		h.cfg.addErrorValue(out, s.errorAttr, s.errorWith)
+	}
+	if h.cfg.severityNumber {
+		if number, ok := severityNumber(severity); ok {
+			out.add(SeverityNumberKey, slog.IntValue(number))
+		}
+	}
+
+	if err := s.encode(h.cfg); err != nil {
+		err = fmt.Errorf("sloggcp handler: %w", err)
+		h.cfg.handleError(err)
+		return err
+	}
+	err := h.write(s.buf)
+	if report != nil {
+		report.Time = t
+		h.cfg.errorReporter.ReportError(ctx, *report)
+	}
+	return err
+}
+
+// Flusher is implemented by writers and handlers which buffer output,
+// such as [bufio.Writer], [BatchWriter] and the handler returned by [NewErrorReportingHandler].
+type Flusher interface {
+	Flush() error
+}
+
+// Flush implements [Flusher].
+// It flushes the writer, if it implements [Flusher].
+func (h *handler) Flush() error {
+	h.out.lock()
+	defer h.out.unlock()
+	return h.out.flush()
+}
+
+// Syncer is implemented by writers which commit written data to stable storage,
+// such as [os.File], and by the handler returned by [NewErrorReportingHandler].
+type Syncer interface {
+	Sync() error
+}
+
+// Sync implements [Syncer].
+// It flushes the writer, if it implements [Flusher], and then syncs it, if it implements [Syncer].
+// Call Sync where log output must survive a crash of the process or the machine,
+// for example before exiting on a fatal error.
+// Sync is a no-op for writers which implement neither.
+// A writer wrapped by a buffering writer, such as a [BatchWriter], is not synced.
+func (h *handler) Sync() error {
+	h.out.lock()
+	defer h.out.unlock()
+	err := h.out.flush()
+	if h.out.syncer != nil {
+		err = errors.Join(err, h.out.syncer.Sync())
+	}
+	return err
+}
+
+// Close implements [io.Closer].
+// It flushes the writer and closes it, if it implements [io.Closer].
+func (h *handler) Close() error {
+	h.out.lock()
+	defer h.out.unlock()
+	err := h.out.flush()
+	if c, ok := h.out.w.(io.Closer); ok {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// WriterSetter is implemented by the handler returned by [NewErrorReportingHandler],
+// to replace its writer at runtime, for example when reloading configuration.
+//
+//	handler.(sloggcp.WriterSetter).SetWriter(file)
+type WriterSetter interface {
+	SetWriter(w io.Writer) (previous io.Writer)
+}
+
+// SetWriter implements [WriterSetter].
+// It replaces the writer of the handler and of all handlers sharing its root,
+// that is the handler returned by NewErrorReportingHandler and all handlers derived from it
+// with WithAttrs and WithGroup, no matter whether they were derived before or after the call.
+// Records handled concurrently are written entirely to either the previous or the new writer.
+// The previous writer is flushed if it implements [Flusher], and returned, so the caller can close it.
+// A flush error is passed to the function set through [WithErrorHandler].
+func (h *handler) SetWriter(w io.Writer) (previous io.Writer) {
+	h.out.lock()
+	err := h.out.flush()
+	previous = h.out.w
+	h.out.setWriter(w)
+	h.out.unlock()
+	if err != nil {
+		h.cfg.handleError(fmt.Errorf("sloggcp handler: flush previous writer: %w", err))
+	}
+	return previous
+}
+
+// flush flushes the writer. o.mtx must be held.
+func (o *output) flush() error {
+	if f, ok := o.w.(Flusher); ok {
+		return f.Flush()
 	}
 	return nil
 }
@@ -193,8 +420,13 @@ func (h *handler) replaceAttr(groups []string, a slog.Attr) slog.Attr {
 }
 
 // WithAttrs implements [slog.Handler].
+// Attributes returned by [MinLevel] raise the minimum level of the returned handler.
 func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return h.withGroupOrAttrs(groupOrAttrs{attrs: attrs})
+	if !slices.ContainsFunc(attrs, func(a slog.Attr) bool { return a.Key == MinLevelKey }) {
+		return h.withGroupOrAttrs(groupOrAttrs{attrs: attrs})
+	}
+	h2 := *h
+	return h2.withGroupOrAttrs(groupOrAttrs{attrs: h2.withMinLevels(attrs)})
 }
 
 // WithGroup implements [slog.Handler].
@@ -208,61 +440,20 @@ type groupOrAttrs struct {
 	attrs []slog.Attr // attrs if non-empty
 }
 
+// withGroupOrAttrs returns a new handler with the group or attrs
+// added to the prepared state.
+// The attrs are processed and encoded once,
+// so Handle only has to copy the result for every record.
 func (h *handler) withGroupOrAttrs(goa groupOrAttrs) *handler {
-	h2 := *h
-	h2.goas = make([]groupOrAttrs, len(h.goas)+1)
-	copy(h2.goas, h.goas)
-	h2.goas[len(h2.goas)-1] = goa
-	return &h2
-}
-
-func extractValue(v slog.Value) any {
-	if v.Kind() == slog.KindGroup {
-		m := make(map[string]any)
-		attr := v.Group()
-		for _, a := range attr {
-			m[a.Key] = extractValue(a.Value)
-		}
-		return m
-	}
-	switch tv := v.Any().(type) {
-	case slog.LogValuer:
-		return extractValue(tv.LogValue())
-	case json.Marshaler, encoding.TextMarshaler:
-		return tv
-	case error:
-		return tv.Error()
-	case fmt.Stringer:
-		return tv.String()
-	default:
-		return tv
+	s := &encodeState{levels: make([]object, 1, len(h.prepared.levels)+1)}
+	s.load(h.prepared, false)
+	if goa.group != "" {
+		s.openGroup(goa.group, h.cfg.groupSeparator != "")
 	}
-}
-
-func severityFromLevel(level slog.Level) string {
-	if level >= LevelEmergency {
-		return EmergencySeverity
-	}
-	if level >= LevelAlert {
-		return AlertSeverity
-	}
-	if level >= LevelCritical {
-		return CriticalSeverity
-	}
-	if level >= LevelError {
-		return ErrorSeverity
-	}
-	if level >= LevelWarning {
-		return WarningSeverity
+	for _, a := range goa.attrs {
+		s.addAttr(h, a)
 	}
-	if level >= LevelNotice {
-		return NoticeSeverity
-	}
-	if level >= LevelInfo {
-		return InfoSeverity
-	}
-	if level >= LevelDebug {
-		return DebugSeverity
-	}
-	return DefaultSeverity
+	h2 := *h
+	h2.prepared = s.prepare(h.cfg)
+	return &h2
 }
@@ -1,12 +1,22 @@
 package sloggcp
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 )
 
 type stringer struct{}
@@ -359,3 +369,813 @@ func Test_severityFromLevel(t *testing.T) {
 		})
 	}
 }
+
+// handlerBenchmarks are run by BenchmarkHandler and, as reference, by BenchmarkJSONHandler.
+// Compare runs with benchstat, for example:
+//
+//	go test -run '^$' -bench Handler -benchmem -count 10 > new.txt
+//	benchstat old.txt new.txt
+var handlerBenchmarks = []struct {
+	name    string
+	options []Option
+	derive  func(logger *slog.Logger) *slog.Logger
+	log     func(logger *slog.Logger)
+}{
+	{
+		name: "info",
+		log: func(logger *slog.Logger) {
+			logger.Info("this is info", "string", "value", "int", 42, "bool", true)
+		},
+	},
+	{
+		name: "group",
+		log: func(logger *slog.Logger) {
+			logger.WithGroup("group").With("bar", "baz").Info("this is info", "group", groupTypeTest, "stringer", stringer{})
+		},
+	},
+	{
+		name: "group values",
+		log: func(logger *slog.Logger) {
+			logger.Info("this is info",
+				slog.Group("request", "method", "GET", "path", "/foo",
+					slog.Group("header", "accept", "*/*", "user-agent", "curl")),
+				"group", groupTypeTest,
+			)
+		},
+	},
+	{
+		name: "error",
+		log: func(logger *slog.Logger) {
+			logger.Error("error message", "error", mockStackAndReport{true})
+		},
+	},
+	{
+		name: "error stack trace",
+		log: func(logger *slog.Logger) {
+			logger.Error("error message", "error", benchmarkError)
+		},
+	},
+	{
+		name: "derived",
+		derive: func(logger *slog.Logger) *slog.Logger {
+			return logger.With("service", "api", "version", "1.2.3", "group", groupTypeTest).
+				WithGroup("request").With("method", "GET", "path", "/foo", "status", 200)
+		},
+		log: func(logger *slog.Logger) {
+			logger.Info("this is info", "string", "value", "int", 42)
+		},
+	},
+	{
+		name: "options",
+		options: []Option{
+			WithAddSource(true),
+			WithLabels(map[string]string{"env": "prod"}),
+			WithTraceExtractor(TraceFromContext),
+		},
+		log: func(logger *slog.Logger) {
+			logger.InfoContext(benchmarkContext, "this is info", "string", "value", "int", 42)
+		},
+	},
+}
+
+// benchmarkError is created once, so its stack trace is formatted on every log call.
+var benchmarkError = NewError("oops")
+
+var benchmarkContext = func() context.Context {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(CloudTraceContextHeader, "105445aa7843bc8bf206b12000100000/1;o=1")
+	ctx, _ := RequestLogger(r, slog.New(slog.DiscardHandler), "my-project")
+	return ctx
+}()
+
+func BenchmarkHandler(b *testing.B) {
+	for _, bb := range handlerBenchmarks {
+		b.Run(bb.name, func(b *testing.B) {
+			logger := slog.New(New(io.Discard, bb.options...))
+			if bb.derive != nil {
+				logger = bb.derive(logger)
+			}
+			b.ReportAllocs()
+			for b.Loop() {
+				bb.log(logger)
+			}
+		})
+	}
+}
+
+// BenchmarkUnsynchronizedHandler runs the handler benchmarks with [NewUnsynchronizedHandler].
+func BenchmarkUnsynchronizedHandler(b *testing.B) {
+	for _, bb := range handlerBenchmarks {
+		b.Run(bb.name, func(b *testing.B) {
+			logger := slog.New(NewUnsynchronizedHandler(io.Discard, bb.options...))
+			if bb.derive != nil {
+				logger = bb.derive(logger)
+			}
+			b.ReportAllocs()
+			for b.Loop() {
+				bb.log(logger)
+			}
+		})
+	}
+}
+
+// BenchmarkJSONHandler runs the handler benchmarks with [slog.JSONHandler] and [ReplaceAttr],
+// as reference for the overhead of GCP specific features.
+func BenchmarkJSONHandler(b *testing.B) {
+	for _, bb := range handlerBenchmarks {
+		if bb.options != nil {
+			continue
+		}
+		b.Run(bb.name, func(b *testing.B) {
+			logger := slog.New(slog.NewJSONHandler(io.Discard, &slog.HandlerOptions{ReplaceAttr: ReplaceAttr}))
+			if bb.derive != nil {
+				logger = bb.derive(logger)
+			}
+			b.ReportAllocs()
+			for b.Loop() {
+				bb.log(logger)
+			}
+		})
+	}
+}
+
+type countingValuer struct {
+	calls *int
+}
+
+func (v countingValuer) LogValue() slog.Value {
+	*v.calls++
+	return slog.StringValue("value")
+}
+
+func TestHandler_derived(t *testing.T) {
+	var (
+		buf   bytes.Buffer
+		calls int
+	)
+	parent := slog.New(NewErrorReportingHandler(&buf, nil)).
+		With("valuer", countingValuer{&calls}).
+		WithGroup("group").
+		With("foo", "bar")
+	if calls != 1 {
+		t.Errorf("LogValue() calls after With = %d, want 1", calls)
+	}
+
+	// Derived handlers must not share their prepared fields.
+	child1 := parent.With("child", 1)
+	child2 := parent.With("child", 2)
+	child1.Info("one")
+	child2.Info("two")
+	parent.Info("parent", "error", "not reported in group")
+	if calls != 1 {
+		t.Errorf("LogValue() calls after Handle = %d, want 1", calls)
+	}
+
+	want := []string{
+		`{"group":{"child":1,"foo":"bar"},"message":"one","severity":"INFO","valuer":"value"}`,
+		`{"group":{"child":2,"foo":"bar"},"message":"two","severity":"INFO","valuer":"value"}`,
+		`{"group":{"error":"not reported in group","foo":"bar"},"message":"parent","severity":"INFO","valuer":"value"}`,
+	}
+	dec := json.NewDecoder(&buf)
+	for _, w := range want {
+		got := make(map[string]any)
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("Failed to decode log output: %v", err)
+		}
+		delete(got, TimeKey)
+		gotJSON, _ := json.Marshal(got)
+		if string(gotJSON) != w {
+			t.Errorf("log output = %s, want %s", gotJSON, w)
+		}
+	}
+}
+
+func TestHandler_replaceAttrEmptyKey(t *testing.T) {
+	dropSecret := func(_ []string, a slog.Attr) slog.Attr {
+		if a.Key == "secret" {
+			a.Key = ""
+		}
+		return a
+	}
+	tests := []struct {
+		name    string
+		options []Option
+		log     func(*slog.Logger)
+		want    map[string]any
+	}{
+		{
+			name:    "record",
+			options: []Option{WithReplaceAttr(dropSecret)},
+			log:     func(l *slog.Logger) { l.Info("msg", "secret", "s", "k", "v") },
+			want:    map[string]any{"k": "v"},
+		},
+		{
+			name:    "With",
+			options: []Option{WithReplaceAttr(dropSecret)},
+			log:     func(l *slog.Logger) { l.With("secret", "s").WithGroup("g").With("secret", "s").Info("msg", "k", "v") },
+			want:    map[string]any{"g": map[string]any{"k": "v"}},
+		},
+		{
+			name:    "group member",
+			options: []Option{WithReplaceAttr(dropSecret)},
+			log:     func(l *slog.Logger) { l.Info("msg", slog.Group("g", "secret", "s", "k", "v")) },
+			want:    map[string]any{"g": map[string]any{"k": "v"}},
+		},
+		{
+			name:    "flattened group member",
+			options: []Option{WithReplaceAttr(dropSecret), WithGroupSeparator(".")},
+			log:     func(l *slog.Logger) { l.Info("msg", slog.Group("g", "secret", "s", "k", "v")) },
+			want:    map[string]any{"g.k": "v"},
+		},
+		{
+			name: "without ReplaceAttr",
+			log:  func(l *slog.Logger) { l.Info("msg", "", "s", slog.Group("g", "", "s", "k", "v")) },
+			want: map[string]any{"g": map[string]any{"k": "v"}},
+		},
+		{
+			name: "groups inlined",
+			log: func(l *slog.Logger) {
+				l.Info("msg", slog.Group("", "a", 1), slog.Group("g", slog.Group("", "b", 2)))
+			},
+			want: map[string]any{"a": float64(1), "g": map[string]any{"b": float64(2)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.log(slog.New(New(&buf, tt.options...)))
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			for _, key := range []string{TimeKey, SeverityKey, MessageKey} {
+				delete(got, key)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_inlineGroups(t *testing.T) {
+	// Groups without key are inlined like by the handlers of the slog package.
+	inline := logValuerFunc(func() slog.Value {
+		return slog.GroupValue(slog.String("x", "1"), slog.String("y", "2"))
+	})
+	tests := []struct {
+		name string
+		log  func(*slog.Logger)
+	}{
+		{
+			name: "record",
+			log:  func(l *slog.Logger) { l.Info("msg", "a", 1, slog.Group("", "b", 2, "c", 3)) },
+		},
+		{
+			name: "nested",
+			log:  func(l *slog.Logger) { l.Info("msg", slog.Group("g", slog.Group("", "b", 2, slog.Group("", "c", 3)))) },
+		},
+		{
+			name: "With",
+			log: func(l *slog.Logger) {
+				l.With(slog.Group("", "a", 1)).WithGroup("g").With(slog.Group("", "b", 2)).Info("msg", "c", 3)
+			},
+		},
+		{
+			name: "LogValuer",
+			log:  func(l *slog.Logger) { l.Info("msg", slog.Any("", inline), slog.Group("g", slog.Any("", inline))) },
+		},
+		{
+			name: "empty",
+			log:  func(l *slog.Logger) { l.Info("msg", "a", 1, slog.Group(""), slog.Group("g", slog.Group(""))) },
+		},
+		{
+			name: "group of inline group",
+			log:  func(l *slog.Logger) { l.Info("msg", slog.Group("g", slog.Group("", slog.Group("h", "a", 1)))) },
+		},
+	}
+	decode := func(t *testing.T, data []byte) map[string]any {
+		var got map[string]any
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Failed to decode log output %s: %v", data, err)
+		}
+		for _, key := range []string{TimeKey, SeverityKey, MessageKey, slog.LevelKey, slog.MessageKey} {
+			delete(got, key)
+		}
+		return got
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf, want bytes.Buffer
+			tt.log(slog.New(New(&buf)))
+			tt.log(slog.New(slog.NewJSONHandler(&want, nil)))
+
+			if got, want := decode(t, buf.Bytes()), decode(t, want.Bytes()); !reflect.DeepEqual(got, want) {
+				t.Errorf("log output = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestHandler_recordNotModified(t *testing.T) {
+	// A record may be retained and handled again, for example by a fan-out handler,
+	// so Handle must neither modify the record nor the values of its attributes.
+	tests := []struct {
+		name    string
+		options []Option
+		derive  func(slog.Handler) slog.Handler
+	}{
+		{name: "default"},
+		{name: "separate errors", options: []Option{WithSeparateErrors(ErrorsKey)}},
+		{name: "flattened groups", options: []Option{WithGroupSeparator(".")}},
+		{name: "derived", derive: func(h slog.Handler) slog.Handler {
+			return h.WithAttrs([]slog.Attr{slog.String("a", "b")}).WithGroup("g")
+		}},
+		{name: "middleware", derive: func(h slog.Handler) slog.Handler {
+			return NewGCPMiddleware(h).WithGroup("g")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := New(&buf, tt.options...)
+			if tt.derive != nil {
+				h = tt.derive(h)
+			}
+			var pcs [1]uintptr
+			runtime.Callers(1, pcs[:])
+			recordTime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+			r := slog.NewRecord(recordTime, slog.LevelInfo, "msg", pcs[0])
+			r.AddAttrs(
+				slog.String("k", "v"),
+				slog.Group("group", slog.Int("n", 1), slog.Group("", slog.Bool("inline", true))),
+				slog.Any(ErrorKey, NewError("oops")),
+				slog.Any(ErrorsKey, []error{errors.New("a"), errors.New("b")}),
+				Labels(map[string]string{"l": "v"}),
+				slog.Int("beyond inline attributes", 6),
+			)
+			want := recordAttrs(r)
+			clone := r.Clone()
+
+			var outputs []string
+			for range 2 {
+				buf.Reset()
+				if err := h.Handle(t.Context(), r); err != nil {
+					t.Fatal(err)
+				}
+				outputs = append(outputs, buf.String())
+			}
+			if outputs[0] != outputs[1] {
+				t.Errorf("outputs of the same record differ:\n%s\n%s", outputs[0], outputs[1])
+			}
+			if got := recordAttrs(r); !reflect.DeepEqual(got, want) {
+				t.Errorf("record attributes after Handle = %v, want %v", got, want)
+			}
+			if r.Time != clone.Time || r.Level != clone.Level || r.Message != clone.Message || r.PC != clone.PC {
+				t.Errorf("record after Handle = %v, want %v", r, clone)
+			}
+		})
+	}
+}
+
+// recordAttrs returns the string representations of the attributes of r.
+func recordAttrs(r slog.Record) []string {
+	var attrs []string
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a.String())
+		return true
+	})
+	return attrs
+}
+
+func TestHandler_replaceAttrNested(t *testing.T) {
+	var calls [][]string
+	replaceAttr := func(groups []string, a slog.Attr) slog.Attr {
+		calls = append(calls, append(slices.Clone(groups), a.Key))
+		switch a.Key {
+		case "password":
+			return slog.String(a.Key, "***")
+		case "drop":
+			return slog.Attr{}
+		}
+		return a
+	}
+	var buf bytes.Buffer
+	logger := slog.New(NewErrorReportingHandler(&buf, &slog.HandlerOptions{ReplaceAttr: replaceAttr}))
+	logger.WithGroup("g").Info("",
+		slog.Group("req",
+			slog.Group("auth", "password", "secret", "drop", 1),
+			"user", userValuer{name: "u1", password: "secret"},
+		),
+		"drop", 2,
+	)
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode log output: %v", err)
+	}
+	want := map[string]any{
+		"g": map[string]any{
+			"req": map[string]any{
+				"auth": map[string]any{"password": "***"},
+				"user": map[string]any{"name": "u1", "password": "***"},
+			},
+		},
+	}
+	delete(got, TimeKey)
+	delete(got, SeverityKey)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("log output = %v, want %v", got, want)
+	}
+	wantCalls := [][]string{
+		{slog.TimeKey},
+		{slog.LevelKey},
+		{"g", "req"},
+		{"g", "drop"},
+		{"g", "req", "auth"},
+		{"g", "req", "user"},
+		{"g", "req", "auth", "password"},
+		{"g", "req", "auth", "drop"},
+		{"g", "req", "user", "name"},
+		{"g", "req", "user", "password"},
+	}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("ReplaceAttr calls = %v, want %v", calls, wantCalls)
+	}
+}
+
+// closeWriter records the data written and whether it was closed.
+type closeWriter struct {
+	bytes.Buffer
+	closed bool
+	err    error
+}
+
+func (w *closeWriter) Close() error {
+	w.closed = true
+	return w.err
+}
+
+func TestHandler_FlushClose(t *testing.T) {
+	errClose := errors.New("close failed")
+	tests := []struct {
+		name       string
+		closeErr   error
+		wantClosed bool
+		wantErr    error
+	}{
+		{name: "closed", wantClosed: true},
+		{name: "close error", closeErr: errClose, wantClosed: true, wantErr: errClose},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &closeWriter{err: tt.closeErr}
+			buffered := struct {
+				*bufio.Writer
+				io.Closer
+			}{bufio.NewWriter(out), out}
+			h := NewErrorReportingHandler(buffered, nil)
+			derived := h.WithAttrs([]slog.Attr{slog.String("k", "v")})
+			slog.New(derived).Info("msg")
+			if out.Len() != 0 {
+				t.Fatalf("output written before Flush: %s", out.String())
+			}
+
+			if err := derived.(Flusher).Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			if !bytes.Contains(out.Bytes(), []byte(`"k":"v"`)) {
+				t.Errorf("output after Flush = %s", out.String())
+			}
+
+			slog.New(h).Info("last")
+			if err := h.(io.Closer).Close(); !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("Close() error = %v, want %v", err, tt.wantErr)
+			}
+			if out.closed != tt.wantClosed {
+				t.Errorf("closed = %v, want %v", out.closed, tt.wantClosed)
+			}
+			if !bytes.Contains(out.Bytes(), []byte(`"message":"last"`)) {
+				t.Errorf("output after Close = %s", out.String())
+			}
+		})
+	}
+}
+
+func TestHandler_FlushClose_plainWriter(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewErrorReportingHandler(&buf, nil)
+	if err := h.(Flusher).Flush(); err != nil {
+		t.Errorf("Flush() error = %v", err)
+	}
+	if err := h.(io.Closer).Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+// syncWriter records the data written, and the data synced by Sync.
+type syncWriter struct {
+	bytes.Buffer
+	synced string
+	err    error
+}
+
+func (w *syncWriter) Sync() error {
+	w.synced = w.String()
+	return w.err
+}
+
+func TestHandler_Sync(t *testing.T) {
+	errSync := errors.New("sync failed")
+	tests := []struct {
+		name    string
+		syncErr error
+	}{
+		{name: "synced"},
+		{name: "sync error", syncErr: errSync},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &syncWriter{err: tt.syncErr}
+			buffered := struct {
+				*bufio.Writer
+				Syncer
+			}{bufio.NewWriter(out), out}
+			h := NewErrorReportingHandler(buffered, nil)
+			derived := h.WithGroup("g")
+			slog.New(derived).Info("msg")
+
+			if err := derived.(Syncer).Sync(); !errors.Is(err, tt.syncErr) || (err == nil) != (tt.syncErr == nil) {
+				t.Errorf("Sync() error = %v, want %v", err, tt.syncErr)
+			}
+			if !bytes.Contains([]byte(out.synced), []byte(`"message":"msg"`)) {
+				t.Errorf("synced output = %q, want flushed record", out.synced)
+			}
+		})
+	}
+}
+
+func TestHandler_Sync_plainWriter(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewErrorReportingHandler(&buf, nil)
+	if err := h.(Syncer).Sync(); err != nil {
+		t.Errorf("Sync() error = %v", err)
+	}
+
+	out := &syncWriter{}
+	h.(WriterSetter).SetWriter(out)
+	slog.New(h).Info("msg")
+	if err := h.(Syncer).Sync(); err != nil {
+		t.Errorf("Sync() error = %v", err)
+	}
+	if out.synced == "" {
+		t.Error("writer set through SetWriter not synced")
+	}
+}
+
+func TestHandler_SetWriter(t *testing.T) {
+	var first, second bytes.Buffer
+	buffered := bufio.NewWriter(&first)
+	h := NewErrorReportingHandler(buffered, nil)
+	before := slog.New(h.WithAttrs([]slog.Attr{slog.String("derived", "before")}))
+	before.Info("one")
+
+	previous := h.(WriterSetter).SetWriter(&second)
+	if previous != buffered {
+		t.Errorf("SetWriter() = %v, want previous writer", previous)
+	}
+	if !bytes.Contains(first.Bytes(), []byte(`"message":"one"`)) {
+		t.Errorf("previous writer not flushed: %s", first.String())
+	}
+
+	after := slog.New(h.WithGroup("g"))
+	before.Info("two")
+	after.Info("three")
+	slog.New(h).Info("four")
+	for _, msg := range []string{"two", "three", "four"} {
+		if !bytes.Contains(second.Bytes(), []byte(`"message":"`+msg+`"`)) {
+			t.Errorf("%s not written to new writer: %s", msg, second.String())
+		}
+	}
+	if bytes.Contains(first.Bytes(), []byte(`"message":"two"`)) {
+		t.Errorf("previous writer used after SetWriter: %s", first.String())
+	}
+}
+
+func TestHandler_SetWriter_concurrent(t *testing.T) {
+	var first, second lockedBuffer
+	h := NewErrorReportingHandler(&first, nil)
+	logger := slog.New(h)
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for range 100 {
+				logger.Info("msg")
+			}
+		})
+	}
+	h.(WriterSetter).SetWriter(&second)
+	wg.Wait()
+	lines := bytes.Count(first.buf.Bytes(), []byte("\n")) + bytes.Count(second.buf.Bytes(), []byte("\n"))
+	if lines != 400 {
+		t.Errorf("lines = %d, want 400", lines)
+	}
+}
+
+// lockedBuffer is a [bytes.Buffer] safe for concurrent writes.
+type lockedBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.Write(p)
+}
+
+// TestHandler_concurrentDerive derives handlers from shared parents on many goroutines,
+// while logging through them, and checks that no derived handler sees the state of another.
+// Run with -race to detect unsynchronized access to shared state.
+func TestHandler_concurrentDerive(t *testing.T) {
+	const (
+		goroutines = 16
+		records    = 50
+	)
+	var buf lockedBuffer
+	root := NewErrorReportingHandler(&buf, nil, WithLabels(map[string]string{"app": "test"}))
+	parent := root.WithAttrs([]slog.Attr{slog.String("shared", "parent")}).WithGroup("g")
+
+	var wg sync.WaitGroup
+	for i := range goroutines {
+		wg.Go(func() {
+			id := strconv.Itoa(i)
+			h := parent.WithAttrs([]slog.Attr{slog.String("id", id), Labels(map[string]string{"id": id})})
+			for n := range records {
+				// Derive from the shared parent and from the own handler on every iteration.
+				derived := h.WithGroup("sub").WithAttrs([]slog.Attr{slog.Int("n", n)})
+				slog.New(parent).Info("parent", "id", id)
+				slog.New(derived).Info(id, "n2", n, Labels(map[string]string{"n": strconv.Itoa(n)}))
+			}
+		})
+	}
+	wg.Wait()
+
+	dec := json.NewDecoder(&buf.buf)
+	var count int
+	for dec.More() {
+		var got struct {
+			Message string            `json:"message"`
+			Shared  string            `json:"shared"`
+			Labels  map[string]string `json:"logging.googleapis.com/labels"`
+			G       struct {
+				ID  string `json:"id"`
+				Sub *struct {
+					N  int `json:"n"`
+					N2 int `json:"n2"`
+				} `json:"sub"`
+			} `json:"g"`
+		}
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("Failed to decode log output: %v", err)
+		}
+		count++
+		if got.Shared != "parent" || got.Labels["app"] != "test" {
+			t.Fatalf("shared state missing: %+v", got)
+		}
+		if got.Message == "parent" {
+			if got.G.Sub != nil || len(got.Labels) != 1 {
+				t.Fatalf("parent record has derived state: %+v", got)
+			}
+			continue
+		}
+		if got.G.ID != got.Message || got.Labels["id"] != got.Message || got.G.Sub == nil ||
+			got.G.Sub.N != got.G.Sub.N2 || got.Labels["n"] != strconv.Itoa(got.G.Sub.N) || len(got.Labels) != 3 {
+			t.Fatalf("derived record mixed with other handlers: %+v", got)
+		}
+	}
+	if want := goroutines * records * 2; count != want {
+		t.Errorf("records = %d, want %d", count, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	replaceAttr := func(_ []string, a slog.Attr) slog.Attr {
+		if a.Key == "secret" {
+			return slog.String(a.Key, "***")
+		}
+		return a
+	}
+	tests := []struct {
+		name       string
+		options    []Option
+		level      slog.Level
+		wantOutput bool
+		wantSource bool
+		want       map[string]any
+	}{
+		{
+			name:       "default level",
+			level:      slog.LevelDebug,
+			wantOutput: false,
+		},
+		{
+			name:       "default",
+			level:      slog.LevelInfo,
+			wantOutput: true,
+			want:       map[string]any{"secret": "s"},
+		},
+		{
+			name:       "level",
+			options:    []Option{WithLevel(slog.LevelDebug)},
+			level:      slog.LevelDebug,
+			wantOutput: true,
+			want:       map[string]any{"secret": "s"},
+		},
+		{
+			name:       "add source",
+			options:    []Option{WithAddSource(true)},
+			level:      slog.LevelInfo,
+			wantOutput: true,
+			wantSource: true,
+			want:       map[string]any{"secret": "s"},
+		},
+		{
+			name:       "replace attr",
+			options:    []Option{WithReplaceAttr(replaceAttr)},
+			level:      slog.LevelInfo,
+			wantOutput: true,
+			want:       map[string]any{"secret": "***"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			slog.New(New(&buf, tt.options...)).Log(t.Context(), tt.level, "", "secret", "s")
+			if (buf.Len() > 0) != tt.wantOutput {
+				t.Fatalf("log output = %q, want output %v", buf.String(), tt.wantOutput)
+			}
+			if !tt.wantOutput {
+				return
+			}
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			if _, ok := got[SourceLocationKey]; ok != tt.wantSource {
+				t.Errorf("sourceLocation present = %v, want %v", ok, tt.wantSource)
+			}
+			if got["secret"] != tt.want["secret"] {
+				t.Errorf("secret = %v, want %v", got["secret"], tt.want["secret"])
+			}
+		})
+	}
+}
+
+func TestNewUnsynchronizedHandler(t *testing.T) {
+	omitTime := func(_ []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	}
+	options := []Option{WithAddSource(true), WithLabels(map[string]string{"env": "prod"}), WithReplaceAttr(omitTime)}
+	log := func(h slog.Handler) {
+		logger := slog.New(h).With("a", 1)
+		logger.WithGroup("g").Info("msg", "b", 2)
+		logger.Error("msg", ErrorKey, errors.New("oops"))
+	}
+	var want, got bytes.Buffer
+	log(New(&want, options...))
+	h := NewUnsynchronizedHandler(&got, options...)
+	log(h)
+	if got.String() != want.String() {
+		t.Errorf("log output = %s, want %s", got.String(), want.String())
+	}
+
+	var buf bytes.Buffer
+	previous := h.(WriterSetter).SetWriter(&buf)
+	if previous != &got {
+		t.Errorf("SetWriter() = %v, want previous writer", previous)
+	}
+	slog.New(h).Info("msg")
+	if err := h.(Flusher).Flush(); err != nil {
+		t.Errorf("Flush() = %v", err)
+	}
+	if buf.Len() == 0 {
+		t.Error("log output is empty after SetWriter")
+	}
+}
+
+func TestNewErrorReportingHandler_optionsOverride(t *testing.T) {
+	var buf bytes.Buffer
+	opts := &slog.HandlerOptions{Level: slog.LevelError}
+	slog.New(NewErrorReportingHandler(&buf, opts, WithLevel(slog.LevelDebug))).Debug("msg")
+	if buf.Len() == 0 {
+		t.Error("WithLevel did not override slog.HandlerOptions.Level")
+	}
+	if opts.Level != slog.LevelError {
+		t.Errorf("opts modified: %v", opts.Level)
+	}
+}
@@ -0,0 +1,15 @@
+module github.com/zitadel/sloggcp/sloggcpgrpc
+
+go 1.25.0
+
+require (
+	github.com/zitadel/sloggcp v0.2.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260630182238-925bb5da69e7
+	google.golang.org/grpc v1.82.0
+	google.golang.org/protobuf v1.36.11
+)
+
+require (
+	golang.org/x/net v0.56.0 // indirect
+	golang.org/x/sys v0.46.0 // indirect
+)
@@ -0,0 +1,126 @@
+// Package sloggcpgrpc reports gRPC status errors with the sloggcp handler.
+package sloggcpgrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/zitadel/sloggcp"
+)
+
+// Keys of the attributes of a logged [StatusError].
+const (
+	MessageKey = "message"
+	CodeKey    = "code"
+	DetailsKey = "details"
+)
+
+var (
+	_ sloggcp.StackTraceError     = (*StatusError)(nil)
+	_ sloggcp.ReportLocationError = (*StatusError)(nil)
+	_ sloggcp.SeverityError       = (*StatusError)(nil)
+	_ slog.LogValuer              = (*StatusError)(nil)
+)
+
+// StatusError is a gRPC status error, prepared for logging under [sloggcp.ErrorKey].
+// The error report message is the error string, so errors are grouped by it in Error Reporting.
+// The error attribute of the log entry is a group with the message, the code name, such as "NotFound",
+// and the status details, so they can be queried in the Logs Explorer, for example with jsonPayload.error.code="NotFound".
+//
+// The stack trace and report location of the wrapped error are reported, if it provides them,
+// for example when the status error wraps a [sloggcp.Error].
+type StatusError struct {
+	err    error
+	status *status.Status
+}
+
+// Wrap returns a [StatusError] for a gRPC status error, as recognized by [status.FromError].
+// Other errors, including nil, are returned unchanged, so they are logged as usual.
+//
+//	logger.Error("call failed", sloggcp.ErrorKey, sloggcpgrpc.Wrap(err))
+func Wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	return &StatusError{err: err, status: s}
+}
+
+// Error implements [error].
+func (e *StatusError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *StatusError) Unwrap() error {
+	return e.err
+}
+
+// GRPCStatus returns the status of the error, so it is recognized by [status.FromError].
+func (e *StatusError) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// StackTrace implements [sloggcp.StackTraceError].
+// It returns the stack trace of the first [sloggcp.StackTraceError] in the wrapped error tree.
+func (e *StatusError) StackTrace() ([]byte, bool) {
+	var target sloggcp.StackTraceError
+	if errors.As(e.err, &target) {
+		return target.StackTrace()
+	}
+	return nil, false
+}
+
+// ReportLocation implements [sloggcp.ReportLocationError].
+// It returns the location of the first [sloggcp.ReportLocationError] in the wrapped error tree, or nil.
+func (e *StatusError) ReportLocation() *sloggcp.ReportLocation {
+	var target sloggcp.ReportLocationError
+	if errors.As(e.err, &target) {
+		return target.ReportLocation()
+	}
+	return nil
+}
+
+// Severity implements [sloggcp.SeverityError].
+// Codes of client errors, which map to 4xx HTTP status codes, such as NotFound and InvalidArgument,
+// are reported at [sloggcp.LevelWarning], all other codes at [sloggcp.LevelError].
+func (e *StatusError) Severity() slog.Level {
+	switch e.status.Code() {
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.ResourceExhausted,
+		codes.FailedPrecondition, codes.Aborted, codes.OutOfRange:
+		return sloggcp.LevelWarning
+	default:
+		return sloggcp.LevelError
+	}
+}
+
+// LogValue implements [slog.LogValuer].
+// The details are encoded as protobuf JSON.
+// Details of message types which are not linked into the program only contain their "@type".
+func (e *StatusError) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String(MessageKey, e.err.Error()),
+		slog.String(CodeKey, e.status.Code().String()),
+	}
+	if details := e.status.Proto().GetDetails(); len(details) > 0 {
+		values := make([]json.RawMessage, len(details))
+		for i, detail := range details {
+			raw, err := protojson.Marshal(detail)
+			if err != nil {
+				raw, _ = json.Marshal(map[string]string{"@type": detail.GetTypeUrl()})
+			}
+			values[i] = raw
+		}
+		attrs = append(attrs, slog.Any(DetailsKey, values))
+	}
+	return slog.GroupValue(attrs...)
+}
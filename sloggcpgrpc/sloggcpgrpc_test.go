@@ -0,0 +1,147 @@
+package sloggcpgrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/zitadel/sloggcp"
+)
+
+func TestWrap(t *testing.T) {
+	plain := errors.New("plain")
+	tests := []struct {
+		name     string
+		err      error
+		want     error
+		wantCode codes.Code
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "not a status error",
+			err:  plain,
+			want: plain,
+		},
+		{
+			name:     "status error",
+			err:      status.Error(codes.NotFound, "user not found"),
+			wantCode: codes.NotFound,
+		},
+		{
+			name:     "wrapped status error",
+			err:      fmt.Errorf("get user: %w", status.Error(codes.PermissionDenied, "denied")),
+			wantCode: codes.PermissionDenied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Wrap(tt.err)
+			if tt.wantCode == codes.OK {
+				if got != tt.want {
+					t.Errorf("Wrap() = %v, want %v", got, tt.want)
+				}
+				return
+			}
+			var statusErr *StatusError
+			if !errors.As(got, &statusErr) {
+				t.Fatalf("Wrap() = %T, want *StatusError", got)
+			}
+			if got.Error() != tt.err.Error() {
+				t.Errorf("Error() = %q, want %q", got.Error(), tt.err.Error())
+			}
+			if code := status.Code(got); code != tt.wantCode {
+				t.Errorf("status.Code() = %v, want %v", code, tt.wantCode)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Errorf("errors.Is(Wrap(err), err) = false, want true")
+			}
+		})
+	}
+}
+
+func TestStatusError_errorReport(t *testing.T) {
+	s, err := status.New(codes.NotFound, "user not found").WithDetails(&errdetails.ErrorInfo{
+		Reason: "USER_NOT_FOUND",
+		Domain: "example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		err          error
+		wantMessage  string
+		wantError    map[string]any
+		wantLocation bool
+		wantSeverity string
+	}{
+		{
+			name:        "status error",
+			err:         status.Error(codes.Unavailable, "try again"),
+			wantMessage: "rpc error: code = Unavailable desc = try again",
+			wantError: map[string]any{
+				MessageKey: "rpc error: code = Unavailable desc = try again",
+				CodeKey:    "Unavailable",
+			},
+			wantSeverity: sloggcp.ErrorSeverity,
+		},
+		{
+			name:        "details",
+			err:         s.Err(),
+			wantMessage: "rpc error: code = NotFound desc = user not found",
+			wantError: map[string]any{
+				MessageKey: "rpc error: code = NotFound desc = user not found",
+				CodeKey:    "NotFound",
+				DetailsKey: []any{map[string]any{
+					"@type":  "type.googleapis.com/google.rpc.ErrorInfo",
+					"reason": "USER_NOT_FOUND",
+					"domain": "example.com",
+				}},
+			},
+			wantSeverity: sloggcp.WarningSeverity,
+		},
+		{
+			name:         "stack trace and report location",
+			err:          sloggcp.Wrap(status.Error(codes.Internal, "boom"), "handler"),
+			wantMessage:  "handler: rpc error: code = Internal desc = boom\ngoroutine ",
+			wantLocation: true,
+			wantSeverity: sloggcp.ErrorSeverity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(sloggcp.NewErrorReportingHandler(&buf, nil))
+			logger.Error("msg", sloggcp.ErrorKey, Wrap(tt.err))
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			message, _ := got[sloggcp.MessageKey].(string)
+			if !strings.HasPrefix(message, tt.wantMessage) {
+				t.Errorf("%s = %q, want prefix %q", sloggcp.MessageKey, message, tt.wantMessage)
+			}
+			if _, ok := got[sloggcp.ReportLocationKey]; ok != tt.wantLocation {
+				t.Errorf("%s present = %v, want %v", sloggcp.ReportLocationKey, ok, tt.wantLocation)
+			}
+			if got[sloggcp.SeverityKey] != tt.wantSeverity {
+				t.Errorf("%s = %v, want %v", sloggcp.SeverityKey, got[sloggcp.SeverityKey], tt.wantSeverity)
+			}
+			if tt.wantError != nil && !reflect.DeepEqual(got[sloggcp.ErrorKey], tt.wantError) {
+				t.Errorf("%s = %v, want %v", sloggcp.ErrorKey, got[sloggcp.ErrorKey], tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+// Package sloggcplogging writes slog records directly to the Cloud Logging API,
+// for workloads where log output is not collected from stdout, such as outside of GCP.
+package sloggcplogging
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+
+	"github.com/zitadel/sloggcp"
+)
+
+// entryLogger is implemented by [logging.Logger].
+type entryLogger interface {
+	Log(e logging.Entry)
+}
+
+// NewCloudLoggingHandler returns a handler which writes records
+// as [logging.Entry] to the log logName, using the given client.
+// Records are encoded the same way as by [sloggcp.NewErrorReportingHandler],
+// including error reports. The GCP special fields, such as severity, labels,
+// trace, source location, HTTP request and operation, are set on the entry,
+// and all other fields form the JSON payload.
+//
+// Entries are buffered and sent by the [logging.Logger] of the client,
+// see [logging.Logger.Log]. Call [logging.Client.Close] or [logging.Logger.Flush]
+// before the program exits, to make sure all entries are sent.
+// Errors of the client are reported through [logging.Client.OnError].
+//
+// Cloud Logging expects trace IDs in the form "projects/PROJECT_ID/traces/TRACE_ID",
+// so trace correlation requires [sloggcp.WithProjectID] to be set in options.
+func NewCloudLoggingHandler(client *logging.Client, logName string, opts *slog.HandlerOptions, options ...sloggcp.Option) slog.Handler {
+	return newHandler(client.Logger(logName), opts, options...)
+}
+
+func newHandler(logger entryLogger, opts *slog.HandlerOptions, options ...sloggcp.Option) slog.Handler {
+	return sloggcp.NewErrorReportingHandler(&entryWriter{logger: logger}, opts, options...)
+}
+
+// entryWriter converts the JSON lines written by the sloggcp handler to entries.
+// The handler writes exactly one record per call to Write.
+type entryWriter struct {
+	logger entryLogger
+}
+
+func (w *entryWriter) Write(p []byte) (int, error) {
+	entry, err := toEntry(p)
+	if err != nil {
+		return 0, fmt.Errorf("sloggcplogging: %w", err)
+	}
+	w.logger.Log(entry)
+	return len(p), nil
+}
+
+// toEntry converts a JSON log line to a [logging.Entry].
+// Special fields which cannot be decoded are kept in the payload.
+func toEntry(line []byte) (entry logging.Entry, err error) {
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(line, &payload); err != nil {
+		return entry, err
+	}
+	decoders := map[string]func(raw json.RawMessage) error{
+		sloggcp.TimeKey: func(raw json.RawMessage) error {
+			return json.Unmarshal(raw, &entry.Timestamp)
+		},
+		sloggcp.SeverityKey: func(raw json.RawMessage) error {
+			var severity string
+			if err := json.Unmarshal(raw, &severity); err != nil {
+				return err
+			}
+			entry.Severity = logging.ParseSeverity(severity)
+			return nil
+		},
+		sloggcp.LabelsKey: func(raw json.RawMessage) error {
+			return json.Unmarshal(raw, &entry.Labels)
+		},
+		sloggcp.InsertIDKey: func(raw json.RawMessage) error {
+			return json.Unmarshal(raw, &entry.InsertID)
+		},
+		sloggcp.TraceKey: func(raw json.RawMessage) error {
+			return json.Unmarshal(raw, &entry.Trace)
+		},
+		sloggcp.SpanIDKey: func(raw json.RawMessage) error {
+			return json.Unmarshal(raw, &entry.SpanID)
+		},
+		sloggcp.TraceSampledKey: func(raw json.RawMessage) error {
+			return json.Unmarshal(raw, &entry.TraceSampled)
+		},
+		sloggcp.OperationKey: func(raw json.RawMessage) error {
+			var op sloggcp.Operation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return err
+			}
+			entry.Operation = &loggingpb.LogEntryOperation{
+				Id:       op.ID,
+				Producer: op.Producer,
+				First:    op.First,
+				Last:     op.Last,
+			}
+			return nil
+		},
+		sloggcp.SourceLocationKey: func(raw json.RawMessage) (err error) {
+			entry.SourceLocation, err = toSourceLocation(raw)
+			return err
+		},
+		sloggcp.HTTPRequestKey: func(raw json.RawMessage) (err error) {
+			entry.HTTPRequest, err = toHTTPRequest(raw)
+			return err
+		},
+	}
+	for key, decode := range decoders {
+		if raw, ok := payload[key]; ok && decode(raw) == nil {
+			delete(payload, key)
+		}
+	}
+	entry.Payload = payload
+	return entry, nil
+}
+
+func toSourceLocation(raw json.RawMessage) (*loggingpb.LogEntrySourceLocation, error) {
+	var source struct {
+		Function string      `json:"function"`
+		File     string      `json:"file"`
+		Line     json.Number `json:"line"`
+	}
+	if err := json.Unmarshal(raw, &source); err != nil {
+		return nil, err
+	}
+	loc := &loggingpb.LogEntrySourceLocation{
+		File:     source.File,
+		Function: source.Function,
+	}
+	if source.Line != "" {
+		line, err := source.Line.Int64()
+		if err != nil {
+			return nil, err
+		}
+		loc.Line = line
+	}
+	return loc, nil
+}
+
+// httpRequest is the JSON representation of [sloggcp.HTTPRequest].
+type httpRequest struct {
+	RequestMethod                  string `json:"requestMethod"`
+	RequestURL                     string `json:"requestUrl"`
+	RequestSize                    string `json:"requestSize"`
+	Status                         int    `json:"status"`
+	ResponseSize                   string `json:"responseSize"`
+	UserAgent                      string `json:"userAgent"`
+	RemoteIP                       string `json:"remoteIp"`
+	ServerIP                       string `json:"serverIp"`
+	Referer                        string `json:"referer"`
+	Latency                        string `json:"latency"`
+	CacheLookup                    bool   `json:"cacheLookup"`
+	CacheHit                       bool   `json:"cacheHit"`
+	CacheValidatedWithOriginServer bool   `json:"cacheValidatedWithOriginServer"`
+	CacheFillBytes                 string `json:"cacheFillBytes"`
+	Protocol                       string `json:"protocol"`
+}
+
+// toHTTPRequest converts the JSON representation of [sloggcp.HTTPRequest]
+// to a [logging.HTTPRequest]. The client reads method, URL, user agent,
+// referer and protocol from a [http.Request], which is reconstructed for this purpose.
+func toHTTPRequest(raw json.RawMessage) (_ *logging.HTTPRequest, err error) {
+	var r httpRequest
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(r.RequestURL)
+	if err != nil {
+		return nil, err
+	}
+	req := &logging.HTTPRequest{
+		Request: &http.Request{
+			Method: r.RequestMethod,
+			URL:    u,
+			Proto:  r.Protocol,
+			Header: make(http.Header),
+		},
+		Status:                         r.Status,
+		RemoteIP:                       r.RemoteIP,
+		LocalIP:                        r.ServerIP,
+		CacheLookup:                    r.CacheLookup,
+		CacheHit:                       r.CacheHit,
+		CacheValidatedWithOriginServer: r.CacheValidatedWithOriginServer,
+	}
+	if r.UserAgent != "" {
+		req.Request.Header.Set("User-Agent", r.UserAgent)
+	}
+	if r.Referer != "" {
+		req.Request.Header.Set("Referer", r.Referer)
+	}
+	if req.RequestSize, err = parseInt(r.RequestSize); err != nil {
+		return nil, err
+	}
+	if req.ResponseSize, err = parseInt(r.ResponseSize); err != nil {
+		return nil, err
+	}
+	if req.CacheFillBytes, err = parseInt(r.CacheFillBytes); err != nil {
+		return nil, err
+	}
+	if r.Latency != "" {
+		// The protobuf Duration format is a valid Go duration.
+		if req.Latency, err = time.ParseDuration(r.Latency); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// parseInt parses int64 values, which are encoded as strings in JSON.
+func parseInt(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+}
@@ -0,0 +1,182 @@
+package sloggcplogging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+
+	"github.com/zitadel/sloggcp"
+)
+
+type entries []logging.Entry
+
+func (e *entries) Log(entry logging.Entry) {
+	*e = append(*e, entry)
+}
+
+func TestNewCloudLoggingHandler(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	tests := []struct {
+		name        string
+		options     []sloggcp.Option
+		level       slog.Level
+		attrs       []slog.Attr
+		wantEntry   logging.Entry
+		wantPayload map[string]any
+	}{
+		{
+			name:  "message",
+			level: slog.LevelInfo,
+			attrs: []slog.Attr{slog.String("a", "b"), slog.Group("g", slog.Int("c", 1))},
+			wantEntry: logging.Entry{
+				Timestamp: now,
+				Severity:  logging.Info,
+			},
+			wantPayload: map[string]any{
+				sloggcp.MessageKey: "msg",
+				"a":                "b",
+				"g":                map[string]any{"c": float64(1)},
+			},
+		},
+		{
+			name:    "special fields",
+			options: []sloggcp.Option{sloggcp.WithLabels(map[string]string{"k": "v"})},
+			level:   sloggcp.LevelCritical,
+			attrs: []slog.Attr{
+				slog.String(sloggcp.TraceKey, "projects/p/traces/t"),
+				slog.String(sloggcp.SpanIDKey, "s"),
+				slog.Bool(sloggcp.TraceSampledKey, true),
+				slog.String(sloggcp.InsertIDKey, "id"),
+				slog.Any("op", sloggcp.Operation{ID: "op", Producer: "prod", First: true}),
+				slog.Any(sloggcp.SourceLocationKey, &slog.Source{Function: "f", File: "file.go", Line: 42}),
+			},
+			wantEntry: logging.Entry{
+				Timestamp:    now,
+				Severity:     logging.Critical,
+				Labels:       map[string]string{"k": "v"},
+				InsertID:     "id",
+				Trace:        "projects/p/traces/t",
+				SpanID:       "s",
+				TraceSampled: true,
+				Operation: &loggingpb.LogEntryOperation{
+					Id:       "op",
+					Producer: "prod",
+					First:    true,
+				},
+				SourceLocation: &loggingpb.LogEntrySourceLocation{
+					File:     "file.go",
+					Line:     42,
+					Function: "f",
+				},
+			},
+			wantPayload: map[string]any{
+				sloggcp.MessageKey: "msg",
+			},
+		},
+		{
+			name:  "error report",
+			level: slog.LevelError,
+			attrs: []slog.Attr{slog.String(sloggcp.ErrorKey, "oops")},
+			wantEntry: logging.Entry{
+				Timestamp: now,
+				Severity:  logging.Error,
+			},
+			wantPayload: map[string]any{
+				sloggcp.ErrorReportTypeKey: sloggcp.ErrorReportTypeValue,
+				sloggcp.MessageKey:         "oops",
+				sloggcp.ErrorKey:           "oops",
+			},
+		},
+		{
+			name:  "invalid special field",
+			level: slog.LevelInfo,
+			attrs: []slog.Attr{slog.Int(sloggcp.TraceKey, 1)},
+			wantEntry: logging.Entry{
+				Timestamp: now,
+				Severity:  logging.Info,
+			},
+			wantPayload: map[string]any{
+				sloggcp.MessageKey: "msg",
+				sloggcp.TraceKey:   float64(1),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got entries
+			handler := newHandler(&got, nil, tt.options...)
+			r := slog.NewRecord(now, tt.level, "msg", 0)
+			r.AddAttrs(tt.attrs...)
+			if err := handler.Handle(t.Context(), r); err != nil {
+				t.Fatalf("Handle() error = %v", err)
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d entries, want 1", len(got))
+			}
+			entry := got[0]
+			payload, err := json.Marshal(entry.Payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotPayload map[string]any
+			if err := json.Unmarshal(payload, &gotPayload); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotPayload, tt.wantPayload) {
+				t.Errorf("payload = %v, want %v", gotPayload, tt.wantPayload)
+			}
+			entry.Payload = nil
+			if !reflect.DeepEqual(entry, tt.wantEntry) {
+				t.Errorf("entry = %+v, want %+v", entry, tt.wantEntry)
+			}
+		})
+	}
+}
+
+func Test_toHTTPRequest(t *testing.T) {
+	r, err := http.NewRequest(http.MethodPost, "https://example.com/path?q=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("User-Agent", "test")
+	r.Header.Set("Referer", "https://example.com/")
+	r.Header.Set("X-Forwarded-For", "10.0.0.1")
+	r.ContentLength = 10
+	in := sloggcp.NewHTTPRequest(r, http.StatusCreated, 20, 1500*time.Millisecond)
+	in.CacheFillBytes = 30
+
+	var got entries
+	slog.New(newHandler(&got, nil)).Info("request", sloggcp.HTTPRequestKey, in)
+	if len(got) != 1 {
+		t.Fatalf("got %d entries, want 1", len(got))
+	}
+	req := got[0].HTTPRequest
+	if req == nil {
+		t.Fatal("HTTPRequest is nil")
+	}
+	if req.Request.Method != http.MethodPost ||
+		req.Request.URL.String() != "https://example.com/path?q=1" ||
+		req.Request.UserAgent() != "test" ||
+		req.Request.Referer() != "https://example.com/" ||
+		req.Request.Proto != "HTTP/1.1" {
+		t.Errorf("Request = %+v", req.Request)
+	}
+	req.Request = nil
+	want := &logging.HTTPRequest{
+		RequestSize:    10,
+		Status:         http.StatusCreated,
+		ResponseSize:   20,
+		Latency:        1500 * time.Millisecond,
+		RemoteIP:       "10.0.0.1",
+		CacheFillBytes: 30,
+	}
+	if !reflect.DeepEqual(req, want) {
+		t.Errorf("HTTPRequest = %+v, want %+v", req, want)
+	}
+}
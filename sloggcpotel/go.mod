@@ -0,0 +1,13 @@
+module github.com/zitadel/sloggcp/sloggcpotel
+
+go 1.25.0
+
+require (
+	github.com/zitadel/sloggcp v0.2.0
+	go.opentelemetry.io/otel/trace v1.46.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	go.opentelemetry.io/otel v1.46.0 // indirect
+)
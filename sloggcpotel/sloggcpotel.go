@@ -0,0 +1,31 @@
+// Package sloggcpotel integrates OpenTelemetry tracing with the sloggcp handler.
+package sloggcpotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/zitadel/sloggcp"
+)
+
+var _ sloggcp.TraceExtractor = TraceExtractor
+
+// TraceExtractor implements [sloggcp.TraceExtractor] for OpenTelemetry.
+// It reads the [trace.SpanContext] from the context and returns
+// the trace ID and span ID hex encoded, as expected by Cloud Logging.
+// The sampling decision is always known from the trace flags.
+// Empty strings are returned when the context has no valid span context,
+// so the handler omits the trace attributes.
+//
+// Use it with [sloggcp.WithTraceExtractor]:
+//
+//	sloggcp.NewErrorReportingHandler(os.Stdout, nil, sloggcp.WithTraceExtractor(sloggcpotel.TraceExtractor))
+func TraceExtractor(ctx context.Context) (traceID, spanID string, sampled *bool) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return "", "", nil
+	}
+	isSampled := sc.IsSampled()
+	return sc.TraceID().String(), sc.SpanID().String(), &isSampled
+}
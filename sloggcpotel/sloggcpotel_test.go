@@ -0,0 +1,71 @@
+package sloggcpotel
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceExtractor(t *testing.T) {
+	traceID := trace.TraceID{0x10, 0x54, 0x45, 0xaa, 0x78, 0x43, 0xbc, 0x8b, 0xf2, 0x06, 0xb1, 0x20, 0x00, 0x10, 0x00, 0x00}
+	spanID := trace.SpanID{0, 0, 0, 0, 0, 0, 0, 0x4a}
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		wantTraceID string
+		wantSpanID  string
+		wantSampled *bool
+	}{
+		{
+			name: "no span context",
+			ctx:  context.Background(),
+		},
+		{
+			name: "sampled",
+			ctx: trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    traceID,
+				SpanID:     spanID,
+				TraceFlags: trace.FlagsSampled,
+			})),
+			wantTraceID: "105445aa7843bc8bf206b12000100000",
+			wantSpanID:  "000000000000004a",
+			wantSampled: ptr(true),
+		},
+		{
+			name: "not sampled",
+			ctx: trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID: traceID,
+				SpanID:  spanID,
+			})),
+			wantTraceID: "105445aa7843bc8bf206b12000100000",
+			wantSpanID:  "000000000000004a",
+			wantSampled: ptr(false),
+		},
+		{
+			name: "invalid span context",
+			ctx: trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID: traceID,
+			})),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTraceID, gotSpanID, gotSampled := TraceExtractor(tt.ctx)
+			if gotTraceID != tt.wantTraceID {
+				t.Errorf("TraceExtractor() traceID = %v, want %v", gotTraceID, tt.wantTraceID)
+			}
+			if gotSpanID != tt.wantSpanID {
+				t.Errorf("TraceExtractor() spanID = %v, want %v", gotSpanID, tt.wantSpanID)
+			}
+			if (gotSampled == nil) != (tt.wantSampled == nil) || (gotSampled != nil && *gotSampled != *tt.wantSampled) {
+				t.Errorf("TraceExtractor() sampled = %v, want %v", gotSampled, tt.wantSampled)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
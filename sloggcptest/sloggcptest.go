@@ -0,0 +1,95 @@
+// Package sloggcptest provides a handler which records log entries, for testing
+// code which logs through the sloggcp handler.
+package sloggcptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/zitadel/sloggcp"
+)
+
+// Entry is a recorded log entry.
+type Entry struct {
+	Time     time.Time
+	Severity string
+	Message  string
+	// Fields holds all fields of the entry, including time, severity and message,
+	// as decoded from the JSON output by [json.Unmarshal] into a map[string]any.
+	Fields map[string]any
+}
+
+// Handler records the entries written by a [sloggcp.NewErrorReportingHandler].
+// Records are encoded exactly like in production, and decoded again,
+// so assertions match the actual output.
+// Handlers derived with WithAttrs and WithGroup record to the same Handler.
+// Handler is safe for concurrent use.
+type Handler struct {
+	slog.Handler
+	rec *recorder
+}
+
+// NewHandler returns a [Handler], with the same arguments as [sloggcp.NewErrorReportingHandler].
+// When opts is nil, all levels are recorded.
+func NewHandler(opts *slog.HandlerOptions, options ...sloggcp.Option) *Handler {
+	if opts == nil {
+		opts = &slog.HandlerOptions{Level: sloggcp.LevelDefault}
+	}
+	rec := new(recorder)
+	return &Handler{
+		Handler: sloggcp.NewErrorReportingHandler(rec, opts, options...),
+		rec:     rec,
+	}
+}
+
+// Entries returns a copy of the recorded entries, in the order they were written.
+func (h *Handler) Entries() []Entry {
+	h.rec.mtx.Lock()
+	defer h.rec.mtx.Unlock()
+	return append([]Entry(nil), h.rec.entries...)
+}
+
+// LastEntry returns the most recently recorded entry.
+// ok is false if no entry was recorded.
+func (h *Handler) LastEntry() (entry Entry, ok bool) {
+	h.rec.mtx.Lock()
+	defer h.rec.mtx.Unlock()
+	if len(h.rec.entries) == 0 {
+		return Entry{}, false
+	}
+	return h.rec.entries[len(h.rec.entries)-1], true
+}
+
+// Reset removes all recorded entries.
+func (h *Handler) Reset() {
+	h.rec.mtx.Lock()
+	defer h.rec.mtx.Unlock()
+	h.rec.entries = nil
+}
+
+// recorder decodes the JSON lines written by the handler.
+type recorder struct {
+	mtx     sync.Mutex
+	entries []Entry
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	var fields map[string]any
+	if err := json.Unmarshal(p, &fields); err != nil {
+		return 0, fmt.Errorf("sloggcptest: %w", err)
+	}
+	entry := Entry{Fields: fields}
+	entry.Severity, _ = fields[sloggcp.SeverityKey].(string)
+	entry.Message, _ = fields[sloggcp.MessageKey].(string)
+	if t, ok := fields[sloggcp.TimeKey].(string); ok {
+		entry.Time, _ = time.Parse(time.RFC3339Nano, t)
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.entries = append(r.entries, entry)
+	return len(p), nil
+}
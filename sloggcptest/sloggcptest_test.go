@@ -0,0 +1,57 @@
+package sloggcptest
+
+import (
+	"errors"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zitadel/sloggcp"
+)
+
+func TestHandler(t *testing.T) {
+	h := NewHandler(nil, sloggcp.WithLabels(map[string]string{"env": "test"}))
+	if _, ok := h.LastEntry(); ok {
+		t.Fatal("LastEntry() ok = true for empty handler")
+	}
+
+	logger := slog.New(h)
+	logger.Debug("debug", "a", 1)
+	logger.WithGroup("g").Error("failed", sloggcp.ErrorKey, errors.New("oops"))
+
+	entries := h.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("Entries() returned %d entries, want 2", len(entries))
+	}
+	first := entries[0]
+	if first.Severity != sloggcp.DebugSeverity || first.Message != "debug" {
+		t.Errorf("first entry = %q, %q", first.Severity, first.Message)
+	}
+	if time.Since(first.Time) > time.Minute {
+		t.Errorf("first entry time = %v", first.Time)
+	}
+	delete(first.Fields, sloggcp.TimeKey)
+	wantFields := map[string]any{
+		sloggcp.SeverityKey: sloggcp.DebugSeverity,
+		sloggcp.MessageKey:  "debug",
+		sloggcp.LabelsKey:   map[string]any{"env": "test"},
+		"a":                 float64(1),
+	}
+	if !reflect.DeepEqual(first.Fields, wantFields) {
+		t.Errorf("first entry fields = %v, want %v", first.Fields, wantFields)
+	}
+
+	last, ok := h.LastEntry()
+	if !ok {
+		t.Fatal("LastEntry() ok = false")
+	}
+	if last.Severity != sloggcp.ErrorSeverity || last.Message != "failed" {
+		t.Errorf("last entry = %q, %q", last.Severity, last.Message)
+	}
+
+	h.Reset()
+	if got := h.Entries(); len(got) != 0 {
+		t.Errorf("Entries() after Reset = %v", got)
+	}
+}
@@ -0,0 +1,301 @@
+package sloggcptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zitadel/sloggcp"
+)
+
+// ValidateEntry checks an encoded log entry, a single JSON object as written by the handler,
+// against the constraints GCP puts on the special fields of structured logs and of error reports,
+// so a test catches fields which Cloud Logging or Error Reporting would not pick up as intended.
+// It returns an error describing all violations, or nil.
+//
+// The checks encode the documented constraints of the special fields, not a full JSON schema:
+//   - "severity" is one of the LogSeverity names, such as "WARNING".
+//   - "time" is an RFC 3339 timestamp, or "timestampSeconds" and "timestampNanos" are integers.
+//   - "message" is a string.
+//   - "httpRequest" has the fields of the HttpRequest type, with sizes as decimal strings
+//     and the latency as duration string, such as "1.5s".
+//   - "logging.googleapis.com/sourceLocation" has the string fields "file", "line" and "function",
+//     where "line" holds a decimal number.
+//   - "logging.googleapis.com/labels" maps to strings only.
+//   - The trace, span ID and insert ID are strings, "trace_sampled" is a boolean,
+//     and "operation" has the fields of the LogEntryOperation type.
+//   - No other field has the prefix "logging.googleapis.com/", as such fields are usually misspelled.
+//   - An error report, with "@type" of the ReportedErrorEvent, has a non-empty message.
+//     Its "reportLocation" has the fields "filePath", "lineNumber" and "functionName",
+//     and its "serviceContext" has a non-empty "service".
+//
+// See https://cloud.google.com/logging/docs/structured-logging#special-payload-fields
+// and https://cloud.google.com/error-reporting/docs/formatting-error-messages.
+func ValidateEntry(entry []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(entry))
+	dec.UseNumber()
+	var fields map[string]any
+	if err := dec.Decode(&fields); err != nil {
+		return fmt.Errorf("sloggcptest: entry is not a JSON object: %w", err)
+	}
+	if dec.More() {
+		return errors.New("sloggcptest: entry contains more than one JSON value")
+	}
+
+	var v validator
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		value := fields[key]
+		if check, ok := entryFields[key]; ok {
+			v.check(key, value, check)
+		} else if strings.HasPrefix(key, specialFieldPrefix) {
+			v.fail(key, "unknown special field")
+		}
+	}
+	_, seconds := fields[sloggcp.TimestampSecondsKey]
+	_, nanos := fields[sloggcp.TimestampNanosKey]
+	if seconds != nanos {
+		v.fail(sloggcp.TimestampSecondsKey, "%s and %s must be set together", sloggcp.TimestampSecondsKey, sloggcp.TimestampNanosKey)
+	}
+	if fields[sloggcp.ErrorReportTypeKey] == sloggcp.ErrorReportTypeValue {
+		if message, _ := fields[sloggcp.MessageKey].(string); message == "" {
+			v.fail(sloggcp.MessageKey, "error report without message")
+		}
+	}
+	return errors.Join(v.errs...)
+}
+
+// specialFieldPrefix is the prefix of the special fields of the LogEntry type.
+const specialFieldPrefix = "logging.googleapis.com/"
+
+// check validates a decoded JSON value, returning a description of the violation, if any.
+type check func(value any) string
+
+var entryFields = map[string]check{
+	sloggcp.SeverityKey:         oneOf(severities...),
+	sloggcp.TimeKey:             isTimestamp,
+	sloggcp.TimestampSecondsKey: isInteger,
+	sloggcp.TimestampNanosKey:   isInteger,
+	sloggcp.MessageKey:          isString,
+	sloggcp.HTTPRequestKey: isObject(map[string]check{
+		"requestMethod":                  isString,
+		"requestUrl":                     isString,
+		"requestSize":                    isInt64String,
+		"status":                         isInteger,
+		"responseSize":                   isInt64String,
+		"userAgent":                      isString,
+		"remoteIp":                       isString,
+		"serverIp":                       isString,
+		"referer":                        isString,
+		"latency":                        isDuration,
+		"cacheLookup":                    isBool,
+		"cacheHit":                       isBool,
+		"cacheValidatedWithOriginServer": isBool,
+		"cacheFillBytes":                 isInt64String,
+		"protocol":                       isString,
+	}),
+	sloggcp.SourceLocationKey: isObject(map[string]check{
+		"file":     isString,
+		"line":     isInt64String,
+		"function": isString,
+	}),
+	sloggcp.LabelsKey:       isStringMap,
+	sloggcp.TraceKey:        isString,
+	sloggcp.SpanIDKey:       isString,
+	sloggcp.TraceSampledKey: isBool,
+	sloggcp.InsertIDKey:     isString,
+	sloggcp.OperationKey: isObject(map[string]check{
+		"id":       isString,
+		"producer": isString,
+		"first":    isBool,
+		"last":     isBool,
+	}),
+	sloggcp.ErrorReportTypeKey: isString,
+	sloggcp.ReportLocationKey: required(isObject(map[string]check{
+		sloggcp.FilePathKey:     isString,
+		sloggcp.LineNumberKey:   isInteger,
+		sloggcp.FunctionNameKey: isString,
+	}), sloggcp.FilePathKey, sloggcp.LineNumberKey, sloggcp.FunctionNameKey),
+	sloggcp.ServiceContextKey: required(isObject(map[string]check{
+		"service":      isNonEmptyString,
+		"version":      isString,
+		"resourceType": isString,
+	}), "service"),
+}
+
+var severities = []string{
+	sloggcp.DefaultSeverity,
+	sloggcp.DebugSeverity,
+	sloggcp.InfoSeverity,
+	sloggcp.NoticeSeverity,
+	sloggcp.WarningSeverity,
+	sloggcp.ErrorSeverity,
+	sloggcp.CriticalSeverity,
+	sloggcp.AlertSeverity,
+	sloggcp.EmergencySeverity,
+}
+
+// durationPattern matches the JSON encoding of a protobuf Duration.
+var durationPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]{1,9})?s$`)
+
+func isString(value any) string {
+	if _, ok := value.(string); !ok {
+		return fmt.Sprintf("got %s, want string", jsonType(value))
+	}
+	return ""
+}
+
+func isNonEmptyString(value any) string {
+	if s, ok := value.(string); !ok || s == "" {
+		return fmt.Sprintf("got %s, want non-empty string", jsonType(value))
+	}
+	return ""
+}
+
+func isBool(value any) string {
+	if _, ok := value.(bool); !ok {
+		return fmt.Sprintf("got %s, want boolean", jsonType(value))
+	}
+	return ""
+}
+
+func isInteger(value any) string {
+	if n, ok := value.(json.Number); !ok {
+		return fmt.Sprintf("got %s, want integer", jsonType(value))
+	} else if _, err := n.Int64(); err != nil {
+		return fmt.Sprintf("got %s, want integer", n)
+	}
+	return ""
+}
+
+func isInt64String(value any) string {
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Sprintf("got %s, want decimal string", jsonType(value))
+	}
+	if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+		return fmt.Sprintf("got %q, want decimal string", s)
+	}
+	return ""
+}
+
+func isDuration(value any) string {
+	if s, ok := value.(string); !ok || !durationPattern.MatchString(s) {
+		return fmt.Sprintf("got %s, want duration string such as \"1.5s\"", jsonType(value))
+	}
+	return ""
+}
+
+func isTimestamp(value any) string {
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Sprintf("got %s, want RFC 3339 timestamp", jsonType(value))
+	}
+	if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+		return fmt.Sprintf("got %q, want RFC 3339 timestamp", s)
+	}
+	return ""
+}
+
+func isStringMap(value any) string {
+	m, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Sprintf("got %s, want object", jsonType(value))
+	}
+	for _, key := range slices.Sorted(maps.Keys(m)) {
+		if _, ok := m[key].(string); !ok {
+			return fmt.Sprintf("%q: got %s, want string", key, jsonType(m[key]))
+		}
+	}
+	return ""
+}
+
+func oneOf(values ...string) check {
+	return func(value any) string {
+		if s, ok := value.(string); !ok || !slices.Contains(values, s) {
+			return fmt.Sprintf("got %s, want one of %s", jsonType(value), strings.Join(values, ", "))
+		}
+		return ""
+	}
+}
+
+// isObject returns a check of an object with the given fields, reporting unknown fields as well.
+func isObject(fields map[string]check) check {
+	return func(value any) string {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Sprintf("got %s, want object", jsonType(value))
+		}
+		var problems []string
+		for _, key := range slices.Sorted(maps.Keys(m)) {
+			check, ok := fields[key]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%q: unknown field", key))
+			} else if problem := check(m[key]); problem != "" {
+				problems = append(problems, fmt.Sprintf("%q: %s", key, problem))
+			}
+		}
+		return strings.Join(problems, "; ")
+	}
+}
+
+// required returns a check of an object which requires the given keys, in addition to check.
+func required(check check, keys ...string) check {
+	return func(value any) string {
+		if problem := check(value); problem != "" {
+			return problem
+		}
+		m := value.(map[string]any)
+		var missing []string
+		for _, key := range keys {
+			if _, ok := m[key]; !ok {
+				missing = append(missing, strconv.Quote(key))
+			}
+		}
+		if len(missing) > 0 {
+			return "missing " + strings.Join(missing, ", ")
+		}
+		return ""
+	}
+}
+
+// jsonType describes a decoded JSON value for error messages.
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return "boolean " + strconv.FormatBool(v)
+	case json.Number:
+		return "number " + v.String()
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// validator collects the violations of an entry.
+type validator struct {
+	errs []error
+}
+
+func (v *validator) check(key string, value any, check check) {
+	if problem := check(value); problem != "" {
+		v.fail(key, "%s", problem)
+	}
+}
+
+func (v *validator) fail(key, format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf("sloggcptest: field %q: %s", key, fmt.Sprintf(format, args...)))
+}
@@ -0,0 +1,195 @@
+package sloggcptest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zitadel/sloggcp"
+)
+
+func TestValidateEntry_handlerOutput(t *testing.T) {
+	traceExtractor := func(context.Context) (string, string, *bool) {
+		sampled := true
+		return "105445aa7843bc8bf206b12000100000", "000000000000004a", &sampled
+	}
+	tests := []struct {
+		name    string
+		options []sloggcp.Option
+		log     func(*slog.Logger)
+	}{
+		{
+			name: "plain",
+			log:  func(l *slog.Logger) { l.Info("msg", "a", 1) },
+		},
+		{
+			name: "special fields",
+			options: []sloggcp.Option{
+				sloggcp.WithAddSource(true),
+				sloggcp.WithLabels(map[string]string{"env": "test"}),
+				sloggcp.WithTraceExtractor(traceExtractor),
+				sloggcp.WithProjectID("my-project"),
+				sloggcp.WithInsertIDGenerator(func() string { return "id-1" }),
+			},
+			log: func(l *slog.Logger) {
+				l.Warn("msg",
+					sloggcp.HTTPRequestKey, sloggcp.HTTPRequest{
+						RequestMethod: "GET",
+						RequestURL:    "https://example.com/",
+						RequestSize:   10,
+						Status:        200,
+						ResponseSize:  20,
+						Latency:       1500 * time.Millisecond,
+						CacheHit:      true,
+					},
+					sloggcp.OperationKey, sloggcp.Operation{ID: "op", Producer: "test", First: true},
+				)
+			},
+		},
+		{
+			name:    "time as epoch",
+			options: []sloggcp.Option{sloggcp.WithTimeAsEpoch(true)},
+			log:     func(l *slog.Logger) { l.Info("msg") },
+		},
+		{
+			name:    "error report",
+			options: []sloggcp.Option{sloggcp.WithServiceContext("api", "v1")},
+			log:     func(l *slog.Logger) { l.Error("msg", sloggcp.ErrorKey, sloggcp.NewError("oops")) },
+		},
+		{
+			name:    "error report with location",
+			options: []sloggcp.Option{sloggcp.WithAutoReportLocation(true)},
+			log:     func(l *slog.Logger) { l.Error("msg", sloggcp.ErrorKey, errors.New("oops")) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.log(slog.New(sloggcp.New(&buf, tt.options...)))
+			if err := ValidateEntry(buf.Bytes()); err != nil {
+				t.Errorf("ValidateEntry(%s) = %v", buf.String(), err)
+			}
+		})
+	}
+}
+
+func TestValidateEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   string
+		wantErr []string
+	}{
+		{
+			name:  "valid",
+			entry: `{"severity":"INFO","time":"2024-01-02T03:04:05.123Z","message":"msg","a":{"b":1}}`,
+		},
+		{
+			name:    "not an object",
+			entry:   `["msg"]`,
+			wantErr: []string{"not a JSON object"},
+		},
+		{
+			name:    "more than one value",
+			entry:   `{"message":"a"} {"message":"b"}`,
+			wantErr: []string{"more than one JSON value"},
+		},
+		{
+			name:    "severity",
+			entry:   `{"severity":"WARN"}`,
+			wantErr: []string{`"severity": got "WARN", want one of DEFAULT`},
+		},
+		{
+			name:    "time",
+			entry:   `{"time":"02 Jan 24 03:04 UTC"}`,
+			wantErr: []string{`"time": got "02 Jan 24 03:04 UTC", want RFC 3339 timestamp`},
+		},
+		{
+			name:    "timestamp seconds without nanos",
+			entry:   `{"timestampSeconds":1700000000}`,
+			wantErr: []string{"must be set together"},
+		},
+		{
+			name:    "message",
+			entry:   `{"message":{"text":"msg"}}`,
+			wantErr: []string{`"message": got object, want string`},
+		},
+		{
+			name:    "source location line as number",
+			entry:   `{"logging.googleapis.com/sourceLocation":{"file":"main.go","line":42,"function":"main.main"}}`,
+			wantErr: []string{`"line": got number 42, want decimal string`},
+		},
+		{
+			name:    "source location unknown field",
+			entry:   `{"logging.googleapis.com/sourceLocation":{"file":"main.go","lineNumber":"42"}}`,
+			wantErr: []string{`"lineNumber": unknown field`},
+		},
+		{
+			name:    "labels",
+			entry:   `{"logging.googleapis.com/labels":{"env":"prod","count":1}}`,
+			wantErr: []string{`"count": got number 1, want string`},
+		},
+		{
+			name:    "misspelled special field",
+			entry:   `{"logging.googleapis.com/spanID":"abc"}`,
+			wantErr: []string{`"logging.googleapis.com/spanID": unknown special field`},
+		},
+		{
+			name:    "trace sampled",
+			entry:   `{"logging.googleapis.com/trace_sampled":"true"}`,
+			wantErr: []string{`got "true", want boolean`},
+		},
+		{
+			name:    "http request",
+			entry:   `{"httpRequest":{"status":"200","responseSize":20,"latency":"1500ms"}}`,
+			wantErr: []string{`"status": got "200", want integer`, `"responseSize": got number 20, want decimal string`, `"latency": got "1500ms"`},
+		},
+		{
+			name:    "error report without message",
+			entry:   `{"@type":"` + sloggcp.ErrorReportTypeValue + `","message":""}`,
+			wantErr: []string{"error report without message"},
+		},
+		{
+			name:    "report location",
+			entry:   `{"reportLocation":{"filePath":"main.go","lineNumber":"42"}}`,
+			wantErr: []string{`"lineNumber": got "42", want integer`},
+		},
+		{
+			name:    "report location missing fields",
+			entry:   `{"reportLocation":{"filePath":"main.go","lineNumber":42}}`,
+			wantErr: []string{`missing "functionName"`},
+		},
+		{
+			name:    "service context",
+			entry:   `{"serviceContext":{"version":"v1"}}`,
+			wantErr: []string{`missing "service"`},
+		},
+		{
+			name:    "all violations",
+			entry:   `{"severity":"info","message":1}`,
+			wantErr: []string{`"severity"`, `"message"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEntry([]byte(tt.entry))
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("ValidateEntry() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("ValidateEntry() = nil, want %q", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("ValidateEntry() = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package sloggcp
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strings"
+)
+
+// SourceLocation is the source location of a log entry, as defined by the
+// [LogEntrySourceLocation](https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogEntrySourceLocation) type.
+// The line is encoded as string, like all int64 values in the JSON representation of the Cloud Logging API.
+type SourceLocation struct {
+	File     string `json:"file,omitempty"`
+	Line     int64  `json:"line,string,omitempty"`
+	Function string `json:"function,omitempty"`
+}
+
+// NewSourceLocation converts the [slog.Source] to a [SourceLocation].
+func NewSourceLocation(source *slog.Source) *SourceLocation {
+	return &SourceLocation{
+		File:     source.File,
+		Line:     int64(source.Line),
+		Function: source.Function,
+	}
+}
+
+// SourceFormatter returns the value written to the [SourceLocationKey] field.
+// The returned value is encoded like any other attribute value.
+// When nil is returned, the field is omitted.
+type SourceFormatter func(source *slog.Source) any
+
+// WithSourceFormatter sets the function used to format the source location,
+// when [slog.HandlerOptions.AddSource] or [WithSourceForSeverity] is enabled.
+// By default, the [slog.Source] is written as [SourceLocation], including the full file path.
+func WithSourceFormatter(formatter SourceFormatter) Option {
+	return func(c *config) {
+		c.sourceFormatter = formatter
+	}
+}
+
+// ShortSource is a [SourceFormatter], which trims the file path
+// to the directory and the file name, for example "sloggcp/source.go".
+// This prevents leaking the build environment's directory structure.
+func ShortSource(source *slog.Source) any {
+	short := NewSourceLocation(source)
+	short.File = shortFile(source.File)
+	return short
+}
+
+func shortFile(file string) string {
+	file = filepath.ToSlash(file)
+	i := strings.LastIndexByte(file, '/')
+	if i <= 0 {
+		return file
+	}
+	if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+		return file[j+1:]
+	}
+	return file
+}
+
+// WithSourceForSeverity adds the source location to records at or above the min level,
+// even if [slog.HandlerOptions.AddSource] is disabled.
+// This limits the cost of the source location to the entries where it is needed, such as errors.
+// When AddSource is enabled, the source location is added to all records.
+func WithSourceForSeverity(min slog.Level) Option {
+	return func(c *config) {
+		c.sourceLevel, c.sourceLevelSet = min, true
+	}
+}
+
+// addSource reports whether the source location is added to records with the level.
+func (h *handler) addSource(level slog.Level) bool {
+	return h.opts.AddSource || (h.cfg.sourceLevelSet && level >= h.cfg.sourceLevel)
+}
+
+func (c *config) formatSource(source *slog.Source) any {
+	if c.sourceFormatter == nil {
+		return NewSourceLocation(source)
+	}
+	return c.sourceFormatter(source)
+}
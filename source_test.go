@@ -0,0 +1,190 @@
+package sloggcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_sourceFormatter(t *testing.T) {
+	const function = "github.com/zitadel/sloggcp.TestHandler_sourceFormatter.func4"
+	tests := []struct {
+		name     string
+		options  []Option
+		wantFile func(file string) bool
+		want     any
+	}{
+		{
+			name:     "default",
+			wantFile: filepath.IsAbs,
+			want:     map[string]any{"function": function},
+		},
+		{
+			name:    "short source",
+			options: []Option{WithSourceFormatter(ShortSource)},
+			wantFile: func(file string) bool {
+				return strings.Count(file, "/") == 1 && strings.HasSuffix(file, "/source_test.go")
+			},
+			want: map[string]any{"function": function},
+		},
+		{
+			name: "custom",
+			options: []Option{WithSourceFormatter(func(source *slog.Source) any {
+				return slog.GroupValue(slog.String("function", source.Function))
+			})},
+			want: map[string]any{"function": function},
+		},
+		{
+			name: "omitted",
+			options: []Option{WithSourceFormatter(func(*slog.Source) any {
+				return nil
+			})},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(NewErrorReportingHandler(&buf, &slog.HandlerOptions{AddSource: true}, tt.options...))
+			logger.Info("msg")
+
+			var got struct {
+				Source any `json:"logging.googleapis.com/sourceLocation"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			if m, ok := got.Source.(map[string]any); ok {
+				if line, ok := m["line"]; ok {
+					if _, ok := line.(string); !ok {
+						t.Errorf("sourceLocation line = %v, want string", line)
+					}
+				}
+				delete(m, "line")
+				if file, ok := m["file"].(string); ok {
+					if tt.wantFile == nil || !tt.wantFile(file) {
+						t.Errorf("sourceLocation file = %v, unexpected", file)
+					}
+					delete(m, "file")
+				}
+			}
+			if !reflect.DeepEqual(got.Source, tt.want) {
+				t.Errorf("sourceLocation = %v, want %v", got.Source, tt.want)
+			}
+		})
+	}
+}
+
+func Test_shortFile(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"/home/ci/build/pkg/file.go", "pkg/file.go"},
+		{"pkg/file.go", "pkg/file.go"},
+		{"/file.go", "/file.go"},
+		{"file.go", "file.go"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			if got := shortFile(tt.file); got != tt.want {
+				t.Errorf("shortFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithSourceForSeverity(t *testing.T) {
+	tests := []struct {
+		name      string
+		addSource bool
+		min       slog.Level
+		level     slog.Level
+		want      bool
+	}{
+		{name: "below", min: LevelError, level: LevelWarning, want: false},
+		{name: "at", min: LevelError, level: LevelError, want: true},
+		{name: "above", min: LevelError, level: LevelCritical, want: true},
+		{name: "AddSource", addSource: true, min: LevelError, level: LevelInfo, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := NewErrorReportingHandler(&buf, &slog.HandlerOptions{AddSource: tt.addSource}, WithSourceForSeverity(tt.min))
+			slog.New(h).Log(t.Context(), tt.level, "msg")
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			if _, ok := got[SourceLocationKey]; ok != tt.want {
+				t.Errorf("sourceLocation present = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceLocation_json(t *testing.T) {
+	tests := []struct {
+		name   string
+		source *slog.Source
+		want   string
+	}{
+		{
+			name:   "complete",
+			source: &slog.Source{Function: "pkg.Func", File: "/src/pkg/file.go", Line: 123},
+			want:   `{"file":"/src/pkg/file.go","line":"123","function":"pkg.Func"}`,
+		},
+		{
+			name:   "empty",
+			source: &slog.Source{},
+			want:   `{}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(NewSourceLocation(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("json = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_sourceUnknown(t *testing.T) {
+	tests := []struct {
+		name string
+		pc   uintptr
+	}{
+		{name: "no PC", pc: 0},
+		{name: "unknown PC", pc: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := New(&buf, WithAddSource(true))
+			r := slog.NewRecord(time.Now(), slog.LevelError, "msg", tt.pc)
+			r.AddAttrs(slog.String(ErrorKey, "oops"))
+			if err := h.Handle(t.Context(), r); err != nil {
+				t.Fatal(err)
+			}
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			if v, ok := got[SourceLocationKey]; ok {
+				t.Errorf("%s = %v, want none", SourceLocationKey, v)
+			}
+		})
+	}
+}
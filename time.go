@@ -0,0 +1,91 @@
+package sloggcp
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Keys of the special fields holding the time of the log entry
+// as seconds and nanoseconds since the Unix epoch, see [WithTimeAsEpoch].
+// See https://cloud.google.com/logging/docs/structured-logging#special-payload-fields.
+const (
+	TimestampSecondsKey = "timestampSeconds"
+	TimestampNanosKey   = "timestampNanos"
+)
+
+// WithTimeFormat sets the layout used to format the time of records under [TimeKey].
+// The layout must produce a timestamp recognized by Cloud Logging.
+// By default, [time.RFC3339Nano] is used.
+// The time is converted to UTC before formatting.
+func WithTimeFormat(layout string) Option {
+	return func(c *config) {
+		c.timeFormat = layout
+	}
+}
+
+// WithTimeAsEpoch writes the time of records as the [TimestampSecondsKey] and [TimestampNanosKey] fields,
+// the seconds and nanoseconds since the Unix epoch, instead of the [TimeKey] field.
+// This representation is expected by some configurations of the logging agent.
+func WithTimeAsEpoch(enabled bool) Option {
+	return func(c *config) {
+		c.timeAsEpoch = enabled
+	}
+}
+
+// WithAlwaysTime writes the time of records, even if it is the zero [time.Time].
+// By default, the time is omitted for records without time, like by the handlers of the slog package,
+// and Cloud Logging uses the time the entry was received.
+func WithAlwaysTime(enabled bool) Option {
+	return func(c *config) {
+		c.alwaysTime = enabled
+	}
+}
+
+// WithClock sets a function which provides the time of records without time,
+// such as a fake clock in tests, or the original time when replaying historical data.
+// The time of a record, if set, always takes precedence. [slog.Logger] sets it to the current time,
+// so the clock applies to records created with the zero time, for example by [slog.NewRecord]
+// in a handler wrapping this one, or in code calling the handler directly.
+// The time returned by the clock is written like the time of a record, and passed to the [ErrorReporter].
+// If it returns the zero time, the time is omitted, unless [WithAlwaysTime] is enabled.
+func WithClock(clock func() time.Time) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// addRecordTime adds the time of a record to the top-level object,
+// after passing it to [slog.HandlerOptions.ReplaceAttr] as [slog.TimeKey] attribute, if set.
+// The time is omitted if ReplaceAttr returns an attribute without key.
+// A time value under [slog.TimeKey] is written according to the options,
+// other values and keys are written like attributes.
+func (h *handler) addRecordTime(out *object, t time.Time) {
+	if h.opts.ReplaceAttr == nil {
+		h.cfg.addTime(out, t)
+		return
+	}
+	a := h.opts.ReplaceAttr(nil, slog.Time(slog.TimeKey, t))
+	switch {
+	case a.Key == "":
+	case a.Key == slog.TimeKey && a.Value.Kind() == slog.KindTime:
+		h.cfg.addTime(out, a.Value.Time())
+	default:
+		out.addAttr(a.Key, a.Value)
+	}
+}
+
+// addTime adds the time of a record to the top-level object.
+// The time is written in UTC, so entries written in different time zones
+// are unambiguous and sort consistently.
+func (c *config) addTime(out *object, t time.Time) {
+	t = t.UTC()
+	switch {
+	case c.timeAsEpoch:
+		out.add(TimestampSecondsKey, slog.Int64Value(t.Unix()))
+		out.add(TimestampNanosKey, slog.IntValue(t.Nanosecond()))
+	case c.timeFormat != "":
+		out.add(TimeKey, slog.StringValue(t.Format(c.timeFormat)))
+	default:
+		out.add(TimeKey, slog.TimeValue(t))
+	}
+}
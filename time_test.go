@@ -0,0 +1,251 @@
+package sloggcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithAlwaysTime(t *testing.T) {
+	tests := []struct {
+		name       string
+		enabled    bool
+		recordTime time.Time
+		want       any
+	}{
+		{
+			name: "zero omitted",
+			want: nil,
+		},
+		{
+			name:    "zero",
+			enabled: true,
+			want:    "0001-01-01T00:00:00Z",
+		},
+		{
+			name:       "epoch",
+			recordTime: time.Unix(0, 0).UTC(),
+			want:       "1970-01-01T00:00:00Z",
+		},
+		{
+			name:       "epoch enabled",
+			enabled:    true,
+			recordTime: time.Unix(0, 0).UTC(),
+			want:       "1970-01-01T00:00:00Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := NewErrorReportingHandler(&buf, nil, WithAlwaysTime(tt.enabled))
+			r := slog.NewRecord(tt.recordTime, slog.LevelInfo, "", 0)
+			if err := h.Handle(t.Context(), r); err != nil {
+				t.Fatal(err)
+			}
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			if got[TimeKey] != tt.want {
+				t.Errorf("time = %v, want %v", got[TimeKey], tt.want)
+			}
+		})
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	clockTime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	clock := func() time.Time { return clockTime }
+	tests := []struct {
+		name       string
+		clock      func() time.Time
+		alwaysTime bool
+		recordTime time.Time
+		want       any
+	}{
+		{
+			name:  "zero record time",
+			clock: clock,
+			want:  "2024-05-06T07:08:09Z",
+		},
+		{
+			name:       "record time takes precedence",
+			clock:      clock,
+			recordTime: time.Unix(0, 0).UTC(),
+			want:       "1970-01-01T00:00:00Z",
+		},
+		{
+			name:  "zero clock time omitted",
+			clock: func() time.Time { return time.Time{} },
+			want:  nil,
+		},
+		{
+			name:       "zero clock time, always time",
+			clock:      func() time.Time { return time.Time{} },
+			alwaysTime: true,
+			want:       "0001-01-01T00:00:00Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				buf     bytes.Buffer
+				reports []ErrorReport
+			)
+			reporter := ErrorReporterFunc(func(_ context.Context, r ErrorReport) {
+				reports = append(reports, r)
+			})
+			h := New(&buf, WithClock(tt.clock), WithAlwaysTime(tt.alwaysTime), WithErrorReporter(reporter))
+			r := slog.NewRecord(tt.recordTime, slog.LevelError, "", 0)
+			r.AddAttrs(slog.String(ErrorKey, "oops"))
+			if err := h.Handle(t.Context(), r); err != nil {
+				t.Fatal(err)
+			}
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			if got[TimeKey] != tt.want {
+				t.Errorf("time = %v, want %v", got[TimeKey], tt.want)
+			}
+			if want, _ := tt.want.(string); len(reports) != 1 || want != "" && reports[0].Time.Format(time.RFC3339Nano) != want {
+				t.Errorf("reports = %+v, want time %v", reports, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_time(t *testing.T) {
+	recordTime := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+	tests := []struct {
+		name       string
+		options    []Option
+		recordTime time.Time
+		want       map[string]any
+	}{
+		{
+			name: "default",
+			want: map[string]any{TimeKey: "2024-05-06T07:08:09.123456789Z"},
+		},
+		{
+			name:    "format",
+			options: []Option{WithTimeFormat(time.RFC3339)},
+			want:    map[string]any{TimeKey: "2024-05-06T07:08:09Z"},
+		},
+		{
+			name:    "epoch",
+			options: []Option{WithTimeAsEpoch(true)},
+			want: map[string]any{
+				TimestampSecondsKey: float64(recordTime.Unix()),
+				TimestampNanosKey:   float64(123456789),
+			},
+		},
+		{
+			name:    "epoch disabled",
+			options: []Option{WithTimeAsEpoch(false)},
+			want:    map[string]any{TimeKey: "2024-05-06T07:08:09.123456789Z"},
+		},
+		{
+			name:       "local time",
+			recordTime: recordTime.In(time.FixedZone("CEST", 2*60*60)),
+			want:       map[string]any{TimeKey: "2024-05-06T07:08:09.123456789Z"},
+		},
+		{
+			name:       "local time format",
+			options:    []Option{WithTimeFormat(time.RFC3339)},
+			recordTime: recordTime.In(time.FixedZone("PDT", -7*60*60)),
+			want:       map[string]any{TimeKey: "2024-05-06T07:08:09Z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := NewErrorReportingHandler(&buf, nil, tt.options...)
+			if tt.recordTime.IsZero() {
+				tt.recordTime = recordTime
+			}
+			r := slog.NewRecord(tt.recordTime, slog.LevelInfo, "", 0)
+			if err := h.Handle(t.Context(), r); err != nil {
+				t.Fatal(err)
+			}
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			delete(got, SeverityKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_timeReplaceAttr(t *testing.T) {
+	recordTime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	tests := []struct {
+		name        string
+		replaceAttr func(groups []string, a slog.Attr) slog.Attr
+		want        map[string]any
+	}{
+		{
+			name:        "unchanged",
+			replaceAttr: func(_ []string, a slog.Attr) slog.Attr { return a },
+			want:        map[string]any{TimeKey: "2024-05-06T07:08:09Z"},
+		},
+		{
+			name: "removed",
+			replaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey {
+					return slog.Attr{}
+				}
+				return a
+			},
+			want: map[string]any{},
+		},
+		{
+			name: "replaced time",
+			replaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey {
+					return slog.Time(a.Key, a.Value.Time().Add(time.Hour))
+				}
+				return a
+			},
+			want: map[string]any{TimeKey: "2024-05-06T08:08:09Z"},
+		},
+		{
+			name: "reformatted",
+			replaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey {
+					return slog.String("timestamp", a.Value.Time().Format(time.DateOnly))
+				}
+				return a
+			},
+			want: map[string]any{"timestamp": "2024-05-06"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := New(&buf, WithReplaceAttr(tt.replaceAttr))
+			if err := h.Handle(t.Context(), slog.NewRecord(recordTime, slog.LevelInfo, "", 0)); err != nil {
+				t.Fatal(err)
+			}
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			delete(got, SeverityKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package sloggcp
+
+import (
+	"cmp"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// Keys for trace correlation attributes used in GCP structured logging.
+// See https://cloud.google.com/logging/docs/structured-logging#special-payload-fields.
+const (
+	TraceKey        = "logging.googleapis.com/trace"
+	SpanIDKey       = "logging.googleapis.com/spanId"
+	TraceSampledKey = "logging.googleapis.com/trace_sampled"
+)
+
+// TraceExtractor returns the trace ID, span ID and sampling decision
+// of the trace found in the context.
+// If the context does not carry a trace, an empty traceID must be returned.
+// sampled is nil when the sampling decision is unknown, so the [TraceSampledKey] field is omitted.
+// Cloud Logging treats an absent field differently from false in some pipelines,
+// so false must only be returned when the trace is known to be not sampled.
+type TraceExtractor func(ctx context.Context) (traceID, spanID string, sampled *bool)
+
+// setTrace adds the trace correlation attributes to out,
+// if a trace is found in the context by the [TraceExtractor],
+// or else stored in the context by [RequestLogger].
+func (c *config) setTrace(ctx context.Context, out *object) {
+	traceID, spanID, sampled := c.extractTrace(ctx)
+	if traceID == "" {
+		return
+	}
+	out.add(TraceKey, slog.StringValue(traceID))
+	if spanID != "" {
+		out.add(SpanIDKey, slog.StringValue(spanID))
+	}
+	if sampled != nil {
+		out.add(TraceSampledKey, slog.BoolValue(*sampled))
+	}
+}
+
+// extractTrace returns the trace found in ctx, with the trace ID formatted by formatTrace.
+func (c *config) extractTrace(ctx context.Context) (traceID, spanID string, sampled *bool) {
+	if c.traceExtractor != nil {
+		if traceID, spanID, sampled = c.traceExtractor(ctx); traceID != "" {
+			return c.formatTrace(traceID), spanID, sampled
+		}
+	}
+	if ctx == nil {
+		return "", "", nil
+	}
+	t, ok := ctx.Value(traceContextKey{}).(traceContext)
+	if !ok {
+		return "", "", nil
+	}
+	return qualifyTrace(cmp.Or(c.projectID, t.projectID), t.traceID), t.spanID, t.sampled
+}
+
+// formatTrace returns the fully qualified trace resource name,
+// if a project ID is configured and the trace ID is not already qualified.
+func (c *config) formatTrace(traceID string) string {
+	return qualifyTrace(c.projectID, traceID)
+}
+
+// qualifyTrace implements formatTrace for projectID.
+func qualifyTrace(projectID, traceID string) string {
+	if projectID == "" || strings.HasPrefix(traceID, "projects/") {
+		return traceID
+	}
+	return "projects/" + projectID + "/traces/" + traceID
+}
+
+// CloudTraceContextHeader is the HTTP header set by the Google Cloud load balancer
+// and Cloud Run, carrying the trace context of a request.
+// Its format is "TRACE_ID/SPAN_ID;o=OPTIONS".
+const CloudTraceContextHeader = "X-Cloud-Trace-Context"
+
+// ParseCloudTraceContext parses the value of a [CloudTraceContextHeader].
+//
+// The TRACE_ID is a 32 character hexadecimal value and is returned as-is, in lower case.
+// The SPAN_ID in the header is a decimal unsigned 64 bit integer,
+// while Cloud Logging expects the spanId field as 16 character hexadecimal encoding of the same 8 bytes.
+// Therefore the span ID is converted, for example "74" becomes "000000000000004a".
+// The SPAN_ID and OPTIONS parts are optional, an empty SPAN_ID as in "TRACE_ID/;o=1" returns an empty span ID.
+// Sampled is true when OPTIONS is "o=1".
+//
+// ok is false when the header is empty or malformed.
+func ParseCloudTraceContext(header string) (traceID, spanID string, sampled bool, ok bool) {
+	traceID, rest, _ := strings.Cut(header, "/")
+	traceID, options, _ := strings.Cut(traceID, ";")
+	if !isTraceID(traceID) {
+		return "", "", false, false
+	}
+	if rest != "" {
+		var span string
+		span, options, _ = strings.Cut(rest, ";")
+		if span != "" {
+			id, err := strconv.ParseUint(span, 10, 64)
+			if err != nil {
+				return "", "", false, false
+			}
+			spanID = fmt.Sprintf("%016x", id)
+		}
+	}
+	return strings.ToLower(traceID), spanID, options == "o=1", true
+}
+
+func isTraceID(s string) bool {
+	if len(s) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,255 @@
+package sloggcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+type traceCtxKey struct{}
+
+type testTrace struct {
+	traceID string
+	spanID  string
+	sampled *bool
+}
+
+func testTraceExtractor(ctx context.Context) (traceID, spanID string, sampled *bool) {
+	t, _ := ctx.Value(traceCtxKey{}).(testTrace)
+	return t.traceID, t.spanID, t.sampled
+}
+
+func TestHandler_trace(t *testing.T) {
+	tests := []struct {
+		name  string
+		trace testTrace
+		group string
+		want  map[string]any
+	}{
+		{
+			name: "no trace",
+			want: map[string]any{
+				MessageKey:  "msg",
+				SeverityKey: InfoSeverity,
+			},
+		},
+		{
+			name: "trace and span",
+			trace: testTrace{
+				traceID: "trace",
+				spanID:  "span",
+				sampled: ptr(true),
+			},
+			want: map[string]any{
+				MessageKey:      "msg",
+				SeverityKey:     InfoSeverity,
+				TraceKey:        "trace",
+				SpanIDKey:       "span",
+				TraceSampledKey: true,
+			},
+		},
+		{
+			name: "trace without span",
+			trace: testTrace{
+				traceID: "trace",
+			},
+			want: map[string]any{
+				MessageKey:  "msg",
+				SeverityKey: InfoSeverity,
+				TraceKey:    "trace",
+			},
+		},
+		{
+			name: "not sampled",
+			trace: testTrace{
+				traceID: "trace",
+				sampled: ptr(false),
+			},
+			want: map[string]any{
+				MessageKey:      "msg",
+				SeverityKey:     InfoSeverity,
+				TraceKey:        "trace",
+				TraceSampledKey: false,
+			},
+		},
+		{
+			name: "trace in group",
+			trace: testTrace{
+				traceID: "trace",
+				spanID:  "span",
+			},
+			group: "group",
+			want: map[string]any{
+				MessageKey:  "msg",
+				SeverityKey: InfoSeverity,
+				TraceKey:    "trace",
+				SpanIDKey:   "span",
+				"group": map[string]any{
+					"foo": "bar",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(NewErrorReportingHandler(&buf, nil, WithTraceExtractor(testTraceExtractor)))
+			ctx := context.WithValue(t.Context(), traceCtxKey{}, tt.trace)
+			if tt.group != "" {
+				logger.WithGroup(tt.group).InfoContext(ctx, "msg", "foo", "bar")
+			} else {
+				logger.InfoContext(ctx, "msg")
+			}
+
+			got := make(map[string]any)
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode log output: %v", err)
+			}
+			delete(got, TimeKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("log output = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_config_formatTrace(t *testing.T) {
+	tests := []struct {
+		name      string
+		projectID string
+		traceID   string
+		want      string
+	}{
+		{
+			name:    "no project ID",
+			traceID: "abc",
+			want:    "abc",
+		},
+		{
+			name:      "project ID",
+			projectID: "my-project",
+			traceID:   "abc",
+			want:      "projects/my-project/traces/abc",
+		},
+		{
+			name:      "already qualified",
+			projectID: "my-project",
+			traceID:   "projects/other/traces/abc",
+			want:      "projects/other/traces/abc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConfig([]Option{WithProjectID(tt.projectID)})
+			if got := c.formatTrace(tt.traceID); got != tt.want {
+				t.Errorf("formatTrace() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCloudTraceContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		wantTraceID string
+		wantSpanID  string
+		wantSampled bool
+		wantOk      bool
+	}{
+		{
+			name:        "full header, sampled",
+			header:      "105445aa7843bc8bf206b12000100000/74;o=1",
+			wantTraceID: "105445aa7843bc8bf206b12000100000",
+			wantSpanID:  "000000000000004a",
+			wantSampled: true,
+			wantOk:      true,
+		},
+		{
+			name:        "full header, not sampled",
+			header:      "105445aa7843bc8bf206b12000100000/74;o=0",
+			wantTraceID: "105445aa7843bc8bf206b12000100000",
+			wantSpanID:  "000000000000004a",
+			wantOk:      true,
+		},
+		{
+			name:        "max span ID",
+			header:      "105445aa7843bc8bf206b12000100000/18446744073709551615;o=1",
+			wantTraceID: "105445aa7843bc8bf206b12000100000",
+			wantSpanID:  "ffffffffffffffff",
+			wantSampled: true,
+			wantOk:      true,
+		},
+		{
+			name:        "without options",
+			header:      "105445aa7843bc8bf206b12000100000/1",
+			wantTraceID: "105445aa7843bc8bf206b12000100000",
+			wantSpanID:  "0000000000000001",
+			wantOk:      true,
+		},
+		{
+			name:        "trace ID only",
+			header:      "105445AA7843BC8BF206B12000100000",
+			wantTraceID: "105445aa7843bc8bf206b12000100000",
+			wantOk:      true,
+		},
+		{
+			name:        "trace ID with options",
+			header:      "105445aa7843bc8bf206b12000100000;o=1",
+			wantTraceID: "105445aa7843bc8bf206b12000100000",
+			wantSampled: true,
+			wantOk:      true,
+		},
+		{
+			name:        "empty span ID",
+			header:      "105445aa7843bc8bf206b12000100000/;o=1",
+			wantTraceID: "105445aa7843bc8bf206b12000100000",
+			wantSampled: true,
+			wantOk:      true,
+		},
+		{
+			name:   "empty",
+			header: "",
+		},
+		{
+			name:   "short trace ID",
+			header: "105445aa/74;o=1",
+		},
+		{
+			name:   "invalid trace ID",
+			header: "x05445aa7843bc8bf206b12000100000/74;o=1",
+		},
+		{
+			name:   "hex span ID",
+			header: "105445aa7843bc8bf206b12000100000/4a;o=1",
+		},
+		{
+			name:   "span ID overflow",
+			header: "105445aa7843bc8bf206b12000100000/18446744073709551616;o=1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTraceID, gotSpanID, gotSampled, gotOk := ParseCloudTraceContext(tt.header)
+			if gotTraceID != tt.wantTraceID {
+				t.Errorf("ParseCloudTraceContext() traceID = %v, want %v", gotTraceID, tt.wantTraceID)
+			}
+			if gotSpanID != tt.wantSpanID {
+				t.Errorf("ParseCloudTraceContext() spanID = %v, want %v", gotSpanID, tt.wantSpanID)
+			}
+			if gotSampled != tt.wantSampled {
+				t.Errorf("ParseCloudTraceContext() sampled = %v, want %v", gotSampled, tt.wantSampled)
+			}
+			if gotOk != tt.wantOk {
+				t.Errorf("ParseCloudTraceContext() ok = %v, want %v", gotOk, tt.wantOk)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
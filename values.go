@@ -0,0 +1,161 @@
+package sloggcp
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// BytesFormat determines how []byte attribute values are encoded.
+type BytesFormat int
+
+const (
+	// BytesBase64 encodes []byte values as base64 string, like [json.Marshal] does.
+	BytesBase64 BytesFormat = iota
+	// BytesHex encodes []byte values as lower case hexadecimal string, suited for binary IDs and hashes.
+	BytesHex
+	// BytesString encodes []byte values as string, suited for UTF-8 text such as request bodies.
+	// Invalid UTF-8 sequences are replaced by the Unicode replacement character.
+	BytesString
+)
+
+// WithBytesFormat sets the encoding of []byte attribute values.
+// Named types, such as [json.RawMessage] or [net.IP], are encoded by their own rules.
+// By default, [BytesBase64] is used.
+func WithBytesFormat(format BytesFormat) Option {
+	return func(c *config) {
+		c.bytesFormat = format
+	}
+}
+
+// appendBytes encodes b according to [WithBytesFormat].
+// Values longer than allowed by [WithMaxValueBytes] are truncated before encoding.
+func (c *config) appendBytes(buf []byte, b []byte) []byte {
+	if c.bytesFormat == BytesString {
+		return appendString(buf, c.truncate(string(b)))
+	}
+	var marker string
+	if c.maxValueBytes > 0 && len(b) > c.maxValueBytes {
+		b, marker = b[:c.maxValueBytes], truncatedMarker
+	}
+	if c.bytesFormat == BytesHex {
+		return appendString(buf, hex.EncodeToString(b)+marker)
+	}
+	return appendString(buf, base64.StdEncoding.EncodeToString(b)+marker)
+}
+
+// DurationFormat determines how [time.Duration] attribute values are encoded.
+type DurationFormat int
+
+const (
+	// durationDefault encodes the durations of record attributes as [DurationString]
+	// and of attributes added with WithAttrs as [DurationNanos].
+	durationDefault DurationFormat = iota
+	// DurationNanos encodes durations as integer number of nanoseconds, like [json.Marshal] and [slog.JSONHandler] do.
+	DurationNanos
+	// DurationString encodes durations as string returned by [time.Duration.String], such as "1.5s".
+	DurationString
+	// DurationSeconds encodes durations as floating point number of seconds, such as 1.5.
+	DurationSeconds
+)
+
+// WithDurationFormat sets the encoding of [time.Duration] attribute values.
+// By default, the durations of record attributes are encoded as [DurationString]
+// and the durations of attributes added with WithAttrs as [DurationNanos].
+// Time values are always encoded as RFC 3339 string, like [json.Marshal] does.
+func WithDurationFormat(format DurationFormat) Option {
+	return func(c *config) {
+		c.durationFormat = format
+	}
+}
+
+// appendDuration encodes d according to [WithDurationFormat].
+// with is set for the durations of attributes added with WithAttrs.
+func (c *config) appendDuration(buf []byte, d time.Duration, with bool) ([]byte, error) {
+	switch c.durationFormat {
+	case DurationString:
+		return appendString(buf, d.String()), nil
+	case DurationSeconds:
+		return appendFloat(buf, d.Seconds())
+	case DurationNanos:
+		return strconv.AppendInt(buf, int64(d), 10), nil
+	}
+	if with {
+		return strconv.AppendInt(buf, int64(d), 10), nil
+	}
+	return appendString(buf, d.String()), nil
+}
+
+// appendRawMessage embeds the pre-encoded JSON value m, compacted like [json.Marshal] does.
+// Invalid JSON is encoded as string instead, so a single bad value does not fail the whole entry.
+func (c *config) appendRawMessage(buf []byte, m json.RawMessage) []byte {
+	if out, err := appendJSON(buf, m); err == nil {
+		return out
+	}
+	return appendString(buf, c.truncate(string(m)))
+}
+
+// WithMarshaler sets the function which encodes attribute values
+// that are not encoded by the handler itself, instead of [json.Marshal],
+// for example the Marshal function of a faster JSON package.
+// These are the values with a MarshalJSON or MarshalText method,
+// and all other values encoded according to [json.Marshal] rules, such as structs, maps and slices.
+// The function must return a single valid JSON value, without trailing newline.
+// Its output is written as-is, so invalid output corrupts the log entry.
+// Strings, numbers, times and the other values listed on [NewErrorReportingHandler],
+// as well as the fields the handler adds itself, are always encoded by the handler.
+// By default, or when marshal is nil, [json.Marshal] is used.
+func WithMarshaler(marshal func(v any) ([]byte, error)) Option {
+	return func(c *config) {
+		c.marshal = marshal
+	}
+}
+
+// appendMarshaled encodes v with the function set through [WithMarshaler], or [json.Marshal].
+func (c *config) appendMarshaled(buf []byte, v any) ([]byte, error) {
+	if c.marshal == nil {
+		return appendJSON(buf, v)
+	}
+	data, err := c.marshal(v)
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, data...), nil
+}
+
+// WithTypeEncoder registers a function which replaces attribute values of type t,
+// for example to write a uuid.UUID or a *big.Int as string:
+//
+//	sloggcp.WithTypeEncoder(reflect.TypeFor[*big.Int](), func(v any) any {
+//		return v.(*big.Int).String()
+//	})
+//
+// The value is passed to encode after [slog.LogValuer]s are resolved, and before all other rules
+// listed on [NewErrorReportingHandler], including the methods of [json.Marshaler], [error] and [fmt.Stringer].
+// The returned value is encoded by those rules, without applying type encoders again.
+// Values are matched by their dynamic type exactly, not by the interfaces they implement.
+// Registering another encoder for the same type replaces the previous one.
+func WithTypeEncoder(t reflect.Type, encode func(v any) any) Option {
+	return func(c *config) {
+		if c.typeEncoders == nil {
+			c.typeEncoders = make(map[reflect.Type]func(any) any)
+		}
+		c.typeEncoders[t] = encode
+	}
+}
+
+// encodeType applies the encoder registered for the type of v, if any.
+func (c *config) encodeType(v slog.Value) slog.Value {
+	if v.Kind() == slog.KindGroup {
+		return v
+	}
+	a := v.Any()
+	if encode, ok := c.typeEncoders[reflect.TypeOf(a)]; ok {
+		return slog.AnyValue(encode(a)).Resolve()
+	}
+	return v
+}
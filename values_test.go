@@ -0,0 +1,354 @@
+package sloggcp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithBytesFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		value   any
+		want    string
+	}{
+		{
+			name:  "default",
+			value: []byte("\x01\xfe id"),
+			want:  `"Af4gaWQ="`,
+		},
+		{
+			name:    "base64",
+			options: []Option{WithBytesFormat(BytesBase64)},
+			value:   []byte("\x01\xfe id"),
+			want:    `"Af4gaWQ="`,
+		},
+		{
+			name:    "hex",
+			options: []Option{WithBytesFormat(BytesHex)},
+			value:   []byte("\x01\xfe id"),
+			want:    `"01fe206964"`,
+		},
+		{
+			name:    "string",
+			options: []Option{WithBytesFormat(BytesString)},
+			value:   []byte(`{"body": "äö"}`),
+			want:    `"{\"body\": \"äö\"}"`,
+		},
+		{
+			name:    "string invalid UTF-8",
+			options: []Option{WithBytesFormat(BytesString)},
+			value:   []byte("a\xffb"),
+			want:    `"a�b"`,
+		},
+		{
+			name:    "base64 truncated",
+			options: []Option{WithMaxValueBytes(3)},
+			value:   []byte("abcdef"),
+			want:    `"YWJj` + truncatedMarker + `"`,
+		},
+		{
+			name:    "hex truncated",
+			options: []Option{WithBytesFormat(BytesHex), WithMaxValueBytes(2)},
+			value:   []byte("abcdef"),
+			want:    `"6162` + truncatedMarker + `"`,
+		},
+		{
+			name:    "string truncated",
+			options: []Option{WithBytesFormat(BytesString), WithMaxValueBytes(2)},
+			value:   []byte("aäb"),
+			want:    `"a` + truncatedMarker + `"`,
+		},
+		{
+			name:    "named type",
+			options: []Option{WithBytesFormat(BytesHex)},
+			value:   json.RawMessage(`{"a":1}`),
+			want:    `{"a":1}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newConfig(tt.options).appendValue(nil, "key", slog.AnyValue(tt.value), position{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("appendValue() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithDurationFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		with    bool // attribute added with WithAttrs
+		value   slog.Value
+		want    string
+	}{
+		{
+			name:  "default",
+			value: slog.DurationValue(1500 * time.Millisecond),
+			want:  `"1.5s"`,
+		},
+		{
+			name:  "default with",
+			with:  true,
+			value: slog.DurationValue(1500 * time.Millisecond),
+			want:  `1500000000`,
+		},
+		{
+			name:    "string with",
+			options: []Option{WithDurationFormat(DurationString)},
+			with:    true,
+			value:   slog.DurationValue(1500 * time.Millisecond),
+			want:    `"1.5s"`,
+		},
+		{
+			name:    "nanos",
+			options: []Option{WithDurationFormat(DurationNanos)},
+			value:   slog.DurationValue(1500 * time.Millisecond),
+			want:    `1500000000`,
+		},
+		{
+			name:    "string",
+			options: []Option{WithDurationFormat(DurationString)},
+			value:   slog.DurationValue(1500 * time.Millisecond),
+			want:    `"1.5s"`,
+		},
+		{
+			name:    "seconds",
+			options: []Option{WithDurationFormat(DurationSeconds)},
+			value:   slog.DurationValue(1500 * time.Millisecond),
+			want:    `1.5`,
+		},
+		{
+			name:    "any",
+			options: []Option{WithDurationFormat(DurationString)},
+			value:   slog.AnyValue(time.Minute),
+			want:    `"1m0s"`,
+		},
+		{
+			name:    "group member",
+			options: []Option{WithDurationFormat(DurationSeconds)},
+			value:   slog.GroupValue(slog.Duration("latency", 250*time.Millisecond)),
+			want:    `{"latency":0.25}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newConfig(tt.options).appendValue(nil, "key", tt.value, position{with: tt.with})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("appendValue() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_appendRawMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		value   json.RawMessage
+		want    string
+	}{
+		{
+			name:  "object",
+			value: json.RawMessage(`{"a": [1, 2], "b": "<c>"}`),
+			want:  `{"a":[1,2],"b":"\u003cc\u003e"}`,
+		},
+		{
+			name:  "nil",
+			value: nil,
+			want:  `null`,
+		},
+		{
+			name:  "invalid",
+			value: json.RawMessage(`{"a":`),
+			want:  `"{\"a\":"`,
+		},
+		{
+			name:    "invalid truncated",
+			options: []Option{WithMaxValueBytes(3)},
+			value:   json.RawMessage(`{"a":`),
+			want:    `"{\"a` + truncatedMarker + `"`,
+		},
+		{
+			name:    "valid not truncated",
+			options: []Option{WithMaxValueBytes(3)},
+			value:   json.RawMessage(`{"a":1}`),
+			want:    `{"a":1}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newConfig(tt.options).appendValue(nil, "key", slog.AnyValue(tt.value), position{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("appendValue() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithMarshaler(t *testing.T) {
+	type point struct{ X, Y int }
+	marshal := func(v any) ([]byte, error) {
+		if p, ok := v.(point); ok {
+			return []byte(fmt.Sprintf("[%d,%d]", p.X, p.Y)), nil
+		}
+		return nil, errors.New("unsupported")
+	}
+	tests := []struct {
+		name    string
+		options []Option
+		value   slog.Value
+		want    string
+	}{
+		{
+			name:  "default",
+			value: slog.AnyValue(point{1, 2}),
+			want:  `{"X":1,"Y":2}`,
+		},
+		{
+			name:    "custom",
+			options: []Option{WithMarshaler(marshal)},
+			value:   slog.AnyValue(point{1, 2}),
+			want:    `[1,2]`,
+		},
+		{
+			name:    "nil",
+			options: []Option{WithMarshaler(nil)},
+			value:   slog.AnyValue(point{1, 2}),
+			want:    `{"X":1,"Y":2}`,
+		},
+		{
+			name:    "encoded by handler",
+			options: []Option{WithMarshaler(marshal)},
+			value:   slog.GroupValue(slog.String("s", "v"), slog.Int("i", 1)),
+			want:    `{"i":1,"s":"v"}`,
+		},
+		{
+			name:    "error",
+			options: []Option{WithMarshaler(marshal)},
+			value:   slog.AnyValue(map[string]int{"a": 1}),
+			want:    `"!ERROR:unsupported"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newConfig(tt.options).appendValue(nil, "key", tt.value, position{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("appendValue() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+type testID [2]byte
+
+func (id testID) String() string {
+	return "stringer"
+}
+
+func TestWithTypeEncoder(t *testing.T) {
+	hexID := WithTypeEncoder(reflect.TypeFor[testID](), func(v any) any {
+		id := v.(testID)
+		return hex.EncodeToString(id[:])
+	})
+	tests := []struct {
+		name    string
+		options []Option
+		value   slog.Value
+		want    string
+	}{
+		{
+			name:  "default",
+			value: slog.AnyValue(testID{1, 2}),
+			want:  `"stringer"`,
+		},
+		{
+			name:    "before Stringer",
+			options: []Option{hexID},
+			value:   slog.AnyValue(testID{1, 2}),
+			want:    `"0102"`,
+		},
+		{
+			name:    "pointer type not matched",
+			options: []Option{hexID},
+			value:   slog.AnyValue(&testID{1, 2}),
+			want:    `"stringer"`,
+		},
+		{
+			name:    "group member",
+			options: []Option{hexID},
+			value:   slog.GroupValue(slog.Any("id", testID{1, 2})),
+			want:    `{"id":"0102"}`,
+		},
+		{
+			name: "big.Int",
+			options: []Option{WithTypeEncoder(reflect.TypeFor[*big.Int](), func(v any) any {
+				return v.(*big.Int).String()
+			})},
+			value: slog.AnyValue(new(big.Int).Lsh(big.NewInt(1), 70)),
+			want:  `"1180591620717411303424"`,
+		},
+		{
+			name: "kind value",
+			options: []Option{WithTypeEncoder(reflect.TypeFor[time.Duration](), func(v any) any {
+				return v.(time.Duration).Milliseconds()
+			})},
+			value: slog.DurationValue(1500 * time.Millisecond),
+			want:  `1500`,
+		},
+		{
+			name: "result not encoded again",
+			options: []Option{WithTypeEncoder(reflect.TypeFor[string](), func(v any) any {
+				return v.(string) + "!"
+			})},
+			value: slog.StringValue("a"),
+			want:  `"a!"`,
+		},
+		{
+			name: "LogValuer resolved first",
+			options: []Option{WithTypeEncoder(reflect.TypeFor[string](), func(v any) any {
+				return "encoded"
+			})},
+			value: slog.AnyValue(logValuerFunc(func() slog.Value { return slog.StringValue("resolved") })),
+			want:  `"encoded"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newConfig(tt.options).appendValue(nil, "key", tt.value, position{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("appendValue() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+type logValuerFunc func() slog.Value
+
+func (f logValuerFunc) LogValue() slog.Value {
+	return f()
+}